@@ -9,6 +9,10 @@ import (
 // DefaultPort is used to compute the TCP address in the absense of one.
 const DefaultPort = 3265
 
+// DefaultHTTPPort is the port of the HTTP/JSON echo endpoint of reflectors if
+// the configuration does not specify one.
+const DefaultHTTPPort = 3266
+
 // ExternalIP looks up an the first available external IP address.
 func ExternalIP() (string, error) {
 
@@ -72,3 +76,18 @@ func ResolveAddr(addr string) (string, error) {
 
 	return tcpAddr.String(), nil
 }
+
+// HTTPAddr replaces the port of an address with the HTTP port, so that the
+// HTTP/JSON echo endpoint of a device can be found from its gRPC address.
+func HTTPAddr(addr string, port int) (string, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse address: %s", err.Error())
+	}
+
+	if port == 0 {
+		port = DefaultHTTPPort
+	}
+
+	return net.JoinHostPort(host, fmt.Sprintf("%d", port)), nil
+}
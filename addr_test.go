@@ -83,4 +83,25 @@ var _ = Describe("Addr", func() {
 
 	})
 
+	Describe("HTTP addresses", func() {
+
+		It("should replace the port of an address", func() {
+			addr, err := HTTPAddr("192.168.1.1:3265", 8080)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(addr).Should(Equal("192.168.1.1:8080"))
+		})
+
+		It("should use the default HTTP port", func() {
+			addr, err := HTTPAddr("192.168.1.1:3265", 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(addr).Should(Equal(fmt.Sprintf("192.168.1.1:%d", DefaultHTTPPort)))
+		})
+
+		It("should return an error if port is missing in an address", func() {
+			_, err := HTTPAddr("192.168.1.1", 8080)
+			Ω(err).Should(HaveOccurred())
+		})
+
+	})
+
 })
@@ -1,6 +1,12 @@
 // Code generated by go-bindata.
 // sources:
-// fixtures/schema.sql
+// fixtures/migrations/0001_baseline.sql
+// fixtures/migrations/0002_ping_status_and_timings.sql
+// fixtures/migrations/0003_clock_offsets.sql
+// fixtures/migrations/0004_sequences_and_reflections.sql
+// fixtures/migrations/0005_transports_and_probes.sql
+// fixtures/migrations/0006_payloads.sql
+// fixtures/migrations/0007_ping_sent_index.sql
 // DO NOT EDIT!
 
 package orca
@@ -68,22 +74,142 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _fixturesSchemaSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xc4\x55\x5d\x6f\xda\x30\x14\x7d\xcf\xaf\xb8\xca\x53\x5b\xad\x14\xb6\xaa\x0f\x74\x9a\x46\xc1\x54\xd6\x20\x74\xc1\x48\xed\x53\x31\x8e\x0b\x9e\x82\x9d\xda\x4e\x25\xf6\xeb\xe7\x7c\x41\x28\x63\xea\xa4\xac\x8b\x84\x84\x8e\xcf\xfd\x3c\xd7\xbe\x17\x67\x67\x1e\x9c\x81\xd2\x8c\x3e\x1a\xb6\xe2\x6b\xda\x32\xcf\x71\x06\xf5\x55\xb2\xd1\x62\xb9\xb2\xf0\xb1\xdd\xb9\x82\x99\x14\x2f\x5c\x1b\x61\x37\xa0\x9e\x60\x4c\xf5\x26\xa6\x32\x72\xc4\x8c\xdb\x4b\xed\x4a\xe9\x2e\xc0\x0d\x97\x3f\xe8\x5a\xc8\xec\xcf\xf2\x49\x69\x0b\x9f\x17\x25\xf4\x75\x51\x42\x2d\xa6\xd6\x5f\xf2\x08\x9a\x53\xcb\xa3\x2e\x0c\xb5\x80\x09\xb3\xd0\xb9\x84\xce\x55\xb7\xd3\xe9\x7e\x6a\x17\x41\xcf\xdb\x97\xed\xb6\xa3\x5e\x78\xde\x79\x53\x9f\xf3\x04\x48\x9a\x54\x73\xb0\x9a\x4a\x43\x99\x15\x4a\x82\xe1\x2c\xd5\x59\x75\x8b\x0d\x24\x31\x65\x42\x2e\x81\xc6\x31\xf4\x43\xd4\x23\x08\x5c\xad\xd0\x1b\x11\x14\x82\xb1\x2e\xe9\x35\x97\xd6\x64\x9e\x84\x34\x22\xe2\x59\x4b\xe6\x37\xe8\x16\x07\xf3\x9c\x39\xef\x4f\xc6\x63\x4c\xe6\x35\x72\xab\xc1\x0a\xbc\x3c\xd4\xb5\xe7\x95\xea\x55\x49\xa2\x80\x60\xf2\x00\xa4\x77\x33\x42\xd3\x7f\xd0\xb6\x88\xbf\x08\xc6\x0d\x10\xba\x88\x79\x93\xf5\x38\xdf\x83\x70\x72\x57\x64\x0e\x78\x08\xe8\x1e\x4f\xc9\x14\xfc\x32\xa2\xef\x6a\x2d\x6b\x2c\x28\xdb\x03\xef\xc4\x03\xf7\xf9\x22\xf2\x01\x07\x04\xdd\x3a\x85\xee\x42\x3c\xee\x85\x0f\xf0\x0d\x3d\x7c\x28\x4e\x25\x5d\x73\x1f\x08\xba\x27\x10\x4c\xdc\x6f\x36\x1a\xc1\x2c\xc0\xdf\x67\xa8\x24\x88\x84\x46\x91\x2e\x28\x25\x14\xa9\x35\x15\x72\x0f\x32\xfc\x39\xe5\x92\xf1\x5d\xa8\x01\x1a\xf6\x66\x23\x02\xed\x92\xc1\x8a\x91\xf6\x61\xe0\x72\x25\x78\x5c\xf9\x4f\x93\x68\x1f\xf7\x4e\xaf\x1b\x16\x27\x56\x8c\x66\x93\xfc\x9e\xf2\x6c\x63\x1e\x0a\xb4\x3b\x7a\x9b\x44\x75\x05\x8e\x88\x14\x3b\x87\x36\x8d\x5c\xfb\x5d\xa4\x51\x05\x2a\xb9\x3c\x44\x99\xbb\xc9\x7b\xd2\x25\xca\x58\xa6\x22\xbe\x07\x32\x95\x4a\xab\xf7\x89\x4a\x2f\xa9\x14\x3f\xf3\xdc\xfd\xe3\xa3\x20\x95\x7d\xe5\xeb\xff\x29\x9f\xb8\xb7\xea\x3d\x55\xcf\xe3\x1d\x2a\x5e\xc0\x6f\x53\xdb\xa8\x54\x33\xfe\x58\x27\x55\x9a\x97\x0c\x4b\xf5\x92\xdb\x3f\x31\xaa\x09\xab\x73\xca\x23\x9d\x5d\x54\x63\x8f\x9a\x6a\x6e\x12\x37\x9a\xfc\xb5\x9d\x71\x2f\xf5\x4e\xa9\x43\x2b\xf6\x72\xa0\xaf\x1b\x4a\xf7\x24\x6c\xea\xd3\x37\x9c\x84\x08\xdf\x06\x59\xb9\x70\x52\x2b\xf5\xd4\x71\x86\x28\x44\x41\x1f\x4d\xab\xb7\xf4\x24\x6b\xd4\xe9\xef\xec\x76\x0d\xf8\x3b\xbb\x7a\x5b\xf6\x2c\xb7\x37\xb2\xb0\xcd\xe7\x10\xf2\x0d\x52\x5b\x21\x38\x18\xe0\x3e\xce\xb6\x47\xbe\x3e\xa0\x58\x64\x19\xb3\xb9\xc9\x72\xae\x20\x50\x55\x44\xa5\x0f\x16\x2b\x98\x95\x4a\xe3\x08\x16\x6e\xb1\xa6\xb6\x5a\xb0\x76\xc5\xb7\x8b\xb5\xd5\x64\x3e\xbf\x02\x00\x00\xff\xff\xb8\xd9\xff\x5d\x04\x09\x00\x00")
+var _fixturesMigrations0001BaselineSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xc5\x54\x5b\x4f\xdb\x30\x14\x7e\xcf\xaf\x38\xca\x13\xa0\x51\xd2\x0d\xf1\x50\xa6\x69\xa5\x75\x51\xb4\x5e\x58\x9a\x4a\xf0\x44\x5d\xc7\xa4\x9e\x12\x3b\xd8\x0e\xa8\xfb\xf5\x3b\x6e\xd2\x1b\x1d\x13\x93\x3a\x16\x29\x52\x72\xce\x77\xbe\x73\xf9\x8e\x7d\x76\x72\xe2\xc1\x09\x04\x41\xd0\xbc\x9f\x51\xc3\x33\x21\x79\xc3\x3c\x66\xce\xd8\x51\xc5\x42\x8b\x74\x6e\xe1\x63\xd0\xbc\x80\x89\x14\x4f\x5c\x1b\x61\x17\xa0\x1e\x60\x40\xf5\x22\xa3\x32\x41\xa0\xc3\xb6\x4b\x3b\x57\xba\x05\x70\xc5\xe5\x0f\x9a\x0b\xe9\x3e\xd2\x07\xa5\x2d\x7c\x9e\xd5\xa6\xaf\xb3\xda\xd4\x60\x2a\xff\xb2\xcc\xa0\x39\xb5\x3c\x69\x41\x4f\x0b\x18\x31\x0b\xcd\x73\x68\x5e\xb4\x9a\xcd\xd6\xa7\xa0\x4a\x7a\x1a\x9c\x07\x01\x42\xcf\x3c\xef\xf4\x50\x0f\x32\x41\x3c\xe7\x60\xd8\x9c\xe7\xd4\x35\x63\xf1\xef\x41\x68\x63\x41\xf3\x8c\xe3\x18\x9c\x51\x69\x46\x1b\xd0\xa5\x96\xba\xc1\x18\x60\x55\xb1\x30\xe3\xd8\x03\x77\x31\x8e\x28\x17\xa9\xa6\x56\x28\x69\xe0\x99\xa3\x99\x26\x09\x62\x9e\x05\x8e\xa3\xb4\x40\xeb\x24\xf7\xcb\xc9\x29\x09\x48\x96\x21\xc8\x01\x2d\x52\x08\x03\xb5\xa7\xe1\x55\x85\x11\xfc\x5f\x6c\x58\x01\x11\xb4\x28\x32\x81\xa4\x38\x54\x0a\x56\x53\x69\x28\x5b\xfa\x68\xa6\x64\xba\xcc\x05\xc2\xae\x99\x3e\x80\x51\x2f\x2a\xcb\x4b\x6c\x4d\x2a\x0b\x4c\x49\x4b\x91\x67\x7a\x45\xae\xc3\xe1\x14\x7b\x84\x69\x67\x34\x18\x84\xf1\x14\x8c\xc5\xf6\x72\x2e\xad\x69\x1c\x70\xd6\xde\x59\xb5\x61\xd0\x89\x48\x3b\x26\x40\x86\x71\x18\xdf\x41\xdc\xbe\xea\x93\xf1\x3f\x10\x36\xe1\x4f\x82\xa1\x5a\xb1\x1b\xf4\x21\xfb\x40\xee\x6e\x34\xba\xa9\x2a\x87\xb0\x07\xe4\x36\x1c\xc7\x63\xf0\xeb\x8c\xfe\xa5\xe7\xd5\x3d\x56\x90\xb5\xc3\x3b\xf2\x00\x1f\x5f\x24\x3e\x84\xc3\x98\x5c\x93\x08\x6e\xa2\x70\xd0\x8e\xee\xe0\x1b\xb9\xfb\x50\x79\x25\xcd\xb9\x0f\x31\xb9\x8d\x61\x38\xc2\x77\xd2\xef\xc3\x64\x18\x7e\x9f\x90\x1a\x20\x0a\xdc\x2d\x5d\x41\x6a\x53\xa2\x72\x54\x73\xc7\x64\xf8\x63\xc9\x25\xe3\x9b\x54\x5d\xd2\x6b\x4f\xfa\x31\x04\x35\xa2\xde\x63\x1f\xba\x58\x6b\x1c\x0e\x56\xfc\x65\x91\xec\xda\xbd\xe3\xcb\x03\x8b\x93\x29\x56\x6f\xe4\xfb\xc9\xb3\xce\xb9\x2f\xd0\xc6\xf5\x36\x89\xb6\x15\x78\x45\xa4\x0c\x09\x6d\x99\xe0\xf8\x31\x53\x7f\x65\xc4\x63\xba\x6f\x65\x78\x93\xee\x48\x57\x28\x63\x99\x4a\xf8\x8e\x91\xa9\x52\x5a\xbd\x0b\x54\x3a\xa5\x52\xfc\x5c\xd6\xee\xbf\xbe\x0a\x78\xe0\x5f\x70\xfd\x3f\xe5\x0b\x21\xd3\xf7\x54\x7d\x99\x6f\x5f\xf1\xca\xfc\x36\xb5\x8d\x2a\x35\xe3\xf7\xdb\xa0\x95\xe6\x35\xc2\x52\x9d\x72\xfb\x27\xc4\x6a\xc3\xb6\x31\xb5\x4b\xbb\x83\x6a\xec\xab\xa1\x9a\x9b\x02\x57\x93\xbf\x8c\x33\x78\x43\x6f\x94\xda\x8f\x62\x4f\x7b\xfa\xe2\x52\xe2\x95\xb0\xd8\xde\xbe\xde\x28\x22\xe1\xf5\xd0\xb5\x0b\x47\x5b\xad\x1e\x23\xa6\x47\x22\x32\xec\x90\xf1\xea\x2e\x3d\x72\x83\x3a\xfe\x5d\xdc\x66\x00\x7f\x17\xb7\x3d\x96\x9d\xc8\xf5\x89\xac\x62\xdd\x1e\xfe\x02\x83\x81\xd8\xe7\xa2\x08\x00\x00")
 
-func fixturesSchemaSQLBytes() ([]byte, error) {
+func fixturesMigrations0001BaselineSQLBytes() ([]byte, error) {
 	return bindataRead(
-		_fixturesSchemaSQL,
-		"fixtures/schema.sql",
+		_fixturesMigrations0001BaselineSQL,
+		"fixtures/migrations/0001_baseline.sql",
 	)
 }
 
-func fixturesSchemaSQL() (*asset, error) {
-	bytes, err := fixturesSchemaSQLBytes()
+func fixturesMigrations0001BaselineSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0001BaselineSQLBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "fixtures/schema.sql", size: 2308, mode: os.FileMode(420), modTime: time.Unix(1476892086, 0)}
+	info := bindataFileInfo{name: "fixtures/migrations/0001_baseline.sql", size: 2210, mode: os.FileMode(420), modTime: time.Unix(1792198600, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _fixturesMigrations0002PingStatusAndTimingsSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xad\x52\x41\x6b\xc2\x30\x18\xbd\xf7\x57\x3c\x7a\x29\xc8\xa6\xb2\xeb\xd8\xa1\x5b\x3b\x36\xe8\x54\xb4\xb2\xdd\x4a\x6c\x3f\x6d\xa0\x26\x9a\xc4\x15\xff\xfd\x92\xb4\xb8\x21\x03\x3d\x18\xc8\x21\x5f\xde\xf7\xf2\xbe\xbc\x37\x1a\x0c\x02\x0c\x30\x1e\x8f\x1f\x8a\x1d\x17\x9b\x42\x1b\x66\x0e\xba\x60\xa2\x2a\x0c\xdf\xda\x8a\x1e\xea\x7d\x63\x31\xa3\x20\xb8\xbf\xd5\xb2\x4c\x98\x53\x29\x55\xa5\xb1\x66\xbc\xa1\x0a\xee\x71\x8d\x96\x9b\x1a\x0c\x9d\x08\x58\x11\x20\xa5\xa4\xba\x83\xa9\x09\xa5\x14\x82\x4a\xc3\xa5\xc0\x56\x56\x04\xb9\x76\x65\xc7\xb5\x21\x41\x8a\x19\x07\x74\x3d\x0e\xdc\x8b\xef\x41\xd8\xd5\x4c\xd3\xe9\x44\x65\x2d\xa1\x68\x7f\x20\x6d\x86\x37\x1c\x2b\x88\xb3\x3c\x9d\x23\x8f\x9f\xb3\x14\xa1\x1f\x29\x44\x9c\x24\x78\x99\x66\xcb\x8f\x09\xc2\x6e\xb0\x10\x79\xfa\x95\x63\x32\xb5\x7b\x99\x65\x48\xd2\xd7\x78\x99\xe5\x88\x34\x09\x13\x3d\x5e\x64\xf1\x7f\xd2\x91\x5c\x06\xbb\xaf\xba\x16\x5b\x71\xd6\x14\x0d\x33\x24\xca\x63\x88\x79\x1a\x67\x97\x7b\xd6\x5c\x69\x53\xac\x8e\x86\xae\xed\x50\xbb\xf2\xfc\x11\x67\xe2\xcc\xfb\xa5\x7c\x2c\x6c\x20\x56\xb4\x96\x8a\xbc\x5d\x7d\x1c\x5a\x66\x23\x51\xb9\xbb\x96\xec\x8d\xa2\x5d\xc3\xed\xc1\x48\x70\x6f\xeb\x11\x35\xfb\x26\x30\x47\xd6\xf3\x07\xcb\x59\x12\xe7\xbf\x32\x16\x69\xfe\x6b\xc2\x13\xa2\x9e\x23\xc2\xe7\x5b\x3a\xb7\xb0\x93\xac\xf7\xc5\xc9\x9e\x4b\xea\x7c\x16\xcf\xb4\x39\x23\x61\x73\xca\x20\xa8\xfd\x13\x5c\xc7\xa4\xb9\x28\x7d\xa7\xea\xda\xa4\x68\x8e\x16\xd2\x54\x9e\xe9\x5f\xc5\x9d\x87\x56\xaf\x83\x9d\xc4\x76\x55\xa7\xd4\xab\xfc\x01\x18\x75\x0b\xea\xcb\x03\x00\x00")
+
+func fixturesMigrations0002PingStatusAndTimingsSQLBytes() ([]byte, error) {
+	return bindataRead(
+		_fixturesMigrations0002PingStatusAndTimingsSQL,
+		"fixtures/migrations/0002_ping_status_and_timings.sql",
+	)
+}
+
+func fixturesMigrations0002PingStatusAndTimingsSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0002PingStatusAndTimingsSQLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "fixtures/migrations/0002_ping_status_and_timings.sql", size: 971, mode: os.FileMode(420), modTime: time.Unix(1792202419, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _fixturesMigrations0003ClockOffsetsSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xad\x53\x4d\x6f\x82\x40\x10\xbd\xef\xaf\x98\xec\x49\x8d\x55\x92\x1e\x3d\x51\x59\x0d\x29\x62\x4b\x97\x83\x27\x42\x61\x50\x22\xba\x74\x17\x35\xfe\xfb\x2e\x22\x2d\xa4\xb5\x1f\x09\x7b\x9c\x79\xef\xcd\x9b\xc7\x30\x1e\x0c\x08\x0c\xc0\x30\x8c\xfb\x20\xca\x44\xb4\x0d\x44\x92\x28\x2c\xd4\x48\xbd\x65\xba\x33\x26\xe4\xae\xab\xa7\x95\xc0\xc3\x48\xc8\x58\x41\xb1\x41\xb8\xcc\x83\x6a\x1e\x84\xfb\x18\x62\xcc\xc2\xb3\x02\x54\x45\xba\x0b\x0b\x8c\x21\x91\x62\x77\x81\x62\xb4\x11\xa0\xab\xba\x15\xee\x72\x55\x2a\x95\x84\x2d\x62\x5e\x69\x49\x91\x65\xe9\x7e\xfd\xc1\xd5\xb2\x5f\x67\xe8\x1a\x1e\x51\x9e\xa1\x08\xe5\x1a\x8b\x51\x87\xab\x11\xd3\xe1\xcc\x03\x6e\x3e\x38\x0c\x68\xae\xad\x28\x0a\xa6\x65\xc1\x74\xe9\xf8\x0b\x17\x68\x33\x5c\x0a\x1e\x33\x9d\xc9\xaf\x9c\x3d\x16\x27\x21\xb7\xc1\x25\x97\xbf\x92\x12\x21\x4f\xa1\x8c\xff\x47\x92\x65\x2e\x0a\xdb\xa4\x6e\xbf\x7c\xeb\xba\x80\x87\xaf\x19\x76\x99\xff\x54\x7b\xe6\xac\x5e\xb1\x35\x8c\x92\x1e\x01\xfd\x68\x1a\x53\xb0\x5d\xce\xe6\x3a\x8b\x27\xcf\x5e\x98\xde\x0a\x1e\xd9\x6a\x58\x75\x95\x38\xc8\x08\x83\x26\xc8\x5d\x72\x70\x7d\xc7\xb9\x22\xaa\xb3\xf9\x09\x51\x9f\x5f\x15\x61\x2d\xac\x4f\x36\x43\xf5\x49\xb2\xd8\xcc\xf4\x1d\x0e\xc6\x15\x10\x49\x2c\xcf\x9d\x82\xa5\x57\xe0\xf6\x82\x5d\xeb\x87\x3c\xfe\xae\xee\xbb\xf6\xb3\xcf\xa0\xd7\x70\x3c\x6c\x9a\xeb\x57\xb0\xd9\xd2\x63\xf6\xdc\x2d\x37\x6c\x61\xfb\xda\xdb\x8c\x79\xcc\x9d\xb2\x17\xfd\xcb\x1d\xd3\x08\x55\x8f\xde\xe2\x35\x64\x6f\xf2\x48\x7f\x42\xde\x01\xbc\xb1\x91\xae\x49\x04\x00\x00")
+
+func fixturesMigrations0003ClockOffsetsSQLBytes() ([]byte, error) {
+	return bindataRead(
+		_fixturesMigrations0003ClockOffsetsSQL,
+		"fixtures/migrations/0003_clock_offsets.sql",
+	)
+}
+
+func fixturesMigrations0003ClockOffsetsSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0003ClockOffsetsSQLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "fixtures/migrations/0003_clock_offsets.sql", size: 1097, mode: os.FileMode(420), modTime: time.Unix(1792198597, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _fixturesMigrations0004SequencesAndReflectionsSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb5\x53\xc1\x72\x82\x30\x10\xbd\xf3\x15\x3b\x9c\xd4\xb1\xca\xa1\xb7\x9e\xac\x46\x87\x29\xc6\x96\x86\x19\x3d\x31\x11\x56\xa5\x65\x00\x13\x64\x6a\xbf\xbe\xa1\xa0\xc0\x58\x3a\x3d\xd0\x9c\x92\xdd\x97\x7d\xfb\x36\x2f\xe3\xc1\x40\x83\x01\x18\x86\x71\xef\x4a\x3c\x9e\x30\xf2\x50\xba\x3c\xf2\x5d\x81\xbb\x10\xbd\x34\x88\x23\x39\x92\xc7\x50\xa1\xc6\x9a\x76\xd7\xd5\x52\x95\x80\x09\xee\xbd\x4b\x48\x0f\x08\x22\xa7\x96\x29\x28\x62\xb5\x97\x89\x22\x45\xb8\xf6\x03\xf1\x0e\x30\x43\x71\x86\x84\x07\x22\x3f\xf9\x98\x05\x79\x42\xe1\xf3\x4a\x6f\xf1\x49\x44\x3c\x2c\x6a\xa1\x77\x88\x2f\x05\xa5\xda\x78\x18\x64\xe8\xc3\xf6\x0c\xa5\xa4\x58\xc8\x51\x87\x4a\xba\x1d\x4a\x25\x9a\xf1\x6d\x88\x5d\xf6\x39\xb5\xc9\x84\x11\x60\x93\x47\x8b\x80\x7e\x25\xd2\xb5\x9e\x06\x6a\xe9\x81\xaf\x83\x49\x19\x59\x10\x1b\x9e\x6d\x73\x39\xb1\x37\xf0\x44\x36\xc3\x22\x2b\xd5\x8c\x3d\x74\xeb\x20\xba\x62\x40\x1d\xcb\x2a\x11\x29\x17\x7b\x4c\x7f\x43\x94\xaf\x52\xe5\x67\x64\x3e\x71\x2c\x06\xc6\x15\x50\xbc\x7d\x3b\xc2\x13\xc8\x53\x54\x14\x33\xa5\x85\x99\x4b\x52\xc6\x4f\x89\xff\x53\xdc\xa1\xe6\x8b\x43\xa0\x57\x6b\x7f\x58\xef\xb4\x5f\xc0\xe6\x2b\x9b\x98\x0b\x9a\xcb\x6d\x60\xfb\x60\x93\x39\xb1\x09\x9d\x92\xd7\x8b\xe9\x7a\x7a\xdb\xbd\x5a\xd9\xd6\x7b\x5a\xff\xa1\x63\xc7\xd4\x3e\xea\x7f\x7b\xa6\x46\xf5\x57\xd7\x60\xe4\xa3\xd0\x81\x91\x35\xbb\x75\xc3\xf7\xd7\x6c\xcd\x36\xbd\x52\x86\x93\x20\xda\xd7\x2d\x56\xf1\xa4\x37\x9e\x50\x04\xd9\x4d\x30\xe1\xe7\x30\xe6\xbe\x2b\x83\xcf\xca\x66\xdf\xaf\x52\x6a\x35\xe9\x8c\xac\x1b\x5a\xdd\x92\xf4\x43\x87\x15\x6d\x4e\x21\x77\x4b\x21\x71\x58\xf5\xa6\x8a\x7d\x01\x82\xce\x27\x33\x58\x05\x00\x00")
+
+func fixturesMigrations0004SequencesAndReflectionsSQLBytes() ([]byte, error) {
+	return bindataRead(
+		_fixturesMigrations0004SequencesAndReflectionsSQL,
+		"fixtures/migrations/0004_sequences_and_reflections.sql",
+	)
+}
+
+func fixturesMigrations0004SequencesAndReflectionsSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0004SequencesAndReflectionsSQLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "fixtures/migrations/0004_sequences_and_reflections.sql", size: 1368, mode: os.FileMode(420), modTime: time.Unix(1792198597, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _fixturesMigrations0005TransportsAndProbesSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xad\x52\xc1\x6e\x83\x30\x0c\xbd\xf3\x15\x16\x17\x24\xd4\xb5\x5c\x76\xaa\x76\x60\x23\xd2\x26\x65\x5d\x05\x41\xdb\x0d\x51\x30\xa3\x2a\x23\xd4\x49\x57\xf1\xf7\x4b\x60\x65\x9d\x34\x69\x3d\x34\x27\x2b\x7e\xef\xd9\x7e\xf6\xc2\xf7\x1d\xf0\x21\x08\x82\xdb\x4c\x53\xde\xaa\x4e\x92\x56\x59\xde\x96\x59\x47\x72\x83\x6a\xae\xf6\x8d\x41\x2c\x1c\xe7\xe6\x5a\xcf\x28\x41\x8c\x85\xa4\x52\x81\xae\x11\xa6\xba\x60\xca\xc2\x50\x16\x64\x05\xf8\x89\xd4\x43\xb7\x6d\xdf\x67\x23\x6c\xfb\x31\xfc\xdb\x58\xf0\xc4\xaa\xd4\x86\xa0\xea\x7c\x87\xb3\x81\x6a\x33\x86\x5e\xa0\x52\x86\x35\x12\x08\xad\x32\x96\xb0\xe9\x87\x3c\x61\xd5\x60\xa1\x25\xcd\xaf\x38\x90\x13\x72\xc1\x62\x10\xe1\x3d\x67\xe0\xda\x96\x95\x0b\x61\x14\xc1\xc3\x0b\x4f\x9f\x57\xe0\x4e\x23\xba\x20\xd8\x9b\x58\xfe\x4b\x18\x5c\xb8\x14\xac\x1b\x95\x4d\x56\xb8\x10\xb3\x90\x5f\x54\xe1\xdb\xa8\x4b\x19\x2d\xea\xa3\xa4\x5d\x46\x5a\x9f\x28\x76\x09\x6b\x8b\x34\xbe\xda\x85\x5a\x9f\xb1\x92\x84\xa7\x5d\x98\x0b\x1a\x57\x33\xdd\x16\x1c\xd1\xa4\xf3\xd2\x62\xc7\xb0\x69\xe0\xd0\xe6\xd4\x5b\x31\x2c\x6a\x69\xb4\xf6\x07\x54\x5a\x39\xe9\x3a\x0a\xc5\x4f\x33\x09\x13\x93\x33\x77\xe0\x59\xac\xb7\xfc\x13\x74\xe6\xb7\x01\x0e\xea\x1e\xbc\x3e\xb2\x98\xfd\xca\x3d\x25\xb0\x4a\xb9\x99\xe3\x0b\xc1\x53\x7f\x4d\x07\x03\x00\x00")
+
+func fixturesMigrations0005TransportsAndProbesSQLBytes() ([]byte, error) {
+	return bindataRead(
+		_fixturesMigrations0005TransportsAndProbesSQL,
+		"fixtures/migrations/0005_transports_and_probes.sql",
+	)
+}
+
+func fixturesMigrations0005TransportsAndProbesSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0005TransportsAndProbesSQLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "fixtures/migrations/0005_transports_and_probes.sql", size: 775, mode: os.FileMode(420), modTime: time.Unix(1792202372, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _fixturesMigrations0006PayloadsSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xad\x50\xcb\x6a\xc3\x30\x10\xbc\xeb\x2b\x16\x1f\x4d\x1e\x3e\xf5\x92\x93\x52\xab\xa5\xa0\xca\x10\xe4\x73\x30\xf2\xa6\x16\xb8\x92\xa3\x75\x5b\x92\xaf\xaf\xac\xa6\x85\xd2\x83\x29\x78\x6f\x33\xcc\x0c\x3b\xb3\xcd\x73\x06\x39\x14\x45\x71\x77\x1c\x9a\x4b\xef\x9b\x96\x36\x74\xee\x23\xb9\x65\x6c\xbd\xd4\xc5\x24\x38\xa0\xf1\xa1\x25\x18\x3b\x04\xb2\x57\x24\xf0\xa7\x04\xd0\x74\x1e\x02\x9e\xdf\x90\x46\x82\xc6\xb5\x11\x0c\xbd\x45\x5a\x25\xf0\xd1\x61\x54\x85\x1f\xe9\x94\x65\x5d\x82\x93\xee\x02\xaf\xcd\x68\x3a\x6c\x6f\x4c\x8a\xd9\x2c\xf8\x3a\xe3\x52\x8b\x03\x68\xbe\x97\x02\xb2\xc1\xba\x17\xca\x80\x97\x25\xdc\x57\xb2\x7e\x56\x91\xfa\xda\xed\x38\x95\xca\xe0\x49\x69\xf1\x18\xf5\xaa\xd2\xa0\x6a\x29\xa1\x14\x0f\xbc\x96\x1a\x8a\xdd\x6c\x52\xea\xb3\x40\xce\xf7\x47\x69\x99\x0c\xf6\x55\x25\x05\x57\xf3\x3e\x9a\xd6\x73\x06\xff\x6d\x0c\x68\xd0\xbe\x63\xf8\x63\xfc\xed\x0c\x78\xea\xd1\x8c\xd6\xbb\xf9\xe2\x3b\xf6\x09\x7b\x58\xea\x02\x9c\x02\x00\x00")
+
+func fixturesMigrations0006PayloadsSQLBytes() ([]byte, error) {
+	return bindataRead(
+		_fixturesMigrations0006PayloadsSQL,
+		"fixtures/migrations/0006_payloads.sql",
+	)
+}
+
+func fixturesMigrations0006PayloadsSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0006PayloadsSQLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "fixtures/migrations/0006_payloads.sql", size: 668, mode: os.FileMode(420), modTime: time.Unix(1792198597, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _fixturesMigrations0007PingSentIndexSQL = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xd3\xd7\xd2\xe2\x52\xd0\x52\x30\x30\x30\x30\x8f\x2f\xc8\xcc\x4b\x8f\x2f\x4e\xcd\x2b\x89\xcf\xcc\x4b\x49\xad\xd0\x2b\x2e\xcc\x01\xca\xe9\x73\x71\xe9\x52\x0b\x00\x4d\x52\xf0\x04\x99\x9d\x5a\xac\x50\x92\x91\xaa\x00\xb2\x4c\xa1\x24\x33\x17\xc8\xcd\x4f\x03\x8b\x80\xdc\x50\xac\xa3\x50\x9e\x91\x99\x9c\xa1\x90\x94\x5f\x9a\x97\x02\x16\x06\xa9\x51\x28\x4a\xcc\x4b\x87\xab\x04\x19\x55\x58\x9a\x5a\x94\x89\xa6\x57\x21\x39\x3f\x37\x37\x31\x2f\x45\x8f\x8a\xae\xe6\x72\x0e\x72\x75\x0c\x71\x55\xf0\xf4\x73\x71\x8d\x50\x50\x02\xdb\x03\x0d\xa8\x94\x0a\x25\x05\x7f\x3f\xa8\x98\x92\x82\x86\x12\x48\x58\x49\xd3\x9a\x0b\x00\xa5\x58\x5f\x58\x59\x01\x00\x00")
+
+func fixturesMigrations0007PingSentIndexSQLBytes() ([]byte, error) {
+	return bindataRead(
+		_fixturesMigrations0007PingSentIndexSQL,
+		"fixtures/migrations/0007_ping_sent_index.sql",
+	)
+}
+
+func fixturesMigrations0007PingSentIndexSQL() (*asset, error) {
+	bytes, err := fixturesMigrations0007PingSentIndexSQLBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "fixtures/migrations/0007_ping_sent_index.sql", size: 345, mode: os.FileMode(420), modTime: time.Unix(1792201316, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -140,7 +266,13 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"fixtures/schema.sql": fixturesSchemaSQL,
+	"fixtures/migrations/0001_baseline.sql": fixturesMigrations0001BaselineSQL,
+	"fixtures/migrations/0002_ping_status_and_timings.sql": fixturesMigrations0002PingStatusAndTimingsSQL,
+	"fixtures/migrations/0003_clock_offsets.sql": fixturesMigrations0003ClockOffsetsSQL,
+	"fixtures/migrations/0004_sequences_and_reflections.sql": fixturesMigrations0004SequencesAndReflectionsSQL,
+	"fixtures/migrations/0005_transports_and_probes.sql": fixturesMigrations0005TransportsAndProbesSQL,
+	"fixtures/migrations/0006_payloads.sql": fixturesMigrations0006PayloadsSQL,
+	"fixtures/migrations/0007_ping_sent_index.sql": fixturesMigrations0007PingSentIndexSQL,
 }
 
 // AssetDir returns the file names below a certain
@@ -185,7 +317,15 @@ type bintree struct {
 
 var _bintree = &bintree{nil, map[string]*bintree{
 	"fixtures": &bintree{nil, map[string]*bintree{
-		"schema.sql": &bintree{fixturesSchemaSQL, map[string]*bintree{}},
+		"migrations": &bintree{nil, map[string]*bintree{
+			"0001_baseline.sql": &bintree{fixturesMigrations0001BaselineSQL, map[string]*bintree{}},
+			"0002_ping_status_and_timings.sql": &bintree{fixturesMigrations0002PingStatusAndTimingsSQL, map[string]*bintree{}},
+			"0003_clock_offsets.sql": &bintree{fixturesMigrations0003ClockOffsetsSQL, map[string]*bintree{}},
+			"0004_sequences_and_reflections.sql": &bintree{fixturesMigrations0004SequencesAndReflectionsSQL, map[string]*bintree{}},
+			"0005_transports_and_probes.sql": &bintree{fixturesMigrations0005TransportsAndProbesSQL, map[string]*bintree{}},
+			"0006_payloads.sql": &bintree{fixturesMigrations0006PayloadsSQL, map[string]*bintree{}},
+			"0007_ping_sent_index.sql": &bintree{fixturesMigrations0007PingSentIndexSQL, map[string]*bintree{}},
+		}},
 	}},
 }}
 
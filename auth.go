@@ -0,0 +1,67 @@
+package orca
+
+import (
+	"time"
+
+	"github.com/bbengfort/orca/echo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Sender policies specify how the reflector handles echo requests from
+// senders that are not in its devices table or its sender keys. Allowed
+// senders are added to the devices table, rejected senders are refused with
+// a PermissionDenied status, and transient senders are replied to without
+// being stored in the devices and sequences tables.
+const (
+	AllowSenders     = "allow"
+	RejectSenders    = "reject"
+	TransientSenders = "transient"
+	DefaultSenders   = AllowSenders
+)
+
+// Helper function that checks the sender of an echo request against the
+// sender policy and verifies the HMAC of the request if the reflector has a
+// pre-shared key for the sender. Signed requests must also have been sent
+// within their TTL (at most the Timeout) of now, so that they cannot be
+// replayed. Returns a gRPC status error if the request is rejected.
+func (app *App) authenticate(in *echo.Request, sender *echo.Device) error {
+	name := sender.Name
+
+	app.mu.RLock()
+	_, known := app.senders[name]
+	app.mu.RUnlock()
+
+	key, keyed := app.Config.SenderKeys[name]
+	if !known && !keyed && app.Config.Senders == RejectSenders {
+		return grpc.Errorf(codes.PermissionDenied, "unknown sender %q", name)
+	}
+
+	if keyed && !in.Verify([]byte(key)) {
+		return grpc.Errorf(codes.Unauthenticated, "could not authenticate the request from %q", name)
+	}
+
+	if keyed {
+		window := time.Duration(in.TTL) * time.Second
+		if window <= 0 || window > Timeout {
+			window = Timeout
+		}
+
+		if !in.Fresh(time.Now(), window) {
+			return grpc.Errorf(codes.Unauthenticated, "the request from %q was not sent within %s of now", name, window)
+		}
+	}
+
+	return nil
+}
+
+// Helper function that checks if the reflector has a pre-shared key for the
+// sender, in which case its requests are only replied to if they verify.
+func (app *App) keyed(sender *echo.Device) bool {
+	if sender == nil {
+		return false
+	}
+
+	_, keyed := app.Config.SenderKeys[sender.Name]
+	return keyed
+}
@@ -0,0 +1,177 @@
+package orca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CertValidity is how long the generated certificates are valid for.
+const CertValidity = time.Duration(2*365*24) * time.Hour
+
+// CAName is the name of the certificate and key of the certificate authority
+// in the certificates directory, so it cannot be used as a device name.
+const CAName = "ca"
+
+// CertPaths returns the paths of the certificate and key of the named device
+// (or the CA) in the certificates directory.
+func CertPaths(dir, name string) (cert, key string) {
+	return filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+}
+
+// GenerateCA creates a self-signed certificate authority in the directory
+// that signs the certificates of the devices. An existing CA is not replaced
+// so that the certificates it signed remain valid; returns true if the CA
+// was created.
+func GenerateCA(dir string) (bool, error) {
+	certPath, keyPath := CertPaths(dir, CAName)
+	if _, err := os.Stat(certPath); err == nil {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, err
+	}
+
+	template, err := newCertTemplate("Orca CA")
+	if err != nil {
+		return false, err
+	}
+
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := writeKeyPair(certPath, keyPath, der, key); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GenerateCert creates a certificate and key for the named device signed by
+// the CA in the directory. The hosts are the IP addresses and domain names
+// the device is reached at. The certificate is valid for both server and
+// client authentication since a device can be a reflector and a generator.
+func GenerateCert(dir, name string, hosts []string) (certPath, keyPath string, err error) {
+	if name == CAName {
+		return "", "", fmt.Errorf("%q is reserved for the certificate authority", CAName)
+	}
+
+	if len(hosts) == 0 {
+		return "", "", errors.New("A certificate requires at least one IP address or domain")
+	}
+
+	// Load the CA to sign the certificate with
+	ca, err := tls.LoadX509KeyPair(CertPaths(dir, CAName))
+	if err != nil {
+		return "", "", err
+	}
+
+	parent, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return "", "", err
+	}
+
+	// Create the certificate of the device
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	template, err := newCertTemplate(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	certPath, keyPath = CertPaths(dir, name)
+	if err := writeKeyPair(certPath, keyPath, der, key); err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, nil
+}
+
+// Helper function that creates a certificate template with a random serial
+// number that is valid from now for the CertValidity.
+func newCertTemplate(name string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Orca"}, CommonName: name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(CertValidity),
+	}, nil
+}
+
+// Helper function that writes the PEM encoded certificate and private key,
+// only allowing the owner to read the key.
+func writeKeyPair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	data, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+
+	return writePEM(keyPath, "EC PRIVATE KEY", data, 0600)
+}
+
+// Helper function that writes a single PEM block to a file.
+func writePEM(path, kind string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if err := pem.Encode(f, &pem.Block{Type: kind, Bytes: data}); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,149 @@
+package orca
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OffsetWeight is the weight given to each new sample in the rolling clock
+// offset estimate (an exponentially weighted moving average).
+const OffsetWeight = 0.125
+
+// EstimateOffset computes the NTP-style clock offset and round trip network
+// delay from the four timestamps of an echo: t1 the request was sent by the
+// generator, t2 the request was received by the reflector, t3 the reply was
+// transmitted by the reflector, and t4 the reply was received by the
+// generator. The offset is positive if the reflector clock is ahead.
+func EstimateOffset(t1, t2, t3, t4 time.Time) (offset, delay time.Duration) {
+	offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay = t4.Sub(t1) - t3.Sub(t2)
+	return offset, delay
+}
+
+// OneWayDelays computes the forward (generator to reflector) and reverse
+// (reflector to generator) delays of an echo given an estimate of the clock
+// offset. Note that if the offset is estimated from this echo alone, both
+// delays are half of the round trip, so a rolling estimate should be used.
+func OneWayDelays(t1, t2, t3, t4 time.Time, offset time.Duration) (forward, reverse time.Duration) {
+	forward = t2.Sub(t1) - offset
+	reverse = t4.Sub(t3) + offset
+	return forward, reverse
+}
+
+// ClockOffset is a rolling estimate of the clock offset between a source and a
+// target device, stored in the clock_offsets table. The offset is the
+// average of the per-ping offsets in milliseconds.
+type ClockOffset struct {
+	SourceID int64   // The device that sent the echo requests
+	TargetID int64   // The device that reflected the echo requests
+	Offset   float64 // The estimated offset of the target clock in milliseconds
+	Samples  int64   // The number of pings the estimate is computed from
+	ModelMeta
+}
+
+// Get a clock offset from the database by ID and populate the struct fields.
+func (o *ClockOffset) Get(id int64, db Executor) error {
+	row := db.QueryRow("SELECT * FROM clock_offsets WHERE id = $1", id)
+	err := row.Scan(&o.ID, &o.SourceID, &o.TargetID, &o.Offset, &o.Samples, &o.Created, &o.Updated)
+
+	return err
+}
+
+// GetByPair a clock offset from the database by the source and target device
+// and populate the struct fields.
+func (o *ClockOffset) GetByPair(source, target *Device, db Executor) error {
+	query := "SELECT * FROM clock_offsets WHERE source_id = $1 AND target_id = $2"
+	row := db.QueryRow(query, source.ID, target.ID)
+	err := row.Scan(&o.ID, &o.SourceID, &o.TargetID, &o.Offset, &o.Samples, &o.Created, &o.Updated)
+
+	return err
+}
+
+// Save a clock offset struct to the database. This function checks if the
+// offset has an ID or not. If it does, it will execute a SQL UPDATE,
+// otherwise it will execute a SQL INSERT. Returns a boolean if the offset
+// was inserted. This method handles setting the meta timestamps as well.
+// Offsets with an ID that is not in the database (e.g. because the batch
+// that inserted them was rolled back) are inserted with their ID.
+func (o *ClockOffset) Save(db Executor) (bool, error) {
+	if o.ID > 0 {
+		// This is the UPDATE method so return false.
+		o.Updated = time.Now()
+
+		query := "UPDATE clock_offsets SET source_id=$1, target_id=$2, estimate=$3, samples=$4, updated=$5 WHERE id = $6"
+		res, err := db.Exec(query, o.SourceID, o.TargetID, o.Offset, o.Samples, o.Updated, o.ID)
+		if err != nil {
+			return false, err
+		}
+
+		// If the estimate was updated we're done, otherwise its insert was
+		// rolled back so insert it again with its ID
+		if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+			return false, err
+		}
+	}
+
+	// This is the INSERT method, so return true
+	o.Created = time.Now()
+	o.Updated = time.Now()
+
+	// A NULL id is assigned by sqlite3
+	id := sql.NullInt64{Int64: o.ID, Valid: o.ID > 0}
+
+	query := "INSERT INTO clock_offsets (id, source_id, target_id, estimate, samples, created, updated) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	res, err := db.Exec(query, id, o.SourceID, o.TargetID, o.Offset, o.Samples, o.Created, o.Updated)
+	if err != nil {
+		return false, err
+	}
+
+	// Look up the last inserted ID from sqlite3
+	oid, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+
+	// Store the ID and return
+	o.ID = oid
+	return true, err
+}
+
+// Delete a clock offset from the database. Returns true if the number of
+// rows affected is 1 or false otherwise.
+func (o *ClockOffset) Delete(db Executor) (bool, error) {
+	return deleteFromDatabase(db, "clock_offsets", o.ID)
+}
+
+// Exists checks if the specified clock offset is in the database.
+func (o *ClockOffset) Exists(id int64, db Executor) (bool, error) {
+	if id == 0 {
+		id = o.ID
+	}
+	return existsInDatabase(db, "clock_offsets", id)
+}
+
+// Update the rolling estimate with the offset from a single ping. The first
+// sample is used as the estimate, after which each sample is weighted by the
+// OffsetWeight. Returns the updated estimate as a duration.
+func (o *ClockOffset) Update(offset time.Duration) time.Duration {
+	sample := offset.Seconds() * 1000.0
+	if o.Samples == 0 {
+		o.Offset = sample
+	} else {
+		o.Offset = OffsetWeight*sample + (1-OffsetWeight)*o.Offset
+	}
+
+	o.Samples++
+	return o.Estimate()
+}
+
+// Estimate returns the rolling clock offset estimate as a duration.
+func (o *ClockOffset) Estimate() time.Duration {
+	return time.Duration(o.Offset * float64(time.Millisecond))
+}
+
+// String returns a pretty representation of the clock offset
+func (o *ClockOffset) String() string {
+	output := "%d -> %d offset=%0.3fms samples=%d"
+	return fmt.Sprintf(output, o.SourceID, o.TargetID, o.Offset, o.Samples)
+}
@@ -0,0 +1,63 @@
+package orca_test
+
+import (
+	"time"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clock", func() {
+
+	var t1, t2, t3, t4 time.Time
+
+	BeforeEach(func() {
+		// The reflector clock is 5ms ahead, the forward delay is 3ms, the
+		// reflector takes 1ms to reply and the reverse delay is 7ms.
+		t1 = time.Date(2016, 10, 21, 12, 0, 0, 0, time.UTC)
+		t2 = t1.Add(8 * time.Millisecond)
+		t3 = t2.Add(1 * time.Millisecond)
+		t4 = t1.Add(11 * time.Millisecond)
+	})
+
+	It("should estimate the clock offset and network delay", func() {
+		offset, delay := EstimateOffset(t1, t2, t3, t4)
+		Ω(delay).Should(Equal(10 * time.Millisecond))
+
+		// A single echo cannot observe asymmetry, so the offset is off by
+		// half of the difference between the forward and reverse delays.
+		Ω(offset).Should(Equal(3 * time.Millisecond))
+	})
+
+	It("should compute one-way delays from an offset estimate", func() {
+		forward, reverse := OneWayDelays(t1, t2, t3, t4, 5*time.Millisecond)
+		Ω(forward).Should(Equal(3 * time.Millisecond))
+		Ω(reverse).Should(Equal(7 * time.Millisecond))
+	})
+
+	Describe("ClockOffset", func() {
+
+		It("should implement the Model interface", func() {
+			var iface interface{} = &ClockOffset{}
+			_, ok := iface.(Model)
+			Ω(ok).Should(BeTrue())
+		})
+
+		It("should use the first sample as the estimate", func() {
+			estimate := new(ClockOffset)
+			Ω(estimate.Update(4 * time.Millisecond)).Should(Equal(4 * time.Millisecond))
+			Ω(estimate.Samples).Should(BeEquivalentTo(1))
+		})
+
+		It("should weight later samples by the offset weight", func() {
+			estimate := &ClockOffset{Offset: 4.0, Samples: 1}
+			estimate.Update(12 * time.Millisecond)
+			Ω(estimate.Offset).Should(BeNumerically("~", 4.0+OffsetWeight*8.0))
+			Ω(estimate.Samples).Should(BeEquivalentTo(2))
+		})
+
+	})
+
+})
@@ -5,11 +5,18 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/bbengfort/orca"
 	"github.com/joho/godotenv"
 	"github.com/urfave/cli"
+	"golang.org/x/net/context"
 )
 
 var orcaApp *orca.App
@@ -66,6 +73,21 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "migrate",
+			Usage:  "migrate the database to the latest version of the schema",
+			Action: migrateDatabase,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "t, to",
+					Usage: "specify the version to migrate the database to",
+				},
+				cli.BoolFlag{
+					Name:  "n, dry-run",
+					Usage: "print the migrations without applying them",
+				},
+			},
+		},
 		{
 			Name:   "devices",
 			Usage:  "manage the list of remote devices",
@@ -82,9 +104,87 @@ func main() {
 			},
 		},
 		{
-			Name:   "test",
-			Usage:  "debugging test functionality",
-			Action: test,
+			Name:   "sequences",
+			Usage:  "report loss and reordering of the pings between devices",
+			Action: reportSequences,
+		},
+		{
+			Name:      "losses",
+			Usage:     "report the direction of lost pings using a reflector database",
+			ArgsUsage: "reflector.db",
+			Action:    reportLosses,
+		},
+		{
+			Name:      "certs",
+			Usage:     "generate a certificate authority and device certificates for TLS",
+			ArgsUsage: "name [name ...]",
+			Action:    generateCerts,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "d, dir",
+					Usage: "specify the directory of the certificates",
+				},
+				cli.StringSliceFlag{
+					Name:  "H, host",
+					Usage: "an IP address or domain of the device (repeatable)",
+				},
+			},
+		},
+		{
+			Name:   "pings",
+			Usage:  "query the recorded pings and export them",
+			Action: queryPings,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "s, source",
+					Usage: "only pings from the source device with this name",
+				},
+				cli.StringFlag{
+					Name:  "t, target",
+					Usage: "only pings to the target device with this name",
+				},
+				cli.StringFlag{
+					Name:  "l, location",
+					Usage: "only pings sent from this IP address, city or country",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "only pings sent at or after a time (RFC3339) or a duration ago (e.g. 24h)",
+				},
+				cli.StringFlag{
+					Name:  "until",
+					Usage: "only pings sent before a time (RFC3339) or a duration ago (e.g. 1h)",
+				},
+				cli.StringFlag{
+					Name:  "status",
+					Usage: "only pings with this status (sent, replied, timeout, refused, cancelled, error)",
+				},
+				cli.StringFlag{
+					Name:  "p, probe",
+					Usage: "only pings of this probe (echo, tcp, or dns)",
+				},
+				cli.Float64Flag{
+					Name:  "min-latency",
+					Usage: "only pings with at least this latency in milliseconds",
+				},
+				cli.Float64Flag{
+					Name:  "max-latency",
+					Usage: "only pings with at most this latency in milliseconds",
+				},
+				cli.StringFlag{
+					Name:  "f, format",
+					Value: orca.DefaultFormat,
+					Usage: "output format: table, csv, jsonl or protobuf",
+				},
+				cli.IntFlag{
+					Name:  "n, limit",
+					Usage: "the maximum number of pings to print (0 for all)",
+				},
+				cli.IntFlag{
+					Name:  "o, offset",
+					Usage: "the number of matching pings to skip",
+				},
+			},
 		},
 	}
 
@@ -121,8 +221,10 @@ func initOrca(c *cli.Context) error {
 }
 
 func startReflector(c *cli.Context) error {
+	ctx, cancel := signalContext()
+	defer cancel()
 
-	if err := orcaApp.Reflect(); err != nil {
+	if err := orcaApp.Reflect(ctx); err != nil {
 		return cli.NewExitError(err.Error(), 2)
 	}
 
@@ -130,14 +232,55 @@ func startReflector(c *cli.Context) error {
 }
 
 func startGenerator(c *cli.Context) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// Reload the configuration and devices on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				log.Println("received hangup, reloading configuration and devices")
+				orcaApp.Reload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	if err := orcaApp.Generate(); err != nil {
+	if err := orcaApp.Generate(ctx); err != nil {
 		return cli.NewExitError(err.Error(), 3)
 	}
 
 	return nil
 }
 
+// Returns a context that is cancelled when the process receives SIGINT or
+// SIGTERM so that the daemons can shut down gracefully and exit with 0. A
+// second signal is not handled, so it kills the process immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(sigs)
+		select {
+		case sig := <-sigs:
+			log.Printf("received %s, shutting down\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
 func printConfig(c *cli.Context) error {
 	// Print the configuration and exit
 	fmt.Println(orcaApp.Config.String())
@@ -189,6 +332,40 @@ func createDatabase(c *cli.Context) error {
 	return nil
 }
 
+func migrateDatabase(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+
+	version, err := orcaApp.SchemaVersion()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 9)
+	}
+
+	migrations, err := orcaApp.Migrate(c.Int("to"), dryRun)
+	for _, m := range migrations {
+		if dryRun {
+			fmt.Printf("would apply %s\n", m)
+		} else {
+			fmt.Printf("applied %s\n", m)
+		}
+	}
+
+	if err != nil {
+		return cli.NewExitError(err.Error(), 9)
+	}
+
+	if len(migrations) == 0 {
+		fmt.Printf("%s is already at version %d\n", orcaApp.Config.DBPath, version)
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("%s is at version %d\n", orcaApp.Config.DBPath, version)
+	} else {
+		fmt.Printf("migrated %s from version %d to %d\n", orcaApp.Config.DBPath, version, migrations[len(migrations)-1].Version)
+	}
+	return nil
+}
+
 func manageDevices(c *cli.Context) error {
 
 	// Return the list of devices if specified
@@ -199,7 +376,7 @@ func manageDevices(c *cli.Context) error {
 		}
 
 		for i, d := range devices {
-			fmt.Printf("%d. %s seq=%d\n", i+1, d.String(), d.Sequence)
+			fmt.Printf("%d. %s\n", i+1, d.String())
 		}
 
 		return nil
@@ -208,20 +385,21 @@ func manageDevices(c *cli.Context) error {
 	// Interactively add a device to the database
 	if c.Bool("add") {
 
-		// Create the new empty device
-		device := new(orca.Device)
-
 		// Get the name from the command line
-		device.Name = readInput("Enter device name", "")
+		name := readInput("Enter device name", "")
 
-		// Fetch the ID by name if it exists, otherwise ignore
-		device.GetByName(device.Name, orcaApp.GetDB())
+		// Fetch the device by name if it exists, otherwise create it
+		store := orcaApp.GetStore()
+		device, err := store.GetDeviceByName(name)
+		if err != nil {
+			device = &orca.Device{Name: name}
+		}
 
 		// Get the final information for the device
 		device.IPAddr = readInput("Enter device IP address", device.IPAddr)
 		device.Domain = readInput("Enter device domain", device.Domain)
 
-		if _, err := device.Save(orcaApp.GetDB()); err != nil {
+		if _, err := store.SaveDevice(device); err != nil {
 			return cli.NewExitError(err.Error(), 5)
 		}
 
@@ -231,15 +409,145 @@ func manageDevices(c *cli.Context) error {
 	return cli.NewExitError("Specify a device management command", 1)
 }
 
-func test(c *cli.Context) error {
+func reportSequences(c *cli.Context) error {
+	pings, err := orcaApp.FetchSequences()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 6)
+	}
 
-	db := orcaApp.GetDB()
+	for _, report := range orca.AnalyzeSequences(pings) {
+		fmt.Println(report.String())
+	}
 
-	ping := new(orca.Ping)
-	if err := ping.Get(1, db); err != nil {
-		return cli.NewExitError(err.Error(), 42)
+	return nil
+}
+
+func reportLosses(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.NewExitError("Specify the path to a reflector database", 7)
+	}
+
+	losses, err := orcaApp.FetchLosses(c.Args().First())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 7)
 	}
 
-	fmt.Println(ping.String())
+	counts := make(map[string]int)
+	for _, loss := range losses {
+		counts[loss.Direction]++
+		fmt.Println(loss.String())
+	}
+
+	fmt.Printf(
+		"%d lost pings: %d forward, %d reverse\n",
+		len(losses), counts[orca.LostForward], counts[orca.LostReverse],
+	)
 	return nil
 }
+
+func generateCerts(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return cli.NewExitError("Specify the names of the devices to create certificates for", 8)
+	}
+
+	// Store the certificates next to the database unless specified
+	dir := c.String("dir")
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(orcaApp.Config.DBPath), "certs")
+	}
+
+	created, err := orca.GenerateCA(dir)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 8)
+	}
+
+	caCert, _ := orca.CertPaths(dir, orca.CAName)
+	if created {
+		fmt.Printf("Created certificate authority at %s\n", caCert)
+	}
+
+	for _, name := range c.Args() {
+		hosts := c.StringSlice("host")
+
+		// Add the address and domain of the device if it is in the database
+		if device, err := orcaApp.GetStore().GetDeviceByName(name); err == nil {
+			if host, _, err := net.SplitHostPort(device.IPAddr); err == nil && host != "" {
+				hosts = append(hosts, host)
+			}
+
+			if device.Domain != "" {
+				hosts = append(hosts, device.Domain)
+			}
+		}
+
+		cert, key, err := orca.GenerateCert(dir, name, hosts)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("%s: %s", name, err), 8)
+		}
+
+		fmt.Printf("Created certificate for %s at %s and %s\n", name, cert, key)
+	}
+
+	return nil
+}
+
+func queryPings(c *cli.Context) error {
+	query := &orca.PingQuery{
+		Source:     c.String("source"),
+		Target:     c.String("target"),
+		Location:   c.String("location"),
+		Probe:      c.String("probe"),
+		Status:     c.String("status"),
+		MinLatency: c.Float64("min-latency"),
+		MaxLatency: c.Float64("max-latency"),
+		Limit:      c.Int("limit"),
+		Offset:     c.Int("offset"),
+	}
+
+	if query.Limit < 0 || query.Offset < 0 {
+		return cli.NewExitError("Specify a limit and offset that are not negative", 10)
+	}
+
+	var err error
+	if query.Since, err = parseTime(c.String("since")); err != nil {
+		return cli.NewExitError(err.Error(), 10)
+	}
+
+	if query.Until, err = parseTime(c.String("until")); err != nil {
+		return cli.NewExitError(err.Error(), 10)
+	}
+
+	writer, err := orca.NewPingWriter(os.Stdout, c.String("format"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 10)
+	}
+
+	// Write the pings as they are read rather than loading all of them
+	if err := orcaApp.GetStore().EachPing(query, writer.Write); err != nil {
+		return cli.NewExitError(err.Error(), 10)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return cli.NewExitError(err.Error(), 10)
+	}
+
+	return nil
+}
+
+// Parses a time on the command line, which is either an RFC3339 timestamp or
+// a duration before now. An empty value is the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return ts, fmt.Errorf("Could not parse %q as an RFC3339 time or a duration", value)
+	}
+	return ts, nil
+}
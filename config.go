@@ -1,11 +1,13 @@
 package orca
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -29,15 +31,29 @@ var ConfigPath = []string{
 	filepath.Join(getCwd(), "orca.yml"),
 }
 
-// LoadConfig the configuration from the ConfigPath
-func LoadConfig() *Config {
+// LoadConfig the configuration from the files in the ConfigPath that exist,
+// in order, so that later files override earlier ones. An invalid
+// configuration is returned as an error rather than ignored.
+func LoadConfig() (*Config, error) {
 	config := new(Config)
 
 	for _, path := range ConfigPath {
-		config.Read(path)
+		if err := config.Read(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("Could not load the configuration from %s: %s", path, err)
+		}
+	}
+
+	// If no configuration files exist, use the defaults
+	if len(config.paths) == 0 {
+		if err := config.Parse(nil); err != nil {
+			return nil, err
+		}
 	}
 
-	return config
+	return config, nil
 }
 
 // MaxMindConfig specifies the MaxMind API credentials
@@ -49,13 +65,30 @@ type MaxMindConfig struct {
 // Config is read from a YAML file and defines the current configuration of
 // the project and can be exported as such.
 type Config struct {
-	Debug    bool   `yaml:"debug"`    // Print out log messages or not
-	Name     string `yaml:"name"`     // The name of hte local device
-	Addr     string `yaml:"addr"`     // The listen address of the local device
-	Domain   string `yaml:"domain"`   // The domain name of the local device
-	Interval int64  `yaml:"interval"` // The wait in seconds between pings to reflectors
-	DBPath   string `yaml:"dbpath"`   // The path to the SQLite3 database
-	MaxMind  *MaxMindConfig
+	Debug         bool     `yaml:"debug"`          // Print out log messages or not
+	Name          string   `yaml:"name"`           // The name of hte local device
+	Addr          string   `yaml:"addr"`           // The listen address of the local device
+	Domain        string   `yaml:"domain"`         // The domain name of the local device
+	Interval      int64    `yaml:"interval"`       // The wait in seconds between pings to reflectors
+	Concurrency   int      `yaml:"concurrency"`    // The maximum number of pings in flight at once
+	Mode          string   `yaml:"mode"`           // Dial every ping (cold) or reuse connections (warm)
+	Probes        []string `yaml:"probes"`         // The probes sent to every device each round
+	Transport     string   `yaml:"transport"`      // Send pings as unary RPCs or on a stream
+	UDP           bool     `yaml:"udp"`            // Also ping every device over UDP each round
+	HTTP          bool     `yaml:"http"`           // Also ping every device over HTTP/JSON each round
+	HTTPPort      int      `yaml:"http_port"`      // The port of the HTTP/JSON echo endpoint
+	FlushInterval int64    `yaml:"flush_interval"` // The wait in seconds between batched database writes
+	QueueSize     int      `yaml:"queue_size"`     // The maximum number of pending writes before writers block
+	Key           string   `yaml:"key"`            // The pre-shared key the local device signs its requests with
+	Senders       string   `yaml:"senders"`        // The policy for unknown senders (allow, reject, or transient)
+	Store         string   `yaml:"store"`          // Where the models are stored (sqlite or memory)
+	DBPath        string   `yaml:"dbpath"`         // The path to the SQLite3 database
+	MaxMind       *MaxMindConfig
+	Payload       *PayloadConfig    `yaml:"payload"`     // The sizes of the payloads of the echo requests
+	Reply         *ReplyConfig      `yaml:"reply"`       // The sizes of the payloads of the echo replies
+	TLS           *TLSConfig        `yaml:"tls"`         // Certificates that secure the gRPC service
+	SenderKeys    map[string]string `yaml:"sender_keys"` // The pre-shared keys of the senders by name
+	paths         []string          // The paths the configuration was read from
 }
 
 // Parse configuration from data
@@ -83,10 +116,126 @@ func (conf *Config) Parse(data []byte) error {
 		conf.DBPath = filepath.Join(getUserDir(), ".orca", "orca.db")
 	}
 
+	if conf.Concurrency <= 0 {
+		// If no concurrency limit is specified, use the default limit
+		conf.Concurrency = DefaultConcurrency
+	}
+
+	if conf.HTTPPort <= 0 {
+		// If no HTTP port is specified, use the default port
+		conf.HTTPPort = DefaultHTTPPort
+	}
+
+	if conf.FlushInterval <= 0 {
+		// If no flush interval is specified, use the default interval
+		conf.FlushInterval = DefaultFlushInterval
+	}
+
+	if conf.QueueSize <= 0 {
+		// If no queue size is specified, use the default size
+		conf.QueueSize = DefaultQueueSize
+	}
+
+	// Set the defaults of the nested configurations before any validation
+	// fails, so that a configuration with an error can still be used safely
 	if conf.MaxMind == nil {
 		conf.MaxMind = &MaxMindConfig{}
 	}
 
+	if conf.Payload == nil {
+		conf.Payload = &PayloadConfig{}
+	}
+
+	if conf.TLS == nil {
+		conf.TLS = &TLSConfig{}
+	}
+
+	switch conf.Mode {
+	case "":
+		// If no connection mode is specified, use the default mode
+		conf.Mode = DefaultMode
+	case ColdMode, WarmMode:
+		// The connection mode is valid
+	default:
+		return fmt.Errorf("Unknown connection mode %q (use cold or warm)", conf.Mode)
+	}
+
+	switch conf.Transport {
+	case "":
+		// If no transport is specified, use the default transport
+		conf.Transport = DefaultTransport
+	case UnaryTransport:
+		// The transport is valid
+	case StreamTransport:
+		// Streams are long lived, so they require persistent connections
+		if conf.Mode == ColdMode {
+			return fmt.Errorf("The %s transport requires the %s connection mode", StreamTransport, WarmMode)
+		}
+	default:
+		return fmt.Errorf("Unknown transport %q (use unary or stream)", conf.Transport)
+	}
+
+	switch conf.Senders {
+	case "":
+		// If no sender policy is specified, use the default policy
+		conf.Senders = DefaultSenders
+	case AllowSenders, RejectSenders, TransientSenders:
+		// The sender policy is valid
+	default:
+		return fmt.Errorf("Unknown sender policy %q (use allow, reject, or transient)", conf.Senders)
+	}
+
+	switch conf.Store {
+	case "":
+		// If no store is specified, use the default backend
+		conf.Store = DefaultBackend
+	case SQLiteBackend, MemoryBackend:
+		// The store is valid
+	default:
+		return fmt.Errorf("Unknown store %q (use sqlite or memory)", conf.Store)
+	}
+
+	if len(conf.Probes) == 0 {
+		// If no probes are specified, use the default probe
+		conf.Probes = []string{DefaultProbe}
+	}
+
+	for _, probe := range conf.Probes {
+		switch probe {
+		case EchoProbe, TCPProbe, DNSProbe:
+			// The probe is valid
+		default:
+			return fmt.Errorf("Unknown probe %q (use echo, tcp, or dns)", probe)
+		}
+	}
+
+	if err := conf.Payload.Validate(); err != nil {
+		return err
+	}
+
+	// If the reply sizes are not specified, the replies echo the requests
+	if conf.Reply != nil {
+		if err := conf.Reply.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Replies over UDP must fit in a datagram along with the echoed payload
+	if conf.UDP && conf.Reply != nil {
+		size := conf.Reply.MaxSize()
+		if conf.Reply.Echo {
+			size += conf.Payload.MaxSize()
+		}
+
+		if size > MaxPayloadSize {
+			return fmt.Errorf("The echoed payload and reply of %d bytes do not fit in a UDP datagram (at most %d bytes)", size, MaxPayloadSize)
+		}
+	}
+
+	if err := conf.TLS.Validate(); err != nil {
+		return err
+	}
+
 	// Return nil if there was no error
 	return nil
 }
@@ -102,9 +251,27 @@ func (conf *Config) Read(path string) error {
 		return err
 	}
 
+	conf.paths = append(conf.paths, path)
 	return nil
 }
 
+// Reload reads a new configuration from the paths that the configuration was
+// read from, in the same order, so that changes to the files can be applied.
+func (conf *Config) Reload() (*Config, error) {
+	if len(conf.paths) == 0 {
+		return nil, errors.New("No configuration files were read to reload")
+	}
+
+	config := new(Config)
+	for _, path := range conf.paths {
+		if err := config.Read(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
 // String returns a string representation of the configuration
 func (conf Config) String() string {
 	output := fmt.Sprintf("%s configuration (debug = %t)", conf.Name, conf.Debug)
@@ -120,7 +287,33 @@ func (conf Config) String() string {
 	}
 
 	output += fmt.Sprintf("\nPing Interval: %d seconds", conf.Interval)
-	output += fmt.Sprintf("\nDatabase: %s", conf.DBPath)
+	output += fmt.Sprintf("\nConcurrency: %d pings", conf.Concurrency)
+	output += fmt.Sprintf("\nFlush Interval: %d seconds (queue size = %d)", conf.FlushInterval, conf.QueueSize)
+	output += fmt.Sprintf("\nConnection Mode: %s", conf.Mode)
+	output += fmt.Sprintf("\nProbes: %s", strings.Join(conf.Probes, ", "))
+	output += fmt.Sprintf("\nTransport: %s (udp = %t, http = %t)", conf.Transport, conf.UDP, conf.HTTP)
+	output += fmt.Sprintf("\nHTTP Port: %d", conf.HTTPPort)
+
+	if conf.Payload != nil {
+		output += fmt.Sprintf("\nPayload: %s", conf.Payload)
+	}
+
+	if conf.Reply != nil {
+		output += fmt.Sprintf("\nReply: %s", conf.Reply)
+	}
+
+	if conf.TLS.Enabled() {
+		output += fmt.Sprintf("\nTLS: Cert=%s Key=%s CA=%s (mutual = %t)", conf.TLS.Cert, conf.TLS.Key, conf.TLS.CA, conf.TLS.Mutual)
+	} else {
+		output += "\nTLS: disabled"
+	}
+
+	output += fmt.Sprintf("\nSenders: %s (%d keys, signed = %t)", conf.Senders, len(conf.SenderKeys), conf.Key != "")
+	if conf.Store == MemoryBackend {
+		output += "\nStore: memory"
+	} else {
+		output += fmt.Sprintf("\nDatabase: %s", conf.DBPath)
+	}
 
 	if conf.MaxMind != nil {
 		output += fmt.Sprintf("\nMaxMind: User=%s License=%s", conf.MaxMind.Username, conf.MaxMind.License)
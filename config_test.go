@@ -0,0 +1,152 @@
+package orca_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// Helper function to write a configuration file in the temp directory
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		Ω(ioutil.WriteFile(path, []byte(data), 0644)).Should(Succeed())
+		return path
+	}
+
+	It("should reload the configuration from the paths it was read from", func() {
+		conf := new(Config)
+		Ω(conf.Read(write("a.yml", "name: alpha\ninterval: 10\n"))).Should(Succeed())
+		Ω(conf.Read(write("b.yml", "debug: true\n"))).Should(Succeed())
+		Ω(conf.Interval).Should(BeEquivalentTo(10))
+
+		write("a.yml", "name: alpha\ninterval: 20\n")
+		reloaded, err := conf.Reload()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(reloaded.Name).Should(Equal("alpha"))
+		Ω(reloaded.Interval).Should(BeEquivalentTo(20))
+		Ω(reloaded.Debug).Should(BeTrue())
+
+		// The original configuration is not modified
+		Ω(conf.Interval).Should(BeEquivalentTo(10))
+	})
+
+	It("should require the warm mode for the stream transport", func() {
+		conf := new(Config)
+		Ω(conf.Parse([]byte("transport: stream\n"))).Should(Succeed())
+		Ω(conf.Mode).Should(Equal(WarmMode))
+
+		conf = new(Config)
+		Ω(conf.Parse([]byte("transport: stream\nmode: cold\n"))).ShouldNot(Succeed())
+		Ω(new(Config).Parse([]byte("transport: carrier pigeon\n"))).ShouldNot(Succeed())
+	})
+
+	It("should default to the echo probe and reject unknown probes", func() {
+		conf := new(Config)
+		Ω(conf.Parse([]byte("name: alpha\n"))).Should(Succeed())
+		Ω(conf.Probes).Should(Equal([]string{EchoProbe}))
+
+		conf = new(Config)
+		Ω(conf.Parse([]byte("probes: [echo, tcp, dns]\n"))).Should(Succeed())
+		Ω(conf.Probes).Should(Equal([]string{EchoProbe, TCPProbe, DNSProbe}))
+
+		Ω(new(Config).Parse([]byte("probes: [icmp]\n"))).ShouldNot(Succeed())
+	})
+
+	It("should default to allowing unknown senders", func() {
+		conf := new(Config)
+		Ω(conf.Parse([]byte("sender_keys:\n  alpha: secret\n"))).Should(Succeed())
+		Ω(conf.Senders).Should(Equal(AllowSenders))
+		Ω(conf.SenderKeys).Should(HaveKeyWithValue("alpha", "secret"))
+
+		Ω(new(Config).Parse([]byte("senders: reject\n"))).Should(Succeed())
+		Ω(new(Config).Parse([]byte("senders: maybe\n"))).ShouldNot(Succeed())
+	})
+
+	It("should require the replies over UDP to fit in a datagram", func() {
+		reply := "payload:\n  size: 40000\nreply:\n  size: 30000\n  echo: true\n"
+		Ω(new(Config).Parse([]byte(reply))).Should(Succeed())
+		Ω(new(Config).Parse([]byte("udp: true\n" + reply))).Should(MatchError(ContainSubstring("datagram")))
+
+		// Without the echoed payload the reply fits
+		reply = "payload:\n  size: 40000\nreply:\n  sweep: [0, 30000]\n"
+		Ω(new(Config).Parse([]byte("udp: true\n" + reply))).Should(Succeed())
+	})
+
+	It("should not reload a configuration that was not read from a file", func() {
+		_, err := new(Config).Reload()
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should set the defaults of an invalid configuration", func() {
+		conf := new(Config)
+		Ω(conf.Parse([]byte("mode: wram\n"))).Should(MatchError(ContainSubstring("wram")))
+		Ω(conf.MaxMind).ShouldNot(BeNil())
+		Ω(conf.Payload).ShouldNot(BeNil())
+		Ω(conf.TLS).ShouldNot(BeNil())
+	})
+
+	Describe("loading", func() {
+
+		var paths []string
+
+		BeforeEach(func() {
+			paths = ConfigPath
+		})
+
+		AfterEach(func() {
+			ConfigPath = paths
+		})
+
+		It("should load the configuration files that exist in order", func() {
+			ConfigPath = []string{
+				write("a.yml", "name: alpha\ninterval: 10\n"),
+				filepath.Join(dir, "missing.yml"),
+				write("b.yml", "interval: 20\n"),
+			}
+
+			conf, err := LoadConfig()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(conf.Name).Should(Equal("alpha"))
+			Ω(conf.Interval).Should(BeEquivalentTo(20))
+		})
+
+		It("should use the defaults if no configuration files exist", func() {
+			ConfigPath = []string{filepath.Join(dir, "missing.yml")}
+
+			conf, err := LoadConfig()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(conf.Mode).Should(Equal(DefaultMode))
+			Ω(conf.TLS).ShouldNot(BeNil())
+		})
+
+		It("should report an invalid configuration", func() {
+			path := write("a.yml", "mode: wram\n")
+			ConfigPath = []string{path}
+
+			_, err := LoadConfig()
+			Ω(err).Should(MatchError(ContainSubstring(path)))
+			Ω(err).Should(MatchError(ContainSubstring("wram")))
+		})
+
+	})
+
+})
@@ -0,0 +1,215 @@
+package orca
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Connection modes specify how the generator connects to reflectors. In cold
+// mode a new connection is dialed for every ping, so the latency includes
+// connection setup; in warm mode a persistent connection to each device is
+// reused so that the latency only measures the echo round trip.
+const (
+	ColdMode    = "cold"
+	WarmMode    = "warm"
+	DefaultMode = WarmMode
+)
+
+// ConnManager maintains one persistent gRPC connection per device, redialing
+// the device when its address changes or the connection has failed.
+type ConnManager struct {
+	sync.Mutex
+	conns map[int64]*deviceConn
+	tls   *tls.Config // Secures the connections if not nil
+}
+
+// Holds the connection to a device along with the address that was dialed
+// and the dialer that records the connection errors.
+type deviceConn struct {
+	addr   string
+	conn   *grpc.ClientConn
+	dialer *dialer
+}
+
+// NewConnManager creates an empty connection manager. If the TLS config is
+// not nil, the connections are secured with TLS, otherwise they are insecure.
+func NewConnManager(config *tls.Config) *ConnManager {
+	return &ConnManager{conns: make(map[int64]*deviceConn), tls: config}
+}
+
+// Get returns the connection to the device and the dialer used to connect.
+// A new connection is dialed if there is no connection to the device yet,
+// if the address of the device has changed, or if the connection has failed.
+func (cm *ConnManager) Get(device *Device) (*grpc.ClientConn, *dialer, error) {
+	cm.Lock()
+	defer cm.Unlock()
+
+	// Return the existing connection if it is still usable
+	if dc, ok := cm.conns[device.ID]; ok {
+		if dc.addr == device.IPAddr && usable(dc.conn) {
+			return dc.conn, dc.dialer, nil
+		}
+
+		// Otherwise close the connection and redial below
+		dc.conn.Close()
+		delete(cm.conns, device.ID)
+	}
+
+	dial := &dialer{tls: cm.tls}
+	conn, err := dial.Connect(device.IPAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cm.conns[device.ID] = &deviceConn{addr: device.IPAddr, conn: conn, dialer: dial}
+	return conn, dial, nil
+}
+
+// Reset closes the connection to the device so that it is redialed on the
+// next call to Get, e.g. after a ping to the device failed.
+func (cm *ConnManager) Reset(device *Device) {
+	cm.Lock()
+	defer cm.Unlock()
+
+	if dc, ok := cm.conns[device.ID]; ok {
+		dc.conn.Close()
+		delete(cm.conns, device.ID)
+	}
+}
+
+// Close all of the connections managed by the connection manager.
+func (cm *ConnManager) Close() error {
+	cm.Lock()
+	defer cm.Unlock()
+
+	for id, dc := range cm.conns {
+		dc.conn.Close()
+		delete(cm.conns, id)
+	}
+
+	return nil
+}
+
+// Helper function that determines if a connection can be used for a ping.
+func usable(conn *grpc.ClientConn) bool {
+	state, err := conn.State()
+	if err != nil {
+		return false
+	}
+
+	return state != grpc.TransientFailure && state != grpc.Shutdown
+}
+
+// Helper type that dials TCP connections for gRPC, recording the error from
+// the most recent attempt and how long the last successful connection and
+// TLS handshake took. If the connection is refused or the handshake fails,
+// the failed function is called (e.g. to cancel the request rather than wait
+// for the timeout while gRPC retries in the background).
+type dialer struct {
+	sync.Mutex
+	tls       *tls.Config   // Secures the connection if not nil
+	err       error         // Error from the most recent connection attempt
+	started   time.Time     // When the most recent successful connection started
+	latency   time.Duration // How long the most recent successful connection took
+	shaken    time.Time     // When the most recent successful handshake started
+	handshake time.Duration // How long the most recent successful handshake took
+	failed    func()        // Called when a connection is refused or the handshake fails
+}
+
+// Connect creates a gRPC client connection to the address using the dialer.
+func (d *dialer) Connect(addr string) (*grpc.ClientConn, error) {
+	security := grpc.WithInsecure()
+	if d.tls != nil {
+		creds := &timedCredentials{credentials.NewTLS(d.tls), d}
+		security = grpc.WithTransportCredentials(creds)
+	}
+
+	return grpc.Dial(
+		addr, security, grpc.WithTimeout(Timeout),
+		grpc.WithDialer(d.Dial),
+	)
+}
+
+// Dial a TCP connection to the address and record the error and latency.
+func (d *dialer) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	latency := time.Since(started)
+
+	d.Lock()
+	d.err = err
+	if err == nil {
+		d.started = started
+		d.latency = latency
+	}
+	failed := d.failed
+	d.Unlock()
+
+	if err != nil && failed != nil && failureStatus(err) == PingRefused {
+		failed()
+	}
+
+	return conn, err
+}
+
+// Helper function that records the outcome of a TLS handshake.
+func (d *dialer) handshaked(started time.Time, handshake time.Duration, err error) {
+	d.Lock()
+	if err == nil {
+		d.shaken = started
+		d.handshake = handshake
+	} else {
+		d.err = err
+	}
+	failed := d.failed
+	d.Unlock()
+
+	if err != nil && failed != nil {
+		failed()
+	}
+}
+
+// Watch clears the recorded error and sets the function to call if the
+// connection is refused or the handshake fails while a request is being made.
+func (d *dialer) Watch(failed func()) {
+	d.Lock()
+	defer d.Unlock()
+	d.err = nil
+	d.failed = failed
+}
+
+// Dialed returns how long it took to connect if a connection was made after
+// the since timestamp, e.g. to determine if a ping had to dial its target.
+func (d *dialer) Dialed(since time.Time) (time.Duration, bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.started.Before(since) {
+		return 0, false
+	}
+	return d.latency, true
+}
+
+// Handshaked returns how long the TLS handshake took if a connection was
+// secured after the since timestamp.
+func (d *dialer) Handshaked(since time.Time) (time.Duration, bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.shaken.Before(since) {
+		return 0, false
+	}
+	return d.handshake, true
+}
+
+// Err returns the error from the most recent connection attempt.
+func (d *dialer) Err() error {
+	d.Lock()
+	defer d.Unlock()
+	return d.err
+}
@@ -1,86 +1,62 @@
 package orca
 
 import (
-	"database/sql"
 	"errors"
-
-	// Imports the sqlite3 driver
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// ConnectDB establishes a connection to the Sqlite3 database
+// ConnectDB opens the store of the backend in the configuration, by default
+// a connection to the Sqlite3 database.
 func (app *App) ConnectDB() error {
-	if app.db != nil {
+	if app.store != nil {
 		return errors.New("A database connection already exists!")
 	}
 
 	var err error
-	app.db, err = sql.Open("sqlite3", app.Config.DBPath)
+	app.store, err = OpenStore(app.Config)
 	return err
 }
 
-// CreateDB  executes the create table statements in the schema.sql stored as
-// binary data in the application (as well as any alter table statements).
+// CreateDB creates the tables of the database by applying all of the
+// migrations stored as binary data in the application. Existing databases
+// are migrated to the latest version of the schema.
 func (app *App) CreateDB() error {
-
-	// Load the schema from the binary data
-	schema, err := Asset("fixtures/schema.sql")
-	if err != nil {
-		return err
-	}
-
-	// Execute the schema SQL
-	_, err = app.db.Exec(string(schema))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, err := app.Migrate(0, false)
+	return err
 }
 
-// GetDB returns the database connectio object from the app.
-// Use with care, I didn't want to expose this outside the app ...
-func (app *App) GetDB() *sql.DB {
-	if app.db == nil {
-		app.CreateDB()
-	}
-	return app.db
+// GetStore returns the store of the models from the app.
+func (app *App) GetStore() Store {
+	return app.store
 }
 
 // FetchDevices returns a collection of devices, ordered by the created
 // timestamp. This function expects you to limit the size of the collection
 // by specifying the maximum number of nodes to return in the Devices list.
 func (app *App) FetchDevices() (Devices, error) {
-	query := "SELECT * FROM devices ORDER BY created DESC"
-	return createDeviceList(app.db, query)
+	return app.store.ListDevices()
 }
 
 // FetchDevicesExcept the specified device by excluding the device ID from the
-// SQL query. Allows the creation of a device list except for the local device.
+// list. Allows the creation of a device list except for the local device.
 func (app *App) FetchDevicesExcept(device *Device) (Devices, error) {
-	query := "SELECT * FROM devices WHERE id != $1 ORDER BY created DESC"
-	return createDeviceList(app.db, query, device.ID)
-}
-
-// Helper function that queries the devices table in the database and returns
-// a list of devices or an error. Allows arbitrary query construction.
-func createDeviceList(db *sql.DB, query string, args ...interface{}) (Devices, error) {
-	var devices Devices
-
-	rows, err := db.Query(query, args...)
+	all, err := app.store.ListDevices()
 	if err != nil {
-		return devices, err
+		return nil, err
 	}
 
-	for rows.Next() {
-		d := new(Device)
-		if err := rows.Scan(&d.ID, &d.Name, &d.IPAddr, &d.Domain, &d.Sequence, &d.Created, &d.Updated); err != nil {
-			return devices, err
+	var devices Devices
+	for _, d := range all {
+		if d.ID != device.ID {
+			devices = append(devices, d)
 		}
-
-		devices = append(devices, d)
 	}
-
-	rows.Close()
 	return devices, nil
 }
+
+// FetchSequences queries the sequence numbers and status of every echo ping
+// along with their source and target devices, ordered by pair and request,
+// so that the sequences can be analyzed for loss and reordering. Only echo
+// probes are numbered, so the other probes are excluded.
+func (app *App) FetchSequences() ([]*Ping, error) {
+	return app.store.PingSequences()
+}
@@ -1,7 +1,11 @@
 package echo
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"hash"
 	"time"
 )
 
@@ -37,12 +41,105 @@ func (m *Request) LogRecord() string {
 	return fmt.Sprintf(output, len(m.Payload), sender, m.Sequence, m.TTL, delta)
 }
 
+// Expired returns true if the request outlived its TTL (in seconds) before
+// the given time. Requests without a TTL never expire. Note that the sent
+// time is taken from the clock of the sender.
+func (m *Request) Expired(now time.Time) bool {
+	if m.TTL <= 0 {
+		return false
+	}
+	return now.Sub(m.GetSentTime()) > time.Duration(m.TTL)*time.Second
+}
+
+// Fresh returns true if the request was sent within the window before or
+// after the given time, so that a signed request cannot be replayed later.
+// Note that the sent time is taken from the clock of the sender.
+func (m *Request) Fresh(now time.Time, window time.Duration) bool {
+	delta := now.Sub(m.GetSentTime())
+	return delta <= window && delta >= -window
+}
+
+// Sign stores the HMAC-SHA256 of the request computed with the pre-shared key
+// of the sender on the request. The request must not be modified afterward.
+func (m *Request) Sign(key []byte) {
+	m.HMAC = m.digest(key)
+}
+
+// Verify returns true if the request was signed with the pre-shared key.
+func (m *Request) Verify(key []byte) bool {
+	return len(m.HMAC) > 0 && hmac.Equal(m.HMAC, m.digest(key))
+}
+
+// PayloadDigest returns the SHA-256 digest of the payload of the request, so
+// that the payload echoed in the reply can be verified.
+func (m *Request) PayloadDigest() []byte {
+	digest := sha256.Sum256(m.Payload)
+	return digest[:]
+}
+
+// Helper function that computes the HMAC of the fields of the request other
+// than the HMAC itself. Variable length fields are prefixed with their length
+// so that the fields cannot be shifted into each other.
+func (m *Request) digest(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	sender := m.GetSender()
+	if sender == nil {
+		sender = new(Device)
+	}
+
+	writeBytes(mac, []byte(sender.Name))
+	writeBytes(mac, []byte(sender.IPAddr))
+	writeBytes(mac, []byte(sender.Domain))
+	binary.Write(mac, binary.BigEndian, m.Sequence)
+	binary.Write(mac, binary.BigEndian, m.GetSentTime().UnixNano())
+	binary.Write(mac, binary.BigEndian, m.TTL)
+	binary.Write(mac, binary.BigEndian, m.Ping)
+	binary.Write(mac, binary.BigEndian, m.ReplySize)
+	binary.Write(mac, binary.BigEndian, m.OmitPayload)
+	writeBytes(mac, m.Payload)
+
+	return mac.Sum(nil)
+}
+
+// Helper function that writes the length of the data followed by the data.
+func writeBytes(h hash.Hash, data []byte) {
+	binary.Write(h, binary.BigEndian, uint64(len(data)))
+	h.Write(data)
+}
+
 // GetReceivedTime parses the received time on an Reply message to a time.Time
 func (m *Reply) GetReceivedTime() time.Time {
 	ts := m.GetReceived()
 	return ts.Parse()
 }
 
+// GetTransmittedTime parses the transmitted time on a Reply message to a
+// time.Time (the zero time if the reflector did not stamp the reply).
+func (m *Reply) GetTransmittedTime() time.Time {
+	ts := m.GetTransmitted()
+	return ts.Parse()
+}
+
+// GetProcessingTime returns how long the reflector took to reply.
+func (m *Reply) GetProcessingTime() time.Duration {
+	return time.Duration(m.Processing)
+}
+
+// GetPayloadSize returns the number of payload bytes in the reply, including
+// the payload of the echoed request.
+func (m *Reply) GetPayloadSize() int {
+	if m == nil {
+		return 0
+	}
+
+	size := len(m.Payload)
+	if echo := m.GetEcho(); echo != nil {
+		size += len(echo.Payload)
+	}
+	return size
+}
+
 // LogRecord returns the echo reply as a string in loggable format.
 func (m *Reply) LogRecord() string {
 
@@ -57,5 +154,5 @@ func (m *Reply) LogRecord() string {
 	delta := time.Now().Sub(echo.GetSentTime())
 
 	output := "received %d bytes from %s order=%d seq=%d time=%s"
-	return fmt.Sprintf(output, len(echo.Payload), remote, echo.Sequence, m.Sequence, delta)
+	return fmt.Sprintf(output, m.GetPayloadSize(), remote, echo.Sequence, m.Sequence, delta)
 }
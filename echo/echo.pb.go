@@ -6,14 +6,17 @@
 Package echo is a generated protocol buffer package.
 
 It is generated from these files:
+
 	echo.proto
 
 It has these top-level messages:
+
 	Time
 	Location
 	Device
 	Request
 	Reply
+	Ping
 */
 package echo
 
@@ -107,14 +110,21 @@ func (m *Device) GetLocation() *Location {
 	return nil
 }
 
-// Request is used to measure latency and uptime.
+// Request is used to measure latency and uptime. The hmac authenticates the
+// request with the pre-shared key of the sender. The reply size is the size
+// of the payload of the reply, and omit payload asks the reflector not to
+// echo the payload of the request, so that the reply can be smaller or larger
+// than the request.
 type Request struct {
-	Sequence int64   `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
-	Sender   *Device `protobuf:"bytes,2,opt,name=sender" json:"sender,omitempty"`
-	Sent     *Time   `protobuf:"bytes,3,opt,name=sent" json:"sent,omitempty"`
-	TTL      int64   `protobuf:"varint,4,opt,name=ttl" json:"ttl,omitempty"`
-	Ping     int64   `protobuf:"varint,5,opt,name=ping" json:"ping,omitempty"`
-	Payload  []byte  `protobuf:"bytes,15,opt,name=payload,proto3" json:"payload,omitempty"`
+	Sequence    int64   `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+	Sender      *Device `protobuf:"bytes,2,opt,name=sender" json:"sender,omitempty"`
+	Sent        *Time   `protobuf:"bytes,3,opt,name=sent" json:"sent,omitempty"`
+	TTL         int64   `protobuf:"varint,4,opt,name=ttl" json:"ttl,omitempty"`
+	Ping        int64   `protobuf:"varint,5,opt,name=ping" json:"ping,omitempty"`
+	HMAC        []byte  `protobuf:"bytes,6,opt,name=hmac,proto3" json:"hmac,omitempty"`
+	ReplySize   int64   `protobuf:"varint,7,opt,name=reply_size,json=replySize" json:"reply_size,omitempty"`
+	OmitPayload bool    `protobuf:"varint,8,opt,name=omit_payload,json=omitPayload" json:"omit_payload,omitempty"`
+	Payload     []byte  `protobuf:"bytes,15,opt,name=payload,proto3" json:"payload,omitempty"`
 }
 
 // Reset the message
@@ -145,12 +155,22 @@ func (m *Request) GetSent() *Time {
 	return nil
 }
 
-// Reply is used to respond to EchoRequest messages.
+// Reply is used to respond to EchoRequest messages. The received and
+// transmitted timestamps are taken from the clock of the reflector, and the
+// processing time is the nanoseconds the reflector took to reply. The payload
+// has the reply size of the request. Over UDP or on a stream, where a rejected
+// request cannot be answered with a status, the error says why the reflector
+// could not reply, along with the gRPC status code of the rejection.
 type Reply struct {
-	Sequence int64    `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
-	Receiver *Device  `protobuf:"bytes,2,opt,name=receiver" json:"receiver,omitempty"`
-	Received *Time    `protobuf:"bytes,3,opt,name=received" json:"received,omitempty"`
-	Echo     *Request `protobuf:"bytes,4,opt,name=echo" json:"echo,omitempty"`
+	Sequence    int64    `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+	Receiver    *Device  `protobuf:"bytes,2,opt,name=receiver" json:"receiver,omitempty"`
+	Received    *Time    `protobuf:"bytes,3,opt,name=received" json:"received,omitempty"`
+	Echo        *Request `protobuf:"bytes,4,opt,name=echo" json:"echo,omitempty"`
+	Transmitted *Time    `protobuf:"bytes,5,opt,name=transmitted" json:"transmitted,omitempty"`
+	Processing  int64    `protobuf:"varint,6,opt,name=processing" json:"processing,omitempty"`
+	Error       string   `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+	Code        uint32   `protobuf:"varint,8,opt,name=code" json:"code,omitempty"`
+	Payload     []byte   `protobuf:"bytes,15,opt,name=payload,proto3" json:"payload,omitempty"`
 }
 
 // Reset the message
@@ -189,12 +209,260 @@ func (m *Reply) GetEcho() *Request {
 	return nil
 }
 
+// GetTransmitted returns the transmitted time message if it exists
+func (m *Reply) GetTransmitted() *Time {
+	if m != nil {
+		return m.Transmitted
+	}
+	return nil
+}
+
+// Ping is the record of a ping that is exported by the pings command, e.g. to
+// load the measurements into an analysis. Times are in milliseconds and the
+// measurements that were not taken (or the location of the source, if it was
+// unknown) are left unset; the measurements are wrapped in messages so that a
+// measurement that was not taken can be told apart from a zero.
+type Ping struct {
+	ID            int64        `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Source        *Device      `protobuf:"bytes,2,opt,name=source" json:"source,omitempty"`
+	Target        *Device      `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+	Location      *Location    `protobuf:"bytes,4,opt,name=location" json:"location,omitempty"`
+	Request       int64        `protobuf:"varint,5,opt,name=request" json:"request,omitempty"`
+	Response      int64        `protobuf:"varint,6,opt,name=response" json:"response,omitempty"`
+	Sent          *Time        `protobuf:"bytes,7,opt,name=sent" json:"sent,omitempty"`
+	Recv          *Time        `protobuf:"bytes,8,opt,name=recv" json:"recv,omitempty"`
+	Latency       *DoubleValue `protobuf:"bytes,9,opt,name=latency" json:"latency,omitempty"`
+	Status        string       `protobuf:"bytes,10,opt,name=status" json:"status,omitempty"`
+	Error         string       `protobuf:"bytes,11,opt,name=error" json:"error,omitempty"`
+	Mode          string       `protobuf:"bytes,12,opt,name=mode" json:"mode,omitempty"`
+	Transport     string       `protobuf:"bytes,13,opt,name=transport" json:"transport,omitempty"`
+	Probe         string       `protobuf:"bytes,14,opt,name=probe" json:"probe,omitempty"`
+	PayloadSize   int64        `protobuf:"varint,15,opt,name=payload_size,json=payloadSize" json:"payload_size,omitempty"`
+	ReplySize     int64        `protobuf:"varint,16,opt,name=reply_size,json=replySize" json:"reply_size,omitempty"`
+	DialLatency   *DoubleValue `protobuf:"bytes,17,opt,name=dial_latency,json=dialLatency" json:"dial_latency,omitempty"`
+	FirstByte     *DoubleValue `protobuf:"bytes,18,opt,name=first_byte,json=firstByte" json:"first_byte,omitempty"`
+	RPCLatency    *DoubleValue `protobuf:"bytes,19,opt,name=rpc_latency,json=rpcLatency" json:"rpc_latency,omitempty"`
+	Handshake     *DoubleValue `protobuf:"bytes,20,opt,name=handshake" json:"handshake,omitempty"`
+	Offset        *DoubleValue `protobuf:"bytes,21,opt,name=offset" json:"offset,omitempty"`
+	Delay         *DoubleValue `protobuf:"bytes,22,opt,name=delay" json:"delay,omitempty"`
+	Forward       *DoubleValue `protobuf:"bytes,23,opt,name=forward" json:"forward,omitempty"`
+	Reverse       *DoubleValue `protobuf:"bytes,24,opt,name=reverse" json:"reverse,omitempty"`
+	Processing    *DoubleValue `protobuf:"bytes,25,opt,name=processing" json:"processing,omitempty"`
+	NetworkRTT    *DoubleValue `protobuf:"bytes,26,opt,name=network_rtt,json=networkRtt" json:"network_rtt,omitempty"`
+	PayloadMatch  *BoolValue   `protobuf:"bytes,27,opt,name=payload_match,json=payloadMatch" json:"payload_match,omitempty"`
+	SequenceMatch *BoolValue   `protobuf:"bytes,28,opt,name=sequence_match,json=sequenceMatch" json:"sequence_match,omitempty"`
+	ReceiverMatch *BoolValue   `protobuf:"bytes,29,opt,name=receiver_match,json=receiverMatch" json:"receiver_match,omitempty"`
+}
+
+// Reset the message
+func (m *Ping) Reset() { *m = Ping{} }
+
+// String returns a string representation of the message
+func (m *Ping) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage is a generated method
+func (*Ping) ProtoMessage() {}
+
+// Descriptor is a generated method
+func (*Ping) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+// GetSource returns the source device message if it exists
+func (m *Ping) GetSource() *Device {
+	if m != nil {
+		return m.Source
+	}
+	return nil
+}
+
+// GetTarget returns the target device message if it exists
+func (m *Ping) GetTarget() *Device {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+// GetLocation returns the location message if it exists
+func (m *Ping) GetLocation() *Location {
+	if m != nil {
+		return m.Location
+	}
+	return nil
+}
+
+// GetSent returns the sent time message if it exists
+func (m *Ping) GetSent() *Time {
+	if m != nil {
+		return m.Sent
+	}
+	return nil
+}
+
+// GetRecv returns the received time message if it exists
+func (m *Ping) GetRecv() *Time {
+	if m != nil {
+		return m.Recv
+	}
+	return nil
+}
+
+// GetLatency returns the latency message if it exists
+func (m *Ping) GetLatency() *DoubleValue {
+	if m != nil {
+		return m.Latency
+	}
+	return nil
+}
+
+// GetDialLatency returns the dial latency message if it exists
+func (m *Ping) GetDialLatency() *DoubleValue {
+	if m != nil {
+		return m.DialLatency
+	}
+	return nil
+}
+
+// GetFirstByte returns the first byte message if it exists
+func (m *Ping) GetFirstByte() *DoubleValue {
+	if m != nil {
+		return m.FirstByte
+	}
+	return nil
+}
+
+// GetRPCLatency returns the rpc latency message if it exists
+func (m *Ping) GetRPCLatency() *DoubleValue {
+	if m != nil {
+		return m.RPCLatency
+	}
+	return nil
+}
+
+// GetHandshake returns the handshake message if it exists
+func (m *Ping) GetHandshake() *DoubleValue {
+	if m != nil {
+		return m.Handshake
+	}
+	return nil
+}
+
+// GetOffset returns the offset message if it exists
+func (m *Ping) GetOffset() *DoubleValue {
+	if m != nil {
+		return m.Offset
+	}
+	return nil
+}
+
+// GetDelay returns the delay message if it exists
+func (m *Ping) GetDelay() *DoubleValue {
+	if m != nil {
+		return m.Delay
+	}
+	return nil
+}
+
+// GetForward returns the forward delay message if it exists
+func (m *Ping) GetForward() *DoubleValue {
+	if m != nil {
+		return m.Forward
+	}
+	return nil
+}
+
+// GetReverse returns the reverse delay message if it exists
+func (m *Ping) GetReverse() *DoubleValue {
+	if m != nil {
+		return m.Reverse
+	}
+	return nil
+}
+
+// GetProcessing returns the processing time message if it exists
+func (m *Ping) GetProcessing() *DoubleValue {
+	if m != nil {
+		return m.Processing
+	}
+	return nil
+}
+
+// GetNetworkRTT returns the network rtt message if it exists
+func (m *Ping) GetNetworkRTT() *DoubleValue {
+	if m != nil {
+		return m.NetworkRTT
+	}
+	return nil
+}
+
+// GetPayloadMatch returns the payload match message if it exists
+func (m *Ping) GetPayloadMatch() *BoolValue {
+	if m != nil {
+		return m.PayloadMatch
+	}
+	return nil
+}
+
+// GetSequenceMatch returns the sequence match message if it exists
+func (m *Ping) GetSequenceMatch() *BoolValue {
+	if m != nil {
+		return m.SequenceMatch
+	}
+	return nil
+}
+
+// GetReceiverMatch returns the receiver match message if it exists
+func (m *Ping) GetReceiverMatch() *BoolValue {
+	if m != nil {
+		return m.ReceiverMatch
+	}
+	return nil
+}
+
+// DoubleValue wraps a double so that it can be unset, like the well known
+// google.protobuf.DoubleValue type.
+type DoubleValue struct {
+	Value float64 `protobuf:"fixed64,1,opt,name=value" json:"value,omitempty"`
+}
+
+// Reset the message
+func (m *DoubleValue) Reset() { *m = DoubleValue{} }
+
+// String returns a string representation of the message
+func (m *DoubleValue) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage is a generated method
+func (*DoubleValue) ProtoMessage() {}
+
+// Descriptor is a generated method
+func (*DoubleValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+// BoolValue wraps a bool so that it can be unset, like the well known
+// google.protobuf.BoolValue type.
+type BoolValue struct {
+	Value bool `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+}
+
+// Reset the message
+func (m *BoolValue) Reset() { *m = BoolValue{} }
+
+// String returns a string representation of the message
+func (m *BoolValue) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage is a generated method
+func (*BoolValue) ProtoMessage() {}
+
+// Descriptor is a generated method
+func (*BoolValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
 func init() {
 	proto.RegisterType((*Time)(nil), "echo.Time")
 	proto.RegisterType((*Location)(nil), "echo.Location")
 	proto.RegisterType((*Device)(nil), "echo.Device")
 	proto.RegisterType((*Request)(nil), "echo.Request")
 	proto.RegisterType((*Reply)(nil), "echo.Reply")
+	proto.RegisterType((*Ping)(nil), "echo.Ping")
+	proto.RegisterType((*DoubleValue)(nil), "echo.DoubleValue")
+	proto.RegisterType((*BoolValue)(nil), "echo.BoolValue")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -211,6 +479,9 @@ const _ = grpc.SupportPackageIsVersion2
 type OrcaClient interface {
 	// Reflect allows nodes to respond to echo requests with echo replies.
 	Echo(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Reply, error)
+	// EchoStream replies to every echo request sent on the stream, in order,
+	// so that a long lived stream can be measured at a high frequency.
+	EchoStream(ctx context.Context, opts ...grpc.CallOption) (Orca_EchoStreamClient, error)
 }
 
 type orcaClient struct {
@@ -231,12 +502,47 @@ func (c *orcaClient) Echo(ctx context.Context, in *Request, opts ...grpc.CallOpt
 	return out, nil
 }
 
+func (c *orcaClient) EchoStream(ctx context.Context, opts ...grpc.CallOption) (Orca_EchoStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_OrcaServiceDesc.Streams[0], c.cc, "/echo.Orca/EchoStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orcaEchoStreamClient{stream}
+	return x, nil
+}
+
+// Orca_EchoStreamClient is a generated interface
+type Orca_EchoStreamClient interface {
+	Send(*Request) error
+	Recv() (*Reply, error)
+	grpc.ClientStream
+}
+
+type orcaEchoStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *orcaEchoStreamClient) Send(m *Request) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *orcaEchoStreamClient) Recv() (*Reply, error) {
+	m := new(Reply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Orca service
 
 // OrcaServer is a generated interface
 type OrcaServer interface {
 	// Reflect allows nodes to respond to echo requests with echo replies.
 	Echo(context.Context, *Request) (*Reply, error)
+	// EchoStream replies to every echo request sent on the stream, in order,
+	// so that a long lived stream can be measured at a high frequency.
+	EchoStream(Orca_EchoStreamServer) error
 }
 
 // RegisterOrcaServer is a generated function
@@ -262,6 +568,33 @@ func _OrcaEchoHandler(srv interface{}, ctx context.Context, dec func(interface{}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrcaEchoStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OrcaServer).EchoStream(&orcaEchoStreamServer{stream})
+}
+
+// Orca_EchoStreamServer is a generated interface
+type Orca_EchoStreamServer interface {
+	Send(*Reply) error
+	Recv() (*Request, error)
+	grpc.ServerStream
+}
+
+type orcaEchoStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *orcaEchoStreamServer) Send(m *Reply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *orcaEchoStreamServer) Recv() (*Request, error) {
+	m := new(Request)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _OrcaServiceDesc = grpc.ServiceDesc{
 	ServiceName: "echo.Orca",
 	HandlerType: (*OrcaServer)(nil),
@@ -271,35 +604,74 @@ var _OrcaServiceDesc = grpc.ServiceDesc{
 			Handler:    _OrcaEchoHandler,
 		},
 	},
-	Streams: []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EchoStream",
+			Handler:       _OrcaEchoStreamHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 }
 
 var fileDescriptor0 = []byte{
-	// 416 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x7c, 0x53, 0x5d, 0xca, 0xd3, 0x40,
-	0x14, 0x35, 0x5f, 0xf2, 0xa5, 0xf9, 0x6e, 0xea, 0x0f, 0xf7, 0x41, 0x86, 0x0f, 0x91, 0x1a, 0x44,
-	0x8a, 0x48, 0x1f, 0xe2, 0x0e, 0x44, 0xdf, 0x04, 0x61, 0x70, 0x03, 0xe3, 0xcc, 0x50, 0x07, 0xd2,
-	0x99, 0x98, 0x4c, 0x8b, 0x71, 0x25, 0xee, 0xc1, 0x3d, 0xb9, 0x16, 0x33, 0x77, 0x92, 0xb4, 0x05,
-	0xf5, 0xed, 0x9c, 0x7b, 0x2e, 0x27, 0xe7, 0x9e, 0x69, 0x01, 0xb4, 0xfc, 0xea, 0x76, 0x6d, 0xe7,
-	0xbc, 0xc3, 0x2c, 0xe0, 0xea, 0x1d, 0x64, 0x9f, 0xcd, 0x41, 0x23, 0x83, 0x55, 0xaf, 0xa5, 0xb3,
-	0xaa, 0x67, 0xc9, 0x26, 0xd9, 0xa6, 0x7c, 0xa6, 0xb8, 0x81, 0xd2, 0x0a, 0xeb, 0x66, 0xf5, 0x86,
-	0xd4, 0xcb, 0x51, 0xf5, 0x3b, 0x81, 0xe2, 0xa3, 0x93, 0xc2, 0x1b, 0x67, 0xf1, 0x29, 0xe4, 0xa6,
-	0x15, 0x4a, 0x75, 0xe4, 0x73, 0xc7, 0x27, 0x86, 0xf7, 0x50, 0x34, 0xe3, 0x86, 0x3f, 0x2a, 0x4d,
-	0x1e, 0x09, 0x5f, 0x38, 0x3e, 0x83, 0xbb, 0xc6, 0xd9, 0x7d, 0x14, 0x53, 0x12, 0xcf, 0x03, 0x44,
-	0xc8, 0xa4, 0xf1, 0x03, 0xcb, 0xc8, 0x8f, 0x70, 0xf8, 0x4a, 0xeb, 0x7a, 0x2f, 0x1a, 0x76, 0x1b,
-	0xbf, 0x12, 0x59, 0x38, 0x43, 0xba, 0xa3, 0xf5, 0xdd, 0xc0, 0x72, 0x12, 0x66, 0x8a, 0x15, 0xac,
-	0x5d, 0xb7, 0x17, 0xd6, 0xfc, 0xa0, 0x9c, 0x6c, 0x45, 0xf2, 0xd5, 0x2c, 0xb8, 0x2a, 0x77, 0x10,
-	0xc6, 0xb2, 0x22, 0xba, 0x46, 0x56, 0x7d, 0x87, 0xfc, 0xbd, 0x3e, 0x19, 0x49, 0x59, 0xac, 0x38,
-	0xe8, 0xe9, 0x36, 0xc2, 0x17, 0x17, 0xdf, 0x5c, 0x5d, 0x7c, 0x76, 0x4b, 0x2f, 0xdd, 0xf0, 0xf5,
-	0xd8, 0xc4, 0xd4, 0x16, 0xdd, 0x54, 0xd6, 0x8f, 0x76, 0xf4, 0x2e, 0x73, 0x87, 0x7c, 0xd1, 0xab,
-	0x5f, 0x09, 0xac, 0xb8, 0xfe, 0x76, 0xd4, 0xbd, 0x0f, 0x0d, 0xf6, 0x01, 0x5a, 0xa9, 0xa7, 0x37,
-	0x5a, 0x38, 0xbe, 0x84, 0xbc, 0xd7, 0x56, 0xe9, 0x98, 0xa1, 0xac, 0xd7, 0xd1, 0x31, 0xa6, 0xe6,
-	0x93, 0x86, 0xcf, 0x21, 0x1b, 0x91, 0xa7, 0x3c, 0x65, 0x0d, 0x71, 0x27, 0x3c, 0x3f, 0xa7, 0x39,
-	0x3e, 0x81, 0xd4, 0xfb, 0x86, 0x42, 0xa5, 0x3c, 0xc0, 0x70, 0x6f, 0x6b, 0xec, 0x9e, 0x5a, 0x4e,
-	0x39, 0xe1, 0xd0, 0x71, 0x2b, 0x86, 0xc6, 0x09, 0xc5, 0x1e, 0x8f, 0xe3, 0x35, 0x9f, 0x69, 0xf5,
-	0x33, 0x81, 0x5b, 0xae, 0xdb, 0x66, 0xf8, 0x6f, 0xd6, 0x2d, 0x14, 0x9d, 0x96, 0xda, 0x9c, 0xfe,
-	0x91, 0x76, 0x51, 0xf1, 0xd5, 0xb2, 0xa9, 0xfe, 0x92, 0x79, 0xd1, 0xf0, 0x05, 0xd0, 0x8f, 0x79,
-	0x6a, 0xf3, 0x61, 0xdc, 0x99, 0x6a, 0xe3, 0x24, 0xd5, 0x6f, 0x20, 0xfb, 0xd4, 0x49, 0x31, 0x16,
-	0x95, 0x7d, 0x18, 0x39, 0x5e, 0x2f, 0xdd, 0x97, 0x33, 0x1d, 0xc3, 0x57, 0x0f, 0xbe, 0xe4, 0xf4,
-	0x17, 0x79, 0xfb, 0x27, 0x00, 0x00, 0xff, 0xff, 0x41, 0x21, 0xb0, 0x4f, 0x30, 0x03, 0x00, 0x00,
+	// 916 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x95, 0x56, 0xdb, 0x8e, 0x1b, 0x45,
+	0x10, 0xc5, 0x77, 0xbb, 0xc6, 0xde, 0x4d, 0x9a, 0x10, 0x9a, 0x25, 0x41, 0xc9, 0x80, 0x60, 0xb9,
+	0x68, 0x09, 0x06, 0xf1, 0x01, 0x2b, 0x78, 0x0b, 0x22, 0xea, 0x20, 0x9e, 0x90, 0xac, 0xde, 0x99,
+	0xde, 0xf5, 0x28, 0xe3, 0xe9, 0xa1, 0xa7, 0xbd, 0xc1, 0x79, 0xe3, 0x77, 0xf8, 0x28, 0xfe, 0x81,
+	0x3f, 0xa0, 0xab, 0xba, 0x7b, 0xd6, 0x5e, 0x32, 0x2b, 0xf1, 0x56, 0x97, 0x53, 0x35, 0x5d, 0xa7,
+	0x2e, 0x36, 0x80, 0xca, 0xd6, 0xfa, 0xac, 0x36, 0xda, 0x6a, 0x36, 0x44, 0x39, 0x3d, 0x87, 0xe1,
+	0x2f, 0xc5, 0x46, 0x31, 0x0e, 0x93, 0x46, 0x65, 0xba, 0xca, 0x1b, 0xde, 0x7b, 0xd2, 0x3b, 0x1d,
+	0x88, 0xa8, 0xb2, 0x27, 0x90, 0x54, 0xb2, 0xd2, 0xd1, 0xdb, 0x27, 0xef, 0xbe, 0x29, 0xfd, 0xbb,
+	0x07, 0xd3, 0xe7, 0x3a, 0x93, 0xb6, 0xd0, 0x15, 0x7b, 0x08, 0xe3, 0xa2, 0x96, 0x79, 0x6e, 0x28,
+	0xcf, 0x4c, 0x04, 0x8d, 0x9d, 0xc0, 0xb4, 0x74, 0x08, 0xbb, 0xcd, 0x15, 0xe5, 0xe8, 0x89, 0x56,
+	0x67, 0x8f, 0x60, 0x56, 0xea, 0xea, 0xca, 0x3b, 0x07, 0xe4, 0xbc, 0x31, 0x30, 0x06, 0xc3, 0xac,
+	0xb0, 0x3b, 0x3e, 0xa4, 0x7c, 0x24, 0xe3, 0x57, 0x6a, 0xdd, 0x58, 0x59, 0xf2, 0x91, 0xff, 0x8a,
+	0xd7, 0xb0, 0x8c, 0x4c, 0x6f, 0x2b, 0x6b, 0x76, 0x7c, 0x4c, 0x8e, 0xa8, 0xb2, 0x14, 0xe6, 0xda,
+	0x5c, 0xc9, 0xaa, 0x78, 0x43, 0xef, 0xe4, 0x13, 0x72, 0x1f, 0xd8, 0x30, 0x6b, 0xae, 0x37, 0xb2,
+	0xa8, 0xf8, 0xd4, 0x67, 0xf5, 0x5a, 0xfa, 0x07, 0x8c, 0x7f, 0x50, 0xd7, 0x45, 0x46, 0x6f, 0xa9,
+	0xe4, 0x46, 0x85, 0xda, 0x48, 0xde, 0xab, 0xb8, 0x7f, 0x50, 0xf1, 0x4d, 0xb6, 0xc1, 0x7e, 0x36,
+	0xf6, 0x85, 0x63, 0x22, 0xb0, 0x45, 0x35, 0x25, 0xcb, 0xa3, 0x33, 0xea, 0x4b, 0xe4, 0x50, 0xb4,
+	0xfe, 0xf4, 0xcf, 0x3e, 0x4c, 0x84, 0xfa, 0x7d, 0xab, 0x1a, 0x8b, 0x0c, 0x36, 0x28, 0x56, 0x99,
+	0x0a, 0x3d, 0x6a, 0x75, 0xf6, 0x09, 0x8c, 0x1b, 0x55, 0xe5, 0xca, 0xbf, 0x21, 0x59, 0xce, 0x7d,
+	0x46, 0xff, 0x6a, 0x11, 0x7c, 0xec, 0x23, 0x18, 0x3a, 0xc9, 0xd2, 0x7b, 0x92, 0x25, 0x78, 0x0c,
+	0xb6, 0x5f, 0x90, 0x9d, 0xdd, 0x83, 0x81, 0xb5, 0x25, 0x3d, 0x6a, 0x20, 0x50, 0xc4, 0x7a, 0xeb,
+	0xa2, 0xba, 0x22, 0x96, 0x07, 0x82, 0x64, 0xb4, 0xad, 0x37, 0x32, 0x23, 0x82, 0xe7, 0x82, 0x64,
+	0xf6, 0x18, 0xc0, 0xa8, 0xba, 0xdc, 0xad, 0x9a, 0xe2, 0x8d, 0x22, 0x6e, 0x07, 0x62, 0x46, 0x96,
+	0x97, 0xce, 0xc0, 0x9e, 0x3a, 0xf2, 0x37, 0x85, 0x5d, 0xd5, 0x72, 0x57, 0x6a, 0x99, 0x13, 0xbd,
+	0x53, 0x91, 0xa0, 0xed, 0x85, 0x37, 0x61, 0xe7, 0xa2, 0xf7, 0x98, 0x12, 0x47, 0x35, 0xfd, 0xab,
+	0x0f, 0x23, 0x81, 0xa9, 0xee, 0x64, 0xe0, 0x14, 0xa6, 0x46, 0x65, 0xaa, 0xb8, 0xee, 0xe0, 0xa0,
+	0xf5, 0xb2, 0x4f, 0x5b, 0x64, 0xfe, 0x16, 0x26, 0x5a, 0x9f, 0x7b, 0x34, 0xad, 0x48, 0xe8, 0xd1,
+	0xc2, 0x63, 0x42, 0x33, 0x04, 0xb9, 0xd8, 0x57, 0x90, 0x58, 0x23, 0xab, 0xc6, 0xd5, 0x61, 0x5d,
+	0xb6, 0xd1, 0x7f, 0xb2, 0xed, 0xbb, 0x1d, 0xfd, 0xe0, 0x56, 0x2f, 0x53, 0x4d, 0x83, 0x94, 0x8e,
+	0xa9, 0x80, 0x3d, 0x0b, 0x7b, 0x00, 0x23, 0x65, 0x8c, 0x36, 0x61, 0x36, 0xbd, 0x42, 0xe3, 0xaf,
+	0xdd, 0x5e, 0x20, 0x67, 0x0b, 0x41, 0xf2, 0x1d, 0x64, 0xfd, 0x33, 0x85, 0xe1, 0x0b, 0x4c, 0x76,
+	0x04, 0xfd, 0x22, 0x0f, 0x2c, 0x39, 0x89, 0x26, 0x44, 0x6f, 0x4d, 0xa6, 0x3a, 0x26, 0x84, 0x7c,
+	0x88, 0xb2, 0xd2, 0x5c, 0xa9, 0x38, 0x23, 0xb7, 0x50, 0xde, 0xf7, 0x7f, 0x26, 0x18, 0x9f, 0x6a,
+	0x3c, 0x67, 0x61, 0x88, 0xa2, 0x8a, 0xdd, 0x34, 0xaa, 0xa9, 0x75, 0xd5, 0xa8, 0x40, 0x46, 0xab,
+	0xb7, 0x93, 0x3a, 0xe9, 0x98, 0x54, 0xe7, 0x77, 0x7d, 0xba, 0x26, 0x52, 0x6e, 0xf9, 0xd1, 0xce,
+	0xbe, 0x84, 0x89, 0xbb, 0x2e, 0x6e, 0x30, 0x76, 0x7c, 0x46, 0x90, 0xfb, 0xa1, 0x10, 0xbd, 0xbd,
+	0x28, 0xd5, 0xaf, 0xb2, 0xdc, 0x2a, 0x11, 0x11, 0xb8, 0xa8, 0xee, 0x78, 0xd8, 0x6d, 0xc3, 0xc1,
+	0x2f, 0xaa, 0xd7, 0x6e, 0xfa, 0x91, 0xdc, 0xea, 0xc7, 0x06, 0xfb, 0x31, 0xf7, 0x27, 0x00, 0x65,
+	0x3c, 0x60, 0xd4, 0xe8, 0x5a, 0x1b, 0xcb, 0x17, 0xe4, 0xb8, 0x31, 0x60, 0x1e, 0xd7, 0xe5, 0x0b,
+	0xc5, 0x8f, 0x7c, 0x1e, 0x52, 0x70, 0x27, 0x42, 0xd3, 0xfc, 0xd2, 0x1c, 0xfb, 0xc3, 0x1a, 0x6c,
+	0xb4, 0x36, 0x87, 0x5b, 0x75, 0xef, 0xf6, 0x56, 0x7d, 0x07, 0xf3, 0xbc, 0x90, 0xe5, 0x2a, 0x56,
+	0x7a, 0xbf, 0xab, 0xd2, 0x04, 0x61, 0xcf, 0x43, 0xb5, 0xcf, 0x00, 0x2e, 0x0b, 0xd3, 0xd8, 0xd5,
+	0xc5, 0xce, 0x2a, 0xce, 0xba, 0x62, 0x66, 0x04, 0x3a, 0x77, 0x18, 0xb6, 0x84, 0xc4, 0xd4, 0x59,
+	0xfb, 0x99, 0x77, 0xbb, 0x42, 0xc0, 0xa1, 0xe2, 0x57, 0xbe, 0x86, 0xd9, 0x5a, 0xba, 0x1f, 0x87,
+	0xb5, 0x7c, 0xa5, 0xf8, 0x83, 0xce, 0x8f, 0xb4, 0x18, 0xf6, 0x39, 0x8c, 0xf5, 0xe5, 0x65, 0xe3,
+	0x26, 0xef, 0xbd, 0x2e, 0x74, 0x00, 0xb0, 0xcf, 0x60, 0x94, 0xab, 0x52, 0xee, 0xf8, 0xc3, 0x2e,
+	0xa4, 0xf7, 0xe3, 0x14, 0x5c, 0x6a, 0xf3, 0x5a, 0x9a, 0x9c, 0xbf, 0xdf, 0x39, 0x05, 0x01, 0x81,
+	0x60, 0xa3, 0xdc, 0x7d, 0x70, 0xd3, 0xc8, 0x3b, 0xc1, 0x01, 0xc1, 0xbe, 0x39, 0x58, 0xe5, 0x0f,
+	0x3a, 0x19, 0xd9, 0xdb, 0x6e, 0xc7, 0x62, 0xa5, 0xec, 0x6b, 0x6d, 0x5e, 0xad, 0x8c, 0xb5, 0xfc,
+	0xa4, 0x33, 0x26, 0xa0, 0x84, 0xb5, 0xae, 0xc3, 0x8b, 0x38, 0x23, 0x1b, 0x69, 0xb3, 0x35, 0xff,
+	0x90, 0xa2, 0x8e, 0x7d, 0xd4, 0xb9, 0xd6, 0xa5, 0x8f, 0x89, 0x93, 0xf4, 0x13, 0x82, 0xd8, 0xf7,
+	0x70, 0x14, 0xcf, 0x62, 0x08, 0x7b, 0xf4, 0xf6, 0xb0, 0x45, 0x84, 0xb5, 0x71, 0xf1, 0x48, 0x86,
+	0xb8, 0xc7, 0x1d, 0x71, 0x11, 0x46, 0x71, 0xe9, 0xc7, 0x90, 0xec, 0x15, 0x80, 0xe3, 0x7e, 0x8d,
+	0x02, 0x1d, 0x9f, 0x9e, 0xf0, 0x4a, 0xfa, 0x14, 0x66, 0x6d, 0x82, 0x43, 0xc8, 0x34, 0x40, 0x96,
+	0xbf, 0xc1, 0xf0, 0x67, 0x93, 0x49, 0x77, 0x84, 0x86, 0x3f, 0xe2, 0x75, 0x3d, 0x3c, 0xb9, 0x27,
+	0x49, 0x54, 0xdd, 0xfc, 0xa7, 0xef, 0xb0, 0x33, 0x00, 0x44, 0xbd, 0xb4, 0x46, 0xc9, 0xcd, 0xdd,
+	0xd8, 0xd3, 0xde, 0xb3, 0xde, 0xc5, 0x98, 0xfe, 0xf6, 0x7c, 0xfb, 0x2f, 0x5c, 0xbc, 0x0c, 0x25,
+	0x04, 0x09, 0x00, 0x00,
 }
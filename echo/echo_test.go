@@ -46,4 +46,71 @@ var _ = Describe("Echo", func() {
 		Ω(ts.Equal(msg.Parse())).Should(BeTrue())
 	})
 
+	It("should sign and verify requests with a pre-shared key", func() {
+		key := []byte("supersecret")
+		msg := &Request{
+			Sequence: 42, Sender: &Device{Name: "alpha"},
+			Sent: &Time{Nanoseconds: nsecs}, TTL: 30, Ping: 7, Payload: []byte("payload"),
+		}
+		Ω(msg.Verify(key)).Should(BeFalse())
+
+		msg.Sign(key)
+		Ω(msg.HMAC).ShouldNot(BeEmpty())
+		Ω(msg.Verify(key)).Should(BeTrue())
+		Ω(msg.Verify([]byte("wrong"))).Should(BeFalse())
+
+		// Modifying any signed field invalidates the signature
+		msg.Sender.Name = "mallory"
+		Ω(msg.Verify(key)).Should(BeFalse())
+		msg.Sender.Name = "alpha"
+		msg.Sequence++
+		Ω(msg.Verify(key)).Should(BeFalse())
+		msg.Sequence--
+		msg.ReplySize = 4096
+		Ω(msg.Verify(key)).Should(BeFalse())
+	})
+
+	It("should expire requests that outlived their TTL", func() {
+		sent := time.Now().Add(-10 * time.Second)
+		msg := &Request{Sent: &Time{Nanoseconds: sent.UnixNano()}, TTL: 30}
+		Ω(msg.Expired(time.Now())).Should(BeFalse())
+		Ω(msg.Expired(sent.Add(31 * time.Second))).Should(BeTrue())
+
+		// Requests without a TTL never expire
+		msg.TTL = 0
+		Ω(msg.Expired(sent.Add(time.Hour))).Should(BeFalse())
+	})
+
+	It("should check that requests were sent within a window of now", func() {
+		sent := time.Now()
+		msg := &Request{Sent: &Time{Nanoseconds: sent.UnixNano()}}
+		Ω(msg.Fresh(sent.Add(29*time.Second), 30*time.Second)).Should(BeTrue())
+		Ω(msg.Fresh(sent.Add(-29*time.Second), 30*time.Second)).Should(BeTrue())
+		Ω(msg.Fresh(sent.Add(31*time.Second), 30*time.Second)).Should(BeFalse())
+		Ω(msg.Fresh(sent.Add(-31*time.Second), 30*time.Second)).Should(BeFalse())
+	})
+
+	It("should digest the payload of a request", func() {
+		msg := &Request{Payload: []byte("payload")}
+		digest := msg.PayloadDigest()
+		Ω(digest).Should(HaveLen(32))
+
+		echoed := &Request{Payload: []byte("payload")}
+		Ω(echoed.PayloadDigest()).Should(Equal(digest))
+
+		echoed.Payload[0] = 'P'
+		Ω(echoed.PayloadDigest()).ShouldNot(Equal(digest))
+	})
+
+	It("should count the reply payload and the echoed payload", func() {
+		reply := &Reply{Echo: &Request{Payload: make([]byte, 50)}, Payload: make([]byte, 4096)}
+		Ω(reply.GetPayloadSize()).Should(Equal(4146))
+
+		reply.Echo.Payload = nil
+		Ω(reply.GetPayloadSize()).Should(Equal(4096))
+
+		reply = nil
+		Ω(reply.GetPayloadSize()).Should(Equal(0))
+	})
+
 })
@@ -0,0 +1,268 @@
+package orca
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Export formats of the pings command. The table is for people to read; the
+// other formats write every column of the pings so that they can be loaded
+// into an analysis. Protobuf writes echo.Ping messages that are each prefixed
+// with their length as a varint (the same framing as the delimited messages
+// of the Java and Python protobuf libraries).
+const (
+	TableFormat    = "table"
+	CSVFormat      = "csv"
+	JSONLFormat    = "jsonl"
+	ProtobufFormat = "protobuf"
+	DefaultFormat  = TableFormat
+)
+
+// PingWriter exports pings in one of the export formats. Flush must be called
+// after the last ping is written.
+type PingWriter interface {
+	Write(ping *Ping) error
+	Flush() error
+}
+
+// NewPingWriter returns a writer of the pings in the format to w.
+func NewPingWriter(w io.Writer, format string) (PingWriter, error) {
+	switch format {
+	case "", TableFormat:
+		return newTableWriter(w), nil
+	case CSVFormat:
+		return newCSVWriter(w), nil
+	case JSONLFormat:
+		return &jsonlWriter{w: w}, nil
+	case ProtobufFormat:
+		return &protobufWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("Unknown format %q (use table, csv, jsonl or protobuf)", format)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Ping Columns
+/////////////////////////////////////////////////////////////////////////////
+
+// The columns of the CSV and JSON Lines formats in order. Values are nil if
+// they were not measured or if the location of the ping is unknown; times
+// are in milliseconds.
+var pingColumns = []struct {
+	name  string
+	value func(p *Ping) interface{}
+}{
+	{"id", func(p *Ping) interface{} { return p.ID }},
+	{"source", func(p *Ping) interface{} { return p.Source.Name }},
+	{"target", func(p *Ping) interface{} { return p.Target.Name }},
+	{"location", locationColumn(func(l *Location) interface{} { return l.IPAddr })},
+	{"city", locationColumn(func(l *Location) interface{} { return l.City })},
+	{"country", locationColumn(func(l *Location) interface{} { return l.Country })},
+	{"latitude", locationColumn(func(l *Location) interface{} { return l.Latitude })},
+	{"longitude", locationColumn(func(l *Location) interface{} { return l.Longitude })},
+	{"request", func(p *Ping) interface{} { return p.Request }},
+	{"response", func(p *Ping) interface{} { return p.Response }},
+	{"sent", func(p *Ping) interface{} { return timeValue(p.Sent) }},
+	{"recv", func(p *Ping) interface{} { return timeValue(p.Recv) }},
+	{"latency", func(p *Ping) interface{} { return floatValue(p.Latency) }},
+	{"status", func(p *Ping) interface{} { return p.Status }},
+	{"error", func(p *Ping) interface{} { return stringValue(p.Error) }},
+	{"mode", func(p *Ping) interface{} { return p.Mode }},
+	{"transport", func(p *Ping) interface{} { return p.Transport }},
+	{"probe", func(p *Ping) interface{} { return p.Probe }},
+	{"payload_size", func(p *Ping) interface{} { return p.PayloadSize }},
+	{"reply_size", func(p *Ping) interface{} { return p.ReplySize }},
+	{"dial_latency", func(p *Ping) interface{} { return floatValue(p.DialLatency) }},
+	{"first_byte", func(p *Ping) interface{} { return floatValue(p.FirstByte) }},
+	{"rpc_latency", func(p *Ping) interface{} { return floatValue(p.RPCLatency) }},
+	{"handshake", func(p *Ping) interface{} { return floatValue(p.Handshake) }},
+	{"offset", func(p *Ping) interface{} { return floatValue(p.Offset) }},
+	{"delay", func(p *Ping) interface{} { return floatValue(p.Delay) }},
+	{"forward", func(p *Ping) interface{} { return floatValue(p.Forward) }},
+	{"reverse", func(p *Ping) interface{} { return floatValue(p.Reverse) }},
+	{"processing", func(p *Ping) interface{} { return floatValue(p.Processing) }},
+	{"network_rtt", func(p *Ping) interface{} { return floatValue(p.NetworkRTT) }},
+	{"payload_match", func(p *Ping) interface{} { return boolValue(p.PayloadMatch) }},
+	{"sequence_match", func(p *Ping) interface{} { return boolValue(p.SequenceMatch) }},
+	{"receiver_match", func(p *Ping) interface{} { return boolValue(p.ReceiverMatch) }},
+}
+
+// Helper function that returns a column of a field of the location, which is
+// nil if the location of the ping is unknown.
+func locationColumn(value func(l *Location) interface{}) func(p *Ping) interface{} {
+	return func(p *Ping) interface{} {
+		if p.Location == nil {
+			return nil
+		}
+		return value(p.Location)
+	}
+}
+
+// Helper functions that return the value of a column or nil if it is NULL.
+func timeValue(ts time.Time) interface{} {
+	if ts.IsZero() {
+		return nil
+	}
+	return ts
+}
+
+func floatValue(n sql.NullFloat64) interface{} {
+	if !n.Valid {
+		return nil
+	}
+	return n.Float64
+}
+
+func stringValue(n sql.NullString) interface{} {
+	if !n.Valid {
+		return nil
+	}
+	return n.String
+}
+
+func boolValue(n sql.NullBool) interface{} {
+	if !n.Valid {
+		return nil
+	}
+	return n.Bool
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Ping Writers
+/////////////////////////////////////////////////////////////////////////////
+
+// Writes a summary of the pings in aligned columns.
+type tableWriter struct {
+	w *tabwriter.Writer
+}
+
+func newTableWriter(w io.Writer) *tableWriter {
+	tw := &tableWriter{w: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)}
+	fmt.Fprintln(tw.w, "ID\tSOURCE\tTARGET\tLOCATION\tSENT\tPROBE\tTRANSPORT\tSTATUS\tLATENCY")
+	return tw
+}
+
+// Write a row of the ping to the table.
+func (tw *tableWriter) Write(p *Ping) error {
+	location := "-"
+	if p.Location != nil {
+		location = p.Location.IPAddr
+		if p.Location.City != "" {
+			location = p.Location.City
+		}
+	}
+
+	latency := "-"
+	if p.Latency.Valid {
+		latency = fmt.Sprintf("%0.3fms", p.Latency.Float64)
+	}
+
+	_, err := fmt.Fprintf(
+		tw.w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		p.ID, p.Source.Name, p.Target.Name, location, p.Sent.Format(time.RFC3339),
+		p.Probe, p.Transport, p.Status, latency,
+	)
+	return err
+}
+
+// Flush aligns the columns of the table and writes it.
+func (tw *tableWriter) Flush() error {
+	return tw.w.Flush()
+}
+
+// Writes every column of the pings with a header. NULL values are empty.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+
+	header := make([]string, 0, len(pingColumns))
+	for _, col := range pingColumns {
+		header = append(header, col.name)
+	}
+	cw.w.Write(header)
+
+	return cw
+}
+
+// Write a record of the ping.
+func (cw *csvWriter) Write(p *Ping) error {
+	record := make([]string, 0, len(pingColumns))
+	for _, col := range pingColumns {
+		switch val := col.value(p).(type) {
+		case nil:
+			record = append(record, "")
+		case time.Time:
+			record = append(record, val.Format(time.RFC3339Nano))
+		case float64:
+			record = append(record, strconv.FormatFloat(val, 'f', -1, 64))
+		default:
+			record = append(record, fmt.Sprint(val))
+		}
+	}
+	return cw.w.Write(record)
+}
+
+// Flush the buffered records.
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Writes every column of a ping as a JSON object on a line, with the keys in
+// the order of the columns. NULL values are null.
+type jsonlWriter struct {
+	w io.Writer
+}
+
+// Write the JSON object of the ping.
+func (jw *jsonlWriter) Write(p *Ping) error {
+	fields := make([]string, 0, len(pingColumns))
+	for _, col := range pingColumns {
+		val, err := json.Marshal(col.value(p))
+		if err != nil {
+			return err
+		}
+		fields = append(fields, fmt.Sprintf("%q:%s", col.name, val))
+	}
+
+	_, err := fmt.Fprintf(jw.w, "{%s}\n", strings.Join(fields, ","))
+	return err
+}
+
+// Flush does nothing since the pings are not buffered.
+func (jw *jsonlWriter) Flush() error {
+	return nil
+}
+
+// Writes the echo.Ping message of every ping prefixed with its length.
+type protobufWriter struct {
+	w io.Writer
+}
+
+// Write the length and the message of the ping.
+func (pw *protobufWriter) Write(p *Ping) error {
+	buf := proto.NewBuffer(nil)
+	if err := buf.EncodeMessage(p.Echo()); err != nil {
+		return err
+	}
+
+	_, err := pw.w.Write(buf.Bytes())
+	return err
+}
+
+// Flush does nothing since the pings are not buffered.
+func (pw *protobufWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,144 @@
+package orca_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/bbengfort/orca"
+	"github.com/bbengfort/orca/echo"
+	"github.com/golang/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PingWriter", func() {
+
+	var buf *bytes.Buffer
+	var pings []*Ping
+	var sent time.Time
+
+	// Helper function that writes the pings in the format
+	export := func(format string) {
+		writer, err := NewPingWriter(buf, format)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for _, ping := range pings {
+			Ω(writer.Write(ping)).Should(Succeed())
+		}
+		Ω(writer.Flush()).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		buf = new(bytes.Buffer)
+		sent = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		alpha := &Device{Name: "alpha"}
+		bravo := &Device{Name: "bravo"}
+		location := &Location{IPAddr: "127.0.0.1", City: "Washington", Country: "United States"}
+		pings = []*Ping{
+			{
+				ID: 1, Source: alpha, Target: bravo, Location: location, Request: 1, Response: 1, Status: PingReplied,
+				Sent: sent, Recv: sent.Add(12 * time.Millisecond), Probe: EchoProbe, Transport: UnaryTransport,
+				Latency: sql.NullFloat64{Float64: 12.5, Valid: true}, PayloadSize: 64,
+				PayloadMatch: sql.NullBool{Bool: true, Valid: true}, Offset: sql.NullFloat64{Valid: true},
+			},
+			{
+				ID: 2, Source: alpha, Target: bravo, Request: 2, Sent: sent.Add(time.Second),
+				Status: PingTimeout, Error: sql.NullString{String: "deadline exceeded", Valid: true},
+				Probe: EchoProbe, Transport: UnaryTransport,
+			},
+		}
+	})
+
+	It("should not create a writer of an unknown format", func() {
+		_, err := NewPingWriter(buf, "xml")
+		Ω(err).Should(MatchError(ContainSubstring("Unknown format")))
+	})
+
+	It("should write the pings in a table", func() {
+		export(TableFormat)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Ω(lines).Should(HaveLen(3))
+		Ω(lines[0]).Should(HavePrefix("ID"))
+		Ω(strings.Fields(lines[1])).Should(ContainElement("Washington"))
+		Ω(strings.Fields(lines[1])).Should(ContainElement("12.500ms"))
+		Ω(strings.Fields(lines[2])).Should(ContainElement("timeout"))
+	})
+
+	It("should write the pings as CSV with empty NULL values", func() {
+		export(CSVFormat)
+
+		records, err := csv.NewReader(buf).ReadAll()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(HaveLen(3))
+
+		header := make(map[string]int)
+		for i, name := range records[0] {
+			header[name] = i
+		}
+
+		Ω(records[1][header["city"]]).Should(Equal("Washington"))
+		Ω(records[1][header["latency"]]).Should(Equal("12.5"))
+		Ω(records[1][header["sent"]]).Should(Equal("2017-03-01T12:00:00Z"))
+		Ω(records[1][header["payload_match"]]).Should(Equal("true"))
+		Ω(records[2][header["city"]]).Should(BeEmpty())
+		Ω(records[2][header["latency"]]).Should(BeEmpty())
+		Ω(records[2][header["recv"]]).Should(BeEmpty())
+		Ω(records[2][header["error"]]).Should(Equal("deadline exceeded"))
+	})
+
+	It("should write the pings as JSON lines with null NULL values", func() {
+		export(JSONLFormat)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Ω(lines).Should(HaveLen(2))
+		Ω(lines[0]).Should(HavePrefix(`{"id":1,"source":"alpha","target":"bravo",`))
+
+		var record map[string]interface{}
+		Ω(json.Unmarshal([]byte(lines[0]), &record)).Should(Succeed())
+		Ω(record["latency"]).Should(Equal(12.5))
+		Ω(record["country"]).Should(Equal("United States"))
+		Ω(record["sequence_match"]).Should(BeNil())
+
+		record = nil
+		Ω(json.Unmarshal([]byte(lines[1]), &record)).Should(Succeed())
+		Ω(record).Should(HaveKeyWithValue("location", BeNil()))
+		Ω(record).Should(HaveKeyWithValue("latency", BeNil()))
+		Ω(record["status"]).Should(Equal(PingTimeout))
+	})
+
+	It("should write the pings as length delimited protocol buffers", func() {
+		export(ProtobufFormat)
+
+		reader := proto.NewBuffer(buf.Bytes())
+		first, second := new(echo.Ping), new(echo.Ping)
+		Ω(reader.DecodeMessage(first)).Should(Succeed())
+		Ω(reader.DecodeMessage(second)).Should(Succeed())
+
+		Ω(first.ID).Should(BeEquivalentTo(1))
+		Ω(first.GetSource().Name).Should(Equal("alpha"))
+		Ω(first.GetLocation().City).Should(Equal("Washington"))
+		Ω(first.GetLatency().Value).Should(Equal(12.5))
+		Ω(first.GetSent().Parse().Equal(sent)).Should(BeTrue())
+		Ω(first.GetPayloadMatch().Value).Should(BeTrue())
+		Ω(first.GetSequenceMatch()).Should(BeNil())
+
+		// A measurement of zero is not the same as a measurement not taken
+		Ω(first.GetOffset()).ShouldNot(BeNil())
+		Ω(first.GetOffset().Value).Should(BeZero())
+		Ω(first.GetDelay()).Should(BeNil())
+
+		Ω(second.ID).Should(BeEquivalentTo(2))
+		Ω(second.GetLocation()).Should(BeNil())
+		Ω(second.GetRecv()).Should(BeNil())
+		Ω(second.GetLatency()).Should(BeNil())
+		Ω(second.Error).Should(Equal("deadline exceeded"))
+	})
+
+})
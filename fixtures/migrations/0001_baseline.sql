@@ -1,5 +1,5 @@
 /**
- * orca_schema.sql
+ * 0001_baseline.sql
  * Copyright 2016 University of Maryland
  *
  * Author:  Benjamin Bengfort <benjamin@bengfort.com>
@@ -7,12 +7,13 @@
  */
 
 -------------------------------------------------------------------------
--- Ensure transaction security by placing all CREATE and ALTER statements
--- inside of `BEGIN` and `COMMIT` statements.
+-- The schema of the first release of orca. Databases created before the
+-- migrations were added without a schema_version table are at this version.
+--
+-- Every migration is applied in a transaction along with its version, so
+-- migrations must not contain `BEGIN` or `COMMIT` statements.
 -------------------------------------------------------------------------
 
-BEGIN;
-
 /**
  *  CREATE ENTITY TABLES
  */
@@ -77,13 +78,3 @@ CREATE TABLE "pings"
     FOREIGN KEY ("target_id") REFERENCES devices("id"),
     FOREIGN KEY ("location_id") REFERENCES locations("id")
 );
-
- /**
-  *  CREATE INDICIES
-  */
-
- COMMIT;
-
- -------------------------------------------------------------------------
- -- No CREATE or ALTER statements should be outside of the `COMMIT`.
- -------------------------------------------------------------------------
@@ -0,0 +1,23 @@
+/**
+ * 0002_ping_status_and_timings.sql
+ */
+
+-------------------------------------------------------------------------
+-- Records failed pings with a status and error, the connection mode of the
+-- generator, and the timings of the phases of the echo request.
+-------------------------------------------------------------------------
+
+ALTER TABLE "pings" ADD COLUMN "status" TEXT NOT NULL DEFAULT 'sent';
+ALTER TABLE "pings" ADD COLUMN "error" TEXT;
+ALTER TABLE "pings" ADD COLUMN "mode" TEXT;
+ALTER TABLE "pings" ADD COLUMN "dial_latency" REAL;
+ALTER TABLE "pings" ADD COLUMN "first_byte" REAL;
+ALTER TABLE "pings" ADD COLUMN "rpc_latency" REAL;
+
+-- Pings recorded before the status was added were replied to if they have a
+-- latency
+UPDATE "pings" SET "status" = 'replied' WHERE "latency" IS NOT NULL;
+
+-- Pings recorded before the mode was added were sent on a new connection
+-- since there was only cold mode
+UPDATE "pings" SET "mode" = 'cold' WHERE "mode" IS NULL;
@@ -0,0 +1,31 @@
+/**
+ * 0003_clock_offsets.sql
+ */
+
+-------------------------------------------------------------------------
+-- Records the clock offset and delays estimated from the echo timestamps
+-- and keeps the rolling estimate of the clock offset of every target.
+-------------------------------------------------------------------------
+
+ALTER TABLE "pings" ADD COLUMN "clock_offset" REAL;
+ALTER TABLE "pings" ADD COLUMN "network_delay" REAL;
+ALTER TABLE "pings" ADD COLUMN "forward_delay" REAL;
+ALTER TABLE "pings" ADD COLUMN "reverse_delay" REAL;
+
+-------------------------------------------------------------------------
+-- clock_offsets Table
+-------------------------------------------------------------------------
+
+CREATE TABLE "clock_offsets"
+(
+    "id" INTEGER PRIMARY KEY,
+    "source_id" INTEGER NOT NULL,
+    "target_id" INTEGER NOT NULL,
+    "estimate" REAL,
+    "samples" INTEGER DEFAULT 0,
+    "created" DATETIME,
+    "updated" DATETIME,
+    UNIQUE ("source_id", "target_id"),
+    FOREIGN KEY ("source_id") REFERENCES devices("id"),
+    FOREIGN KEY ("target_id") REFERENCES devices("id")
+);
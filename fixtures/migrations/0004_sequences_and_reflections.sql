@@ -0,0 +1,44 @@
+/**
+ * 0004_sequences_and_reflections.sql
+ */
+
+-------------------------------------------------------------------------
+-- Tracks the request and response sequences of every pair of devices and
+-- journals the echo requests received by reflectors.
+-------------------------------------------------------------------------
+
+-------------------------------------------------------------------------
+-- sequences Table
+-------------------------------------------------------------------------
+
+CREATE TABLE "sequences"
+(
+    "id" INTEGER PRIMARY KEY,
+    "source_id" INTEGER NOT NULL,
+    "target_id" INTEGER NOT NULL,
+    "request" INTEGER DEFAULT 0,
+    "response" INTEGER DEFAULT 0,
+    "created" DATETIME,
+    "updated" DATETIME,
+    UNIQUE ("source_id", "target_id"),
+    FOREIGN KEY ("source_id") REFERENCES devices("id"),
+    FOREIGN KEY ("target_id") REFERENCES devices("id")
+);
+
+-------------------------------------------------------------------------
+-- reflections Table
+-------------------------------------------------------------------------
+
+CREATE TABLE "reflections"
+(
+    "id" INTEGER PRIMARY KEY,
+    "sender" TEXT NOT NULL,
+    "receiver" TEXT NOT NULL,
+    "request" INTEGER,
+    "ping_id" INTEGER,
+    "sent" DATETIME,
+    "recv" DATETIME,
+    "payload_size" INTEGER
+);
+
+CREATE INDEX "reflections_ping_idx" ON "reflections" ("sender", "ping_id");
@@ -0,0 +1,19 @@
+/**
+ * 0005_transports_and_probes.sql
+ */
+
+-------------------------------------------------------------------------
+-- Records the transport and probe of every ping, the time of the TLS
+-- handshake, and the processing time reported by the reflector.
+-------------------------------------------------------------------------
+
+ALTER TABLE "pings" ADD COLUMN "transport" TEXT;
+ALTER TABLE "pings" ADD COLUMN "probe" TEXT;
+ALTER TABLE "pings" ADD COLUMN "tls_handshake" REAL;
+ALTER TABLE "pings" ADD COLUMN "processing" REAL;
+ALTER TABLE "pings" ADD COLUMN "network_rtt" REAL;
+
+-- Pings recorded before the probes and transports were added were all unary
+-- echo requests
+UPDATE "pings" SET "probe" = 'echo';
+UPDATE "pings" SET "transport" = 'unary' WHERE "transport" IS NULL;
@@ -0,0 +1,16 @@
+/**
+ * 0006_payloads.sql
+ */
+
+-------------------------------------------------------------------------
+-- Records the sizes of the echo requests and replies, and whether the echo
+-- in the reply matched the request.
+-------------------------------------------------------------------------
+
+ALTER TABLE "pings" ADD COLUMN "payload_size" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "pings" ADD COLUMN "reply_size" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "pings" ADD COLUMN "payload_match" BOOLEAN;
+ALTER TABLE "pings" ADD COLUMN "sequence_match" BOOLEAN;
+ALTER TABLE "pings" ADD COLUMN "receiver_match" BOOLEAN;
+
+ALTER TABLE "reflections" ADD COLUMN "reply_size" INTEGER;
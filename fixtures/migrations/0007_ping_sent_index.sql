@@ -0,0 +1,10 @@
+/**
+ * 0007_ping_sent_index.sql
+ */
+
+-------------------------------------------------------------------------
+-- Indexes the sent times of the pings, which bound the time ranges of the
+-- queries of the pings command.
+-------------------------------------------------------------------------
+
+CREATE INDEX "pings_sent_idx" ON "pings" ("sent");
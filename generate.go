@@ -1,149 +1,918 @@
 package orca
 
 import (
+	"bytes"
 	"database/sql"
+	"fmt"
 	"log"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bbengfort/orca/echo"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // Timeout is the amount of time sonar will wait for a reply
 const Timeout = time.Duration(30) * time.Second
 
-// Generate is long running function that initializes pings then sleeps.
-func (app *App) Generate() error {
+// ShutdownTimeout is the amount of time the generator and reflector wait for
+// the requests in flight to finish when they are stopped before cancelling them.
+const ShutdownTimeout = time.Duration(5) * time.Second
+
+// DefaultConcurrency is the maximum number of pings in flight at once if the
+// configuration does not specify a concurrency limit.
+const DefaultConcurrency = 8
+
+// Generate is long running function that initializes pings then sleeps. The
+// devices are reloaded from the database every round so that devices can be
+// added or removed while the generator is running, and the configuration is
+// reloaded when Reload is called. The generator runs until the context is
+// cancelled, then stops scheduling pings and waits up to the ShutdownTimeout
+// for the pings in flight to finish before cancelling them. Cancelled pings
+// are recorded with the cancelled status.
+func (app *App) Generate(ctx context.Context) (err error) {
+
+	// Refuse to start against a database that has not been migrated
+	if err := app.CheckSchema(); err != nil {
+		return err
+	}
+
+	// Write the pings to the database in batches in the background
+	flush := app.Config.FlushInterval
+	if flush <= 0 {
+		flush = DefaultFlushInterval
+	}
+
+	app.writer = NewBatchWriter(app.store, time.Duration(flush)*time.Second, app.Config.QueueSize)
+	defer func() {
+		// Flush the pending writes after the pings in flight have finished
+		if werr := app.writer.Close(); werr != nil && err == nil {
+			err = werr
+		}
+	}()
 
 	// Compute the interval from the configuration
 	interval := time.Duration(app.Config.Interval) * time.Second
 
-	// Load a list of devices except the current device
-	local := app.GetDevice()
-	devices, err := app.FetchDevicesExcept(local)
+	// Load the local device and sync its location and external IP address,
+	// which is sent with the echo requests and updated every round
+	// NOTE: location errors are ignored
+	app.GetDevice()
+	app.SyncLocation()
+
+	// Secure the gRPC connections to the reflectors if TLS is configured
+	if app.Config.TLS.Enabled() {
+		config, err := app.Config.TLS.ClientConfig()
+		if err != nil {
+			return err
+		}
+		app.tls = config
+	}
+
+	// Keep persistent connections to the devices in warm mode
+	if app.Config.Mode == "" {
+		app.Config.Mode = DefaultMode
+	}
+
+	if app.Config.Mode == WarmMode {
+		app.conns = NewConnManager(app.tls)
+		defer app.conns.Close()
+	}
+
+	// Keep an echo stream open to the devices with the stream transport
+	if app.Config.Transport == "" {
+		app.Config.Transport = DefaultTransport
+	}
+
+	if app.Config.Transport == StreamTransport {
+		app.streams = NewStreamManager()
+		defer app.streams.Close()
+	}
+
+	// Create the client for the HTTP transport
+	if app.Config.HTTP {
+		app.client = newHTTPClient(app.Config.Mode)
+	}
+
+	// Create the probers that every device is pinged with each round
+	probers, err := app.probers()
 	if err != nil {
 		return err
 	}
 
-	// Resolve the addresses for all the devices
-	for _, device := range devices {
-		addr, aerr := ResolveAddr(device.IPAddr)
-		if aerr != nil {
-			return aerr
-		}
+	// The pings are not sent with the generator context so that the pings in
+	// flight can finish after it is cancelled.
+	pings, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		device.IPAddr = addr
+	// Start a worker for every device so that each device is scheduled on its
+	// own and one slow target cannot delay the pings to the other devices.
+	// The semaphore limits the number of pings that are in flight at once, so
+	// it must hold at least one ping or every worker would block on it.
+	if app.Config.Concurrency <= 0 {
+		app.Config.Concurrency = DefaultConcurrency
 	}
 
-	// Loop forever with a delay between the interval
-	for {
+	workers := &workerPool{app: app, ctx: pings, sem: make(chan struct{}, app.Config.Concurrency), probers: probers}
+	if err := app.syncWorkers(workers); err != nil {
+		return err
+	}
+
+	// Listen for requests to reload the configuration and devices
+	reload := make(chan struct{}, 1)
+	app.mu.Lock()
+	app.reload = reload
+	app.mu.Unlock()
 
-		// Wait for the specified interval
+	// Loop with a delay between the interval until the context is cancelled
+	ticker := time.NewTicker(interval)
+	defer func() {
+		ticker.Stop()
+	}()
+
+	for {
 		select {
-		case <-time.After(interval):
-			// This breaks out of select not the for loop
-			break
-		}
+		case <-ctx.Done():
+			// Give the pings in flight time to finish, then cancel them
+			workers.Stop()
+			if !waitTimeout(&workers.wg, ShutdownTimeout) {
+				cancel()
+				workers.wg.Wait()
+			}
+			return nil
 
-		// Ping all the devices in the database
-		for _, device := range devices {
-			if perr := app.Ping(device); err != nil {
-				return perr
+		case <-reload:
+			// Reload the configuration, resetting the ticker if the interval
+			// changed, and the devices.
+			if err := app.ReloadConfig(); err != nil {
+				log.Printf("could not reload the configuration: %s\n", err)
 			}
+
+			if next := time.Duration(app.Config.Interval) * time.Second; next != interval {
+				interval = next
+				ticker.Stop()
+				ticker = time.NewTicker(interval)
+			}
+
+			if err := app.syncWorkers(workers); err != nil {
+				log.Printf("could not reload the devices: %s\n", err)
+			}
+
+		case <-ticker.C:
+			// Refresh the current location of the source and the list of
+			// devices once per round
+			// NOTE: location errors are ignored
+			app.SyncLocation()
+			if err := app.syncWorkers(workers); err != nil {
+				log.Printf("could not reload the devices: %s\n", err)
+			}
+
+			// Signal every worker to ping its device. If the previous ping to
+			// a device is still in flight then the round is skipped for it.
+			workers.Round()
 		}
 	}
 
 }
 
-// Ping sends an echo request to a device and handles the response
-func (app *App) Ping(device *Device) error {
-	// Send the ping out and get a reply (blocking)
-	reply, err := app.SendPing(device)
+// Helper function that loads the devices except for the local device from
+// the database and updates the workers to ping them. Devices whose address
+// cannot be resolved are logged and skipped.
+func (app *App) syncWorkers(workers *workerPool) error {
+	devices, err := app.FetchDevicesExcept(app.GetDevice())
 	if err != nil {
 		return err
 	}
 
-	// Store the recv timestamp before any work.
-	recv := time.Now()
+	workers.Sync(devices)
+	return nil
+}
 
-	// Log the echo reply
-	if app.Config.Debug {
-		log.Println(reply.LogRecord())
+// Helper function that probes the device sent on the rounds channel with
+// every prober each time a round is signaled, waiting on the semaphore so
+// that only a limited number of pings are in flight. Errors are logged rather
+// than returned so that the worker continues to ping the device even if a
+// ping could not be saved to the database.
+func (app *App) pinger(ctx context.Context, rounds <-chan *Device, sem chan struct{}, probers []Prober) {
+	for device := range rounds {
+		for _, prober := range probers {
+			sem <- struct{}{}
+			err := app.Ping(ctx, device, prober)
+			<-sem
+
+			if err != nil {
+				log.Printf("could not ping %s: %s\n", device, err)
+			}
+		}
 	}
+}
 
-	// Fetch the Ping from the database
-	ping := new(Ping)
-	echo := reply.GetEcho()
-	if err := ping.Get(echo.Ping, app.db); err != nil {
+// Manages a worker for each device that is pinged by the generator. The
+// workers are only used by the generator loop so they do not need a lock.
+type workerPool struct {
+	app     *App
+	ctx     context.Context   // The context the pings are sent with
+	sem     chan struct{}     // Limits the number of pings in flight
+	probers []Prober          // The probes sent to every device each round
+	workers map[int64]*worker // The workers by device ID
+	wg      sync.WaitGroup    // Waits for the workers to finish
+}
+
+// A worker pings a single device. The device is sent on the rounds channel
+// every round so that changes to the device apply from the next ping without
+// interrupting the ping in flight.
+type worker struct {
+	addr   string       // The address of the device in the database
+	device *Device      // The device with its resolved address
+	rounds chan *Device // Signals the worker to ping the device
+}
+
+// Sync starts workers for devices that are not being pinged, stops the
+// workers of devices that are no longer in the list, and updates the devices
+// whose address changed.
+func (p *workerPool) Sync(devices Devices) {
+	if p.workers == nil {
+		p.workers = make(map[int64]*worker)
+	}
+
+	seen := make(map[int64]bool)
+	for _, device := range devices {
+		seen[device.ID] = true
+
+		// Only resolve the address if the device is new or it has changed
+		w, ok := p.workers[device.ID]
+		if ok && w.addr == device.IPAddr && w.device.Name == device.Name {
+			continue
+		}
+
+		addr, err := ResolveAddr(device.IPAddr)
+		if err != nil {
+			log.Printf("could not resolve the address of %s: %s\n", device.Name, err)
+			continue
+		}
+
+		if ok {
+			w.addr = device.IPAddr
+			device.IPAddr = addr
+			w.device = device
+			continue
+		}
+
+		// Start a worker for the new device
+		w = &worker{addr: device.IPAddr, rounds: make(chan *Device, 1)}
+		device.IPAddr = addr
+		w.device = device
+		p.workers[device.ID] = w
+
+		p.wg.Add(1)
+		go func(rounds <-chan *Device) {
+			defer p.wg.Done()
+			p.app.pinger(p.ctx, rounds, p.sem, p.probers)
+		}(w.rounds)
+	}
+
+	// Stop the workers of devices that were removed
+	for id, w := range p.workers {
+		if !seen[id] {
+			w.stop()
+			delete(p.workers, id)
+		}
+	}
+}
+
+// Round signals every worker to ping its device. If the previous ping to a
+// device is still in flight then the round is skipped for it.
+func (p *workerPool) Round() {
+	for _, w := range p.workers {
+		select {
+		case w.rounds <- w.device:
+		default:
+		}
+	}
+}
+
+// Stop all of the workers, skipping any rounds that have not started. The
+// pings in flight are not interrupted; wait on the pool to finish them.
+func (p *workerPool) Stop() {
+	for id, w := range p.workers {
+		w.stop()
+		delete(p.workers, id)
+	}
+}
+
+// Helper function that skips the pending round of the worker and stops it.
+func (w *worker) stop() {
+	select {
+	case <-w.rounds:
+	default:
+	}
+	close(w.rounds)
+}
+
+// Helper function that waits for the wait group to finish or for the timeout
+// to elapse. Returns true if the wait group finished before the timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Ping sends a probe to a device and handles the result. Every attempt is
+// stored in the database: if the probe fails, the ping is saved with the
+// failure status and error message as a lost ping. Only errors that prevent
+// the ping from being recorded are returned.
+func (app *App) Ping(ctx context.Context, device *Device, prober Prober) error {
+	// Create the ping record before the probe is sent
+	ping, err := app.NewPing(device, prober)
+	if err != nil {
 		return err
 	}
 
+	// Send the probe out and wait for the result (blocking)
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	result, err := prober.Probe(ctx, ping)
+	cancel()
+
+	// Record the failure as a lost ping
+	if err != nil {
+		ping.Fail(err)
+
+		if app.GetConfig().Debug {
+			log.Printf("%s: %s\n", ping, err)
+		}
+
+		return app.write(pingKey(ping), app.savePing(ping))
+	}
+
 	// Update the ping information
-	ping.Recv = recv
-	ping.Response = reply.Sequence
+	ping.Recv = result.Recv
+	ping.Latency = milliseconds(result.Latency)
+	ping.Status = PingReplied
+
+	if reply := result.Reply; reply != nil {
+		// Log the echo reply
+		if app.GetConfig().Debug {
+			log.Println(reply.LogRecord())
+		}
+
+		ping.Response = reply.Sequence
+		ping.ReplySize = int64(reply.GetPayloadSize())
 
-	// Compute the latency and set it
-	msecs := float64(recv.Sub(echo.GetSentTime()).Seconds()) * 1000.0
-	ping.Latency = sql.NullFloat64{Float64: msecs, Valid: true}
+		// Flag replies that do not echo the request or come from another device
+		if !verifyReply(ping, reply) && app.GetConfig().Debug {
+			log.Printf("%s: the reply does not match the request (payload=%s sequence=%s receiver=%s)\n",
+				ping, matched(ping.PayloadMatch), matched(ping.SequenceMatch), matched(ping.ReceiverMatch))
+		}
+
+		// Subtract the time the reflector took to reply from the latency
+		if processing := reply.GetProcessingTime(); processing > 0 {
+			ping.Processing = milliseconds(processing)
+			ping.NetworkRTT = milliseconds(result.Latency - processing)
+		}
+
+		// Estimate the clock offset and the one-way delays, unless the reply
+		// came from another device or echoes another request, which would skew
+		// the offset of the target. The ping is saved even if the estimate
+		// fails since it was replied to.
+		if ping.ReceiverMatch.Bool && ping.SequenceMatch.Bool {
+			if err := app.EstimateDelays(ping, reply, result.Recv); err != nil {
+				log.Printf("%s: could not estimate the delays: %s\n", ping, err)
+			}
+		}
+	} else if app.GetConfig().Debug {
+		log.Printf("%s in %s\n", ping, result.Latency)
+	}
 
 	// Save the ping to the database
-	ping.Save(app.db)
+	return app.write(pingKey(ping), app.savePing(ping))
+}
 
-	return nil
+// Helper function that verifies the echo in the reply against the request
+// sent for the ping: the payload (unless it was omitted from the echo, e.g.
+// to keep a UDP reply to an unkeyed sender small) by its digest along with the
+// ping ID, the ping ID and request sequence number, and the name of the
+// receiver.
+// Stores the results on the ping and returns true if everything matched.
+func verifyReply(ping *Ping, reply *echo.Reply) bool {
+	echoed := reply.GetEcho()
+	if echoed == nil {
+		echoed = new(echo.Request)
+	}
+
+	if ping.digest != nil && !echoed.OmitPayload {
+		match := echoed.Ping == ping.ID && bytes.Equal(echoed.PayloadDigest(), ping.digest)
+		ping.PayloadMatch = sql.NullBool{Bool: match, Valid: true}
+	}
+
+	match := echoed.Ping == ping.ID && echoed.Sequence == ping.Request
+	ping.SequenceMatch = sql.NullBool{Bool: match, Valid: true}
+
+	receiver := reply.GetReceiver()
+	match = receiver != nil && receiver.Name == ping.Target.Name
+	ping.ReceiverMatch = sql.NullBool{Bool: match, Valid: true}
+
+	return (!ping.PayloadMatch.Valid || ping.PayloadMatch.Bool) && ping.SequenceMatch.Bool && ping.ReceiverMatch.Bool
+}
+
+// Helper function that describes the result of a verification for logging.
+func matched(match sql.NullBool) string {
+	switch {
+	case !match.Valid:
+		return "unchecked"
+	case match.Bool:
+		return "ok"
+	default:
+		return "mismatch"
+	}
 }
 
-// SendPing sends an echo request to another device
-func (app *App) SendPing(device *Device) (*echo.Reply, error) {
+// EstimateDelays computes the clock offset and network delay of a ping from
+// the four timestamps of the echo and updates the rolling estimate of the
+// clock offset of the target, which is used to split the delay into forward
+// and reverse one-way delays. Nothing is estimated if the reflector did not
+// stamp the transmit time on the reply.
+func (app *App) EstimateDelays(ping *Ping, reply *echo.Reply, recv time.Time) error {
+	if reply.GetTransmitted() == nil {
+		return nil
+	}
+
+	// The four timestamps of the echo
+	t1 := reply.GetEcho().GetSentTime()
+	t2 := reply.GetReceivedTime()
+	t3 := reply.GetTransmittedTime()
+	t4 := recv
 
-	// Connect to the remote node
-	conn, err := grpc.Dial(device.IPAddr, grpc.WithInsecure(), grpc.WithTimeout(Timeout))
+	// Compute the offset and delay for the ping and update the estimate
+	offset, delay := EstimateOffset(t1, t2, t3, t4)
+	estimate, err := app.GetClockOffset(ping.Source, ping.Target)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Defer closing the connection and create an Echo client.
-	defer conn.Close()
-	client := echo.NewOrcaClient(conn)
+	app.mu.Lock()
+	smoothed := estimate.Update(offset)
+	app.mu.Unlock()
 
-	// Refresh the current location of the source
-	// NOTE: location errors are ignored
-	app.SyncLocation()
+	forward, reverse := OneWayDelays(t1, t2, t3, t4, smoothed)
+
+	ping.Offset = milliseconds(offset)
+	ping.Delay = milliseconds(delay)
+	ping.Forward = milliseconds(forward)
+	ping.Reverse = milliseconds(reverse)
+
+	// Save the rolling estimate to the database
+	key := fmt.Sprintf("offset %d %d", estimate.SourceID, estimate.TargetID)
+	return app.write(key, app.saveClockOffset(estimate))
+}
+
+// GetClockOffset returns the rolling clock offset estimate for the source and
+// target devices, loading it from the database the first time it is used.
+func (app *App) GetClockOffset(source, target *Device) (*ClockOffset, error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.offsets == nil {
+		app.offsets = make(map[int64]*ClockOffset)
+	}
+
+	if estimate, ok := app.offsets[target.ID]; ok {
+		return estimate, nil
+	}
+
+	// Load the estimate from the database or create a new one
+	estimate, err := app.store.GetClockOffset(source, target)
+	if err != nil {
+		if err != ErrNotFound {
+			return nil, err
+		}
+
+		estimate = &ClockOffset{SourceID: source.ID, TargetID: target.ID}
+	}
 
+	app.offsets[target.ID] = estimate
+	return estimate, nil
+}
+
+// GetSequence returns the sequence counters for the source and target devices,
+// loading them from the database the first time they are used.
+func (app *App) GetSequence(source, target *Device) (*Sequence, error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.sequences == nil {
+		app.sequences = make(map[int64]*Sequence)
+	}
+
+	if seq, ok := app.sequences[target.ID]; ok {
+		return seq, nil
+	}
+
+	// Load the sequence from the database or create a new one
+	seq, err := app.store.GetSequence(source, target)
+	if err != nil {
+		if err != ErrNotFound {
+			return nil, err
+		}
+
+		seq = &Sequence{SourceID: source.ID, TargetID: target.ID}
+	}
+
+	app.sequences[target.ID] = seq
+	return seq, nil
+}
+
+// NewPing creates a ping record for a probe of the device with a reserved ID
+// and saves it to the database with the sent status. Only echo probes are
+// numbered with the request sequence of the pair since the other probes are
+// not seen by the reflector.
+func (app *App) NewPing(device *Device, prober Prober) (*Ping, error) {
 	// Create a Ping record for experimental metrics
 	ping := new(Ping)
 
 	// Set the source with the current information
 	ping.Source = app.GetDevice()
-	ping.Source.IPAddr = app.ExternalIP
+
+	app.mu.RLock()
 	ping.Location = app.Location
+	app.mu.RUnlock()
 
 	// Set the target as the passed in device and increment the sequence
 	ping.Target = device
-	ping.Target.Sequence++
-	ping.Target.Save(app.db)
+	ping.Probe = prober.Type()
+	ping.Transport = prober.Transport()
+
+	if ping.Probe == EchoProbe {
+		seq, err := app.GetSequence(ping.Source, ping.Target)
+		if err != nil {
+			return nil, err
+		}
+
+		app.mu.Lock()
+		ping.Request = seq.NextRequest()
+		app.mu.Unlock()
+
+		key := fmt.Sprintf("sequence %d %d", seq.SourceID, seq.TargetID)
+		if err := app.write(key, app.saveSequence(seq)); err != nil {
+			return nil, err
+		}
+	}
 
 	// Set the sent timestamp - note this is not the same as the timestamp
 	// in the echo.Request - in order to eliminate database access latency.
 	// The latency saved on the record should be computed from the request.
 	ping.Sent = time.Now()
-	ping.Request = ping.Target.Sequence
+	ping.Status = PingSent
+	ping.Mode = app.GetConfig().Mode
 
-	// Save the ping to the database (updates the ID)
-	if _, err := ping.Save(app.db); err != nil {
+	// Reserve the ID of the ping so it can be sent before it is saved
+	id, err := app.nextPingID()
+	if err != nil {
 		return nil, err
 	}
+	ping.ID = id
+
+	if err := app.write(pingKey(ping), app.savePing(ping)); err != nil {
+		return nil, err
+	}
+
+	return ping, nil
+}
+
+// Helper function that reserves the ID of a new ping so that the ping can be
+// sent before it is written to the database. The IDs continue from the last
+// ping in the database, so only one generator should write the pings of a
+// database at a time (reflectors do not write pings). If the ID collides with
+// a ping of another generator, the save of the ping fails rather than
+// overwriting the other ping.
+func (app *App) nextPingID() (int64, error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.lastPing == 0 {
+		last, err := app.store.LastPingID()
+		if err != nil {
+			return 0, err
+		}
+		app.lastPing = last
+	}
+
+	app.lastPing++
+	return app.lastPing, nil
+}
+
+// SendPing sends the echo request for a ping to its target device. The
+// request times out after the Timeout, and fails immediately if the target
+// refuses the connection or the context is cancelled.
+func (app *App) SendPing(ctx context.Context, ping *Ping) (*echo.Reply, error) {
+
+	// Create a context that times out the echo request
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	// Datagrams and HTTP requests are sent without a gRPC connection
+	switch ping.Transport {
+	case UDPTransport:
+		return invokeEchoUDP(ctx, app.newRequest(ping), ping)
+	case HTTPTransport:
+		addr, err := HTTPAddr(ping.Target.IPAddr, app.GetConfig().HTTPPort)
+		if err != nil {
+			return nil, err
+		}
+
+		url := "http://" + addr + EchoPath
+		return invokeEchoHTTP(ctx, app.client, url, app.newRequest(ping), ping)
+	}
+
+	// Mark the start of the ping to determine if a connection was dialed
+	started := time.Now()
+
+	// Connect to the remote node, in warm mode using the persistent connection
+	// to the device and in cold mode dialing a new connection for this ping.
+	var (
+		conn *grpc.ClientConn
+		dial *dialer
+		err  error
+	)
+
+	if ping.Mode == WarmMode {
+		if conn, dial, err = app.conns.Get(ping.Target); err != nil {
+			return nil, err
+		}
+	} else {
+		dial = &dialer{tls: app.tls}
+		if conn, err = dial.Connect(ping.Target.IPAddr); err != nil {
+			return nil, err
+		}
+
+		// Defer closing the connection since it is only used once.
+		defer conn.Close()
+	}
+
+	// Record connection errors since gRPC retries failed connections until
+	// the timeout in the background; refused connections and failed TLS
+	// handshakes fail immediately.
+	dial.Watch(cancel)
+	defer dial.Watch(nil)
 
 	// Create an EchoRequest to send to the node
+	request := app.newRequest(ping)
+
+	// Send the Echo request to the remote reflector and record how long it
+	// took to connect if the target had to be dialed for this ping.
+	var reply *echo.Reply
+	if ping.Transport == StreamTransport {
+		reply, err = app.sendStream(ctx, conn, request, ping)
+	} else {
+		reply, err = invokeEcho(ctx, conn, request, ping)
+	}
+
+	if latency, ok := dial.Dialed(started); ok {
+		ping.DialLatency = milliseconds(latency)
+	}
+
+	if handshake, ok := dial.Handshaked(started); ok {
+		ping.Handshake = milliseconds(handshake)
+	}
+
+	// If the request failed because of a connection error, return that.
+	if err != nil {
+		// Reopen the stream and redial the persistent connection on the next ping
+		if ping.Transport == StreamTransport {
+			app.streams.Reset(ping.Target)
+		}
+
+		if ping.Mode == WarmMode {
+			app.conns.Reset(ping.Target)
+		}
+
+		if derr := dial.Err(); derr != nil {
+			return nil, derr
+		}
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// Helper function that creates the echo request for a ping with the next
+// payload and reply size, stamping the sent time as late as possible, and
+// signs it with the pre-shared key of the local device if one is configured.
+func (app *App) newRequest(ping *Ping) *echo.Request {
 	request := &echo.Request{
-		Sequence: ping.Target.Sequence,
+		Sequence: ping.Request,
 		Sender:   ping.Source.Echo(),
-		Sent:     &echo.Time{Nanoseconds: time.Now().UnixNano()},
 		TTL:      int64(Timeout.Seconds()),
 		Ping:     ping.ID,
-		Payload:  []byte("Clutter to be replaced with random or actual data."),
+		Payload:  app.nextPayload(ping),
+	}
+	ping.PayloadSize = int64(len(request.Payload))
+
+	// Ask for a reply of the configured size instead of the echoed payload
+	if reply := app.GetConfig().Reply; reply != nil {
+		request.ReplySize = int64(app.nextReplySize(ping))
+		request.OmitPayload = !reply.Echo
 	}
 
-	// Send the Echo request to the remote reflector and return
-	return client.Echo(context.Background(), request)
+	// Keep the digest of the payload to verify the echo in the reply
+	if !request.OmitPayload {
+		ping.digest = request.PayloadDigest()
+	}
+
+	// Stamp the sent time after the payload is built and digested so that
+	// the time to fill large payloads is not measured as network delay
+	request.Sent = &echo.Time{Nanoseconds: time.Now().UnixNano()}
+
+	if key := app.GetConfig().Key; key != "" {
+		request.Sign([]byte(key))
+	}
+
+	return request
+}
+
+// Helper function that sends the echo request on the stream to the target of
+// the ping, opening the stream on the connection if necessary.
+func (app *App) sendStream(ctx context.Context, conn *grpc.ClientConn, request *echo.Request, ping *Ping) (*echo.Reply, error) {
+	stream, err := app.streams.Get(ctx, ping.Target, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return invokeEchoStream(ctx, stream, request, ping)
+}
+
+// Helper function that returns the next payload to the target of the ping,
+// creating the payload generator from the configuration the first time.
+func (app *App) nextPayload(ping *Ping) []byte {
+	app.mu.Lock()
+	if app.payloads == nil {
+		app.payloads = NewPayloadGenerator(app.Config.Payload)
+	}
+	payloads := app.payloads
+	app.mu.Unlock()
+
+	return payloads.Next(ping.Target, ping.Transport)
+}
+
+// Helper function that returns the size of the reply payload requested by the
+// next echo request to the target of the ping.
+func (app *App) nextReplySize(ping *Ping) int {
+	app.mu.Lock()
+	if app.replies == nil {
+		app.replies = NewPayloadGenerator(&app.Config.Reply.PayloadConfig)
+	}
+	replies := app.replies
+	app.mu.Unlock()
+
+	return replies.NextSize(ping.Target, ping.Transport)
+}
+
+// Describes the Echo RPC as a stream with a single request and reply.
+var echoStreamDesc = &grpc.StreamDesc{StreamName: "Echo"}
+
+// Helper function that sends the echo request on a gRPC stream rather than
+// with the generated unary client so that the arrival of the response headers
+// (the first byte of the reply) can be timed separately from the full RPC.
+// The timings are stored on the ping in milliseconds.
+func invokeEcho(ctx context.Context, conn *grpc.ClientConn, request *echo.Request, ping *Ping) (*echo.Reply, error) {
+	stream, err := grpc.NewClientStream(ctx, echoStreamDesc, conn, "/echo.Orca/Echo")
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request and close the sending side of the stream
+	started := time.Now()
+	if err := stream.SendMsg(request); err != nil {
+		return nil, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	// Wait for the response headers; if there is an error it is returned by
+	// RecvMsg along with the status of the RPC.
+	if _, err := stream.Header(); err == nil {
+		ping.FirstByte = milliseconds(time.Since(started))
+	}
+
+	// Receive the reply message
+	reply := new(echo.Reply)
+	if err := stream.RecvMsg(reply); err != nil {
+		return nil, err
+	}
+
+	ping.RPCLatency = milliseconds(time.Since(started))
+	return reply, nil
+}
+
+// Helper function that converts a duration to nullable milliseconds.
+func milliseconds(d time.Duration) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: d.Seconds() * 1000.0, Valid: true}
+}
+
+// Helper function that maps the error from a failed echo request to a ping
+// status so that timeouts and refused connections can be told apart.
+func failureStatus(err error) string {
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return PingTimeout
+	}
+
+	switch {
+	case err == context.Canceled || grpc.Code(err) == codes.Canceled:
+		return PingCancelled
+	case grpc.Code(err) == codes.Unauthenticated || grpc.Code(err) == codes.PermissionDenied:
+		return PingRejected
+	case grpc.Code(err) == codes.DeadlineExceeded:
+		return PingTimeout
+	case grpc.ErrorDesc(err) == grpc.ErrClientConnTimeout.Error():
+		return PingTimeout
+	case strings.Contains(err.Error(), "connection refused"):
+		return PingRefused
+	default:
+		return PingError
+	}
+}
+
+// Helper function that queues the write operation on the batch writer of the
+// generator so that the writes of many pings are grouped into a transaction,
+// or executes it immediately if the generator is not running (e.g. when a
+// single ping is sent).
+func (app *App) write(key string, op WriteFunc) error {
+	if app.writer == nil {
+		return op(app.store)
+	}
+
+	app.writer.Write(key, op)
+	return nil
+}
+
+// Helper function that returns the key of the write operations of the ping,
+// so that the update of a ping replaces its pending insert.
+func pingKey(ping *Ping) string {
+	return fmt.Sprintf("ping %d", ping.ID)
+}
+
+// Helper function that returns a write operation that saves a snapshot of
+// the ping. The snapshot is taken when the write is queued since the ping is
+// not modified by the generator after it is saved.
+func (app *App) savePing(ping *Ping) WriteFunc {
+	snapshot := *ping
+	return func(store Store) error {
+		_, err := store.SavePing(&snapshot)
+		return err
+	}
+}
+
+// Helper function that returns a write operation that saves a snapshot of the
+// sequence and stores the ID back on the sequence once it has been inserted.
+// The sequence is copied under the lock when the operation is executed, so
+// the latest request number is saved.
+func (app *App) saveSequence(seq *Sequence) WriteFunc {
+	return func(store Store) error {
+		app.mu.Lock()
+		snapshot := *seq
+		app.mu.Unlock()
+
+		if _, err := store.SaveSequence(&snapshot); err != nil {
+			return err
+		}
+
+		app.mu.Lock()
+		seq.ModelMeta = snapshot.ModelMeta
+		app.mu.Unlock()
+		return nil
+	}
+}
+
+// Helper function that returns a write operation that saves a snapshot of the
+// clock offset estimate and stores the ID back on the estimate once it has
+// been inserted, like the sequences.
+func (app *App) saveClockOffset(estimate *ClockOffset) WriteFunc {
+	return func(store Store) error {
+		app.mu.Lock()
+		snapshot := *estimate
+		app.mu.Unlock()
+
+		if _, err := store.SaveClockOffset(&snapshot); err != nil {
+			return err
+		}
+
+		app.mu.Lock()
+		estimate.ModelMeta = snapshot.ModelMeta
+		app.mu.Unlock()
+		return nil
+	}
 }
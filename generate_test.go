@@ -0,0 +1,461 @@
+package orca_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/bbengfort/orca"
+	"github.com/bbengfort/orca/echo"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Helper function that runs a UDP reflector on a free loopback port that
+// replies to every echo request as the receiver, after the reply is changed
+// by the alter function. Returns the address and a function that stops it.
+func alteredReflector(receiver string, alter func(reply *echo.Reply)) (string, func()) {
+	sock, err := net.ListenPacket("udp", "127.0.0.1:0")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	go func() {
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			n, addr, err := sock.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			in := new(echo.Request)
+			if err := proto.Unmarshal(buf[:n], in); err != nil {
+				continue
+			}
+
+			now := &echo.Time{Nanoseconds: time.Now().UnixNano()}
+			reply := &echo.Reply{
+				Sequence: in.Sequence, Receiver: &echo.Device{Name: receiver},
+				Received: now, Transmitted: now, Echo: in,
+			}
+			alter(reply)
+
+			data, _ := proto.Marshal(reply)
+			sock.WriteTo(data, addr)
+		}
+	}()
+
+	return sock.LocalAddr().String(), func() { sock.Close() }
+}
+
+var _ = Describe("Generator", func() {
+
+	Describe("round trips", func() {
+
+		var dir string
+		var conf, reflectorConf *Config
+		var app *App
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "orca")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			conf = &Config{
+				Name: "generator", DBPath: filepath.Join(dir, "generator.db"), Interval: 1, FlushInterval: 1,
+				Concurrency: DefaultConcurrency, Probes: []string{EchoProbe},
+			}
+			reflectorConf = &Config{Name: "reflector", DBPath: filepath.Join(dir, "reflector.db"), FlushInterval: 1}
+			app = nil
+		})
+
+		AfterEach(func() {
+			if app != nil {
+				app.GetStore().Close()
+			}
+			os.RemoveAll(dir)
+		})
+
+		// Helper function that runs the generator against a reflector on the
+		// loopback address and the other devices until the returned function
+		// is called, which stops both and checks that the generator returned
+		// without an error.
+		start := func(others ...*Device) func() {
+			reflector, stop := runReflector(reflectorConf)
+			conf.HTTPPort = reflector.GetConfig().HTTPPort
+
+			app = &App{Config: conf}
+			Ω(app.ConnectDB()).Should(Succeed())
+			Ω(app.CreateDB()).Should(Succeed())
+
+			devices := append([]*Device{{Name: "reflector", IPAddr: reflector.GetConfig().Addr, Domain: "localhost"}}, others...)
+			for _, device := range devices {
+				_, err := app.GetStore().SaveDevice(device)
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errc := make(chan error, 1)
+			go func() { errc <- app.Generate(ctx) }()
+
+			return func() {
+				cancel()
+				Eventually(errc, 10*time.Second).Should(Receive(BeNil()))
+				Ω(stop()).Should(Succeed())
+				reflector.GetStore().Close()
+			}
+		}
+
+		// Helper function that returns the pings to the target with the probe
+		// and transport that were replied to.
+		replied := func(target, probe, transport string) []*Ping {
+			pings, err := QueryPings(app.GetStore(), &PingQuery{Target: target, Probe: probe, Status: PingReplied})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var matched []*Ping
+			for _, ping := range pings {
+				if ping.Transport == transport {
+					matched = append(matched, ping)
+				}
+			}
+			return matched
+		}
+
+		// Helper function that runs the generator against the reflector until
+		// n pings with the probe and transport are replied to, then stops both
+		// and returns the pings.
+		generate := func(probe, transport string, n int) []*Ping {
+			stop := start()
+			Eventually(func() int { return len(replied("reflector", probe, transport)) }, 10*time.Second).Should(BeNumerically(">=", n))
+			stop()
+
+			return replied("reflector", probe, transport)[:n]
+		}
+
+		It("should dial every ping in cold mode", func() {
+			conf.Mode = ColdMode
+
+			for _, ping := range generate(EchoProbe, UnaryTransport, 2) {
+				Ω(ping.Mode).Should(Equal(ColdMode))
+				Ω(ping.DialLatency.Valid).Should(BeTrue())
+			}
+		})
+
+		It("should only dial the first ping in warm mode", func() {
+			conf.Mode = WarmMode
+
+			pings := generate(EchoProbe, UnaryTransport, 2)
+			Ω(pings[0].Mode).Should(Equal(WarmMode))
+			Ω(pings[0].DialLatency.Valid).Should(BeTrue())
+			Ω(pings[1].Mode).Should(Equal(WarmMode))
+			Ω(pings[1].DialLatency.Valid).Should(BeFalse())
+		})
+
+		It("should time the dial, first byte and RPC of a ping", func() {
+			conf.Mode = ColdMode
+
+			ping := generate(EchoProbe, UnaryTransport, 1)[0]
+			Ω(ping.DialLatency.Float64).Should(BeNumerically(">", 0))
+			Ω(ping.FirstByte.Float64).Should(BeNumerically(">", 0))
+			Ω(ping.RPCLatency.Float64).Should(BeNumerically(">=", ping.FirstByte.Float64))
+			Ω(ping.Latency.Float64).Should(BeNumerically(">", 0))
+		})
+
+		It("should probe the reflector with a TCP handshake", func() {
+			conf.Probes = []string{TCPProbe}
+
+			ping := generate(TCPProbe, "", 1)[0]
+			Ω(ping.Target.Name).Should(Equal("reflector"))
+			Ω(ping.Transport).Should(BeEmpty())
+			Ω(ping.Request).Should(BeZero())
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.DialLatency.Valid).Should(BeTrue())
+		})
+
+		It("should probe the domain of the reflector with a DNS lookup", func() {
+			conf.Probes = []string{DNSProbe}
+
+			ping := generate(DNSProbe, "", 1)[0]
+			Ω(ping.Target.Name).Should(Equal("reflector"))
+			Ω(ping.Transport).Should(BeEmpty())
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.DialLatency.Valid).Should(BeFalse())
+		})
+
+		It("should ping the reflector from the memory store", func() {
+			conf.Store = MemoryBackend
+
+			ping := generate(EchoProbe, UnaryTransport, 1)[0]
+			Ω(ping.Target.Name).Should(Equal("reflector"))
+			Ω(ping.Source.Name).Should(Equal("generator"))
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.ReceiverMatch.Bool).Should(BeTrue())
+			Ω(conf.DBPath).ShouldNot(BeAnExistingFile())
+		})
+
+		It("should ping the reflector on an echo stream", func() {
+			conf.Transport = StreamTransport
+
+			ping := generate(EchoProbe, StreamTransport, 1)[0]
+			Ω(ping.Target.Name).Should(Equal("reflector"))
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.SequenceMatch.Bool).Should(BeTrue())
+			Ω(ping.ReceiverMatch.Bool).Should(BeTrue())
+		})
+
+		It("should ping the reflector over UDP", func() {
+			conf.UDP = true
+
+			// The reply to an unkeyed sender does not echo the payload so
+			// that it is no larger than the request
+			ping := generate(EchoProbe, UDPTransport, 1)[0]
+			Ω(ping.Target.Name).Should(Equal("reflector"))
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.PayloadMatch.Valid).Should(BeFalse())
+			Ω(ping.SequenceMatch.Bool).Should(BeTrue())
+			Ω(ping.ReceiverMatch.Bool).Should(BeTrue())
+			Ω(ping.ReplySize).Should(BeNumerically("<=", ping.PayloadSize))
+		})
+
+		It("should ping the reflector over UDP with a key", func() {
+			conf.UDP, conf.Key = true, "secret"
+			reflectorConf.SenderKeys = map[string]string{"generator": "secret"}
+
+			ping := generate(EchoProbe, UDPTransport, 1)[0]
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.PayloadMatch.Bool).Should(BeTrue())
+			Ω(ping.SequenceMatch.Bool).Should(BeTrue())
+			Ω(ping.ReplySize).Should(Equal(ping.PayloadSize))
+		})
+
+		It("should ping the reflector over HTTP", func() {
+			conf.HTTP = true
+
+			ping := generate(EchoProbe, HTTPTransport, 1)[0]
+			Ω(ping.Target.Name).Should(Equal("reflector"))
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(ping.PayloadMatch.Bool).Should(BeTrue())
+			Ω(ping.ReceiverMatch.Bool).Should(BeTrue())
+		})
+
+		It("should ping the reflector without a concurrency limit", func() {
+			conf.Concurrency = 0
+
+			ping := generate(EchoProbe, UnaryTransport, 1)[0]
+			Ω(ping.Latency.Valid).Should(BeTrue())
+			Ω(conf.Concurrency).Should(Equal(DefaultConcurrency))
+		})
+
+		It("should finish the pings in flight and save them when it is stopped", func() {
+			// Nothing is written until the generator is stopped
+			conf.UDP, conf.FlushInterval = true, 3600
+
+			// The slow reflector signals that a ping is in flight, then waits
+			// before it replies
+			inflight := make(chan struct{}, 1)
+			addr, stopSlow := alteredReflector("slow", func(reply *echo.Reply) {
+				select {
+				case inflight <- struct{}{}:
+				default:
+				}
+				time.Sleep(time.Second)
+			})
+			defer stopSlow()
+
+			stop := start(&Device{Name: "slow", IPAddr: addr})
+			Eventually(inflight, 5*time.Second).Should(Receive())
+
+			pings, err := QueryPings(app.GetStore(), new(PingQuery))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pings).Should(BeEmpty())
+			stop()
+
+			// The ping in flight was replied to rather than cancelled, and it
+			// was saved along with the ping that was sent before it
+			Ω(replied("slow", EchoProbe, UDPTransport)).Should(HaveLen(1))
+
+			pings, err = QueryPings(app.GetStore(), &PingQuery{Target: "slow"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pings).Should(HaveLen(2))
+
+			pings, err = QueryPings(app.GetStore(), &PingQuery{Status: PingSent})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pings).Should(BeEmpty())
+
+			// The sequence of the requests to the slow reflector was saved
+			slow, err := app.GetStore().GetDeviceByName("slow")
+			Ω(err).ShouldNot(HaveOccurred())
+			seq, err := app.GetStore().GetSequence(app.GetDevice(), slow)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(seq.Request).Should(BeNumerically(">=", 1))
+		})
+
+		It("should start and stop pinging the devices added and removed while it runs", func() {
+			conf.UDP = true
+
+			// Count the echo requests that reach the devices over UDP
+			var before, after int64
+			beforeAddr, stopBefore := alteredReflector("before", func(reply *echo.Reply) { atomic.AddInt64(&before, 1) })
+			defer stopBefore()
+			afterAddr, stopAfter := alteredReflector("after", func(reply *echo.Reply) { atomic.AddInt64(&after, 1) })
+			defer stopAfter()
+
+			removed := &Device{Name: "before", IPAddr: beforeAddr}
+			stop := start(removed)
+			Eventually(func() int64 { return atomic.LoadInt64(&before) }, 5*time.Second).Should(BeNumerically(">=", 1))
+			Ω(atomic.LoadInt64(&after)).Should(BeZero())
+
+			// Add a device and remove the other one while the generator runs
+			_, err := app.GetStore().SaveDevice(&Device{Name: "after", IPAddr: afterAddr})
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = removed.Delete(app.GetStore().(*SQLiteStore).DB())
+			Ω(err).ShouldNot(HaveOccurred())
+
+			// The added device is pinged from the next round, and the removed
+			// device is no longer pinged once its worker is stopped
+			Eventually(func() int64 { return atomic.LoadInt64(&after) }, 5*time.Second).Should(BeNumerically(">=", 2))
+			pinged := atomic.LoadInt64(&before)
+			Consistently(func() int64 { return atomic.LoadInt64(&before) }, 2*time.Second).Should(Equal(pinged))
+			stop()
+		})
+
+		It("should not delay the pings to the other devices behind a slow target", func() {
+			conf.UDP = true
+
+			// The blackhole reads the echo requests but never replies, so the
+			// UDP ping to it is in flight until the generator is stopped
+			sock, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer sock.Close()
+
+			stop := start(&Device{Name: "blackhole", IPAddr: sock.LocalAddr().String()})
+
+			// The reflector is pinged every round without waiting on the blackhole
+			Eventually(func() int { return len(replied("reflector", EchoProbe, UDPTransport)) }, 5*time.Second).Should(BeNumerically(">=", 3))
+			Ω(replied("blackhole", EchoProbe, UDPTransport)).Should(BeEmpty())
+			stop()
+
+			// Only one ping was sent to the blackhole since the rounds are
+			// skipped while it is in flight, and it was cancelled on shutdown
+			pings, err := QueryPings(app.GetStore(), &PingQuery{Target: "blackhole"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var udp []*Ping
+			for _, ping := range pings {
+				if ping.Transport == UDPTransport {
+					udp = append(udp, ping)
+				}
+			}
+			Ω(udp).Should(HaveLen(1))
+			Ω(udp[0].Status).Should(Equal(PingCancelled))
+		})
+
+	})
+
+	Describe("reply verification", func() {
+
+		var app *App
+
+		BeforeEach(func() {
+			app = &App{Config: &Config{Name: "generator", Store: MemoryBackend}}
+			Ω(app.ConnectDB()).Should(Succeed())
+		})
+
+		// Helper function that pings the reflector that alters its replies over
+		// UDP and returns the recorded ping.
+		ping := func(alter func(reply *echo.Reply)) *Ping {
+			addr, stop := alteredReflector("target", alter)
+			defer stop()
+
+			target := &Device{Name: "target", IPAddr: addr}
+			_, err := app.GetStore().SaveDevice(target)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			prober, err := app.NewProber(EchoProbe, UDPTransport)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.Ping(context.Background(), target, prober)).Should(Succeed())
+
+			pings, err := QueryPings(app.GetStore(), new(PingQuery))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pings).Should(HaveLen(1))
+			Ω(pings[0].Status).Should(Equal(PingReplied))
+			return pings[0]
+		}
+
+		It("should record replies that match the request", func() {
+			p := ping(func(reply *echo.Reply) {})
+			Ω(p.PayloadMatch.Valid && p.PayloadMatch.Bool).Should(BeTrue())
+			Ω(p.SequenceMatch.Valid && p.SequenceMatch.Bool).Should(BeTrue())
+			Ω(p.ReceiverMatch.Valid && p.ReceiverMatch.Bool).Should(BeTrue())
+			Ω(p.Offset.Valid).Should(BeTrue())
+		})
+
+		It("should flag a reply that echoes another payload", func() {
+			p := ping(func(reply *echo.Reply) { reply.Echo.Payload[0]++ })
+			Ω(p.PayloadMatch.Valid && !p.PayloadMatch.Bool).Should(BeTrue())
+			Ω(p.SequenceMatch.Bool).Should(BeTrue())
+			Ω(p.ReceiverMatch.Bool).Should(BeTrue())
+		})
+
+		It("should flag a reply that echoes another sequence number", func() {
+			p := ping(func(reply *echo.Reply) { reply.Echo.Sequence++ })
+			Ω(p.PayloadMatch.Bool).Should(BeTrue())
+			Ω(p.SequenceMatch.Valid && !p.SequenceMatch.Bool).Should(BeTrue())
+			Ω(p.ReceiverMatch.Bool).Should(BeTrue())
+		})
+
+		It("should record a reply to another ping rather than discard it", func() {
+			p := ping(func(reply *echo.Reply) { reply.Echo.Ping++ })
+			Ω(p.PayloadMatch.Valid && !p.PayloadMatch.Bool).Should(BeTrue())
+			Ω(p.SequenceMatch.Valid && !p.SequenceMatch.Bool).Should(BeTrue())
+			Ω(p.ReceiverMatch.Bool).Should(BeTrue())
+
+			// The delays are not estimated from the timestamps of another ping
+			Ω(p.Offset.Valid).Should(BeFalse())
+		})
+
+		It("should flag a reply from another device", func() {
+			p := ping(func(reply *echo.Reply) { reply.Receiver.Name = "impostor" })
+			Ω(p.PayloadMatch.Bool).Should(BeTrue())
+			Ω(p.SequenceMatch.Bool).Should(BeTrue())
+			Ω(p.ReceiverMatch.Valid && !p.ReceiverMatch.Bool).Should(BeTrue())
+		})
+
+		It("should record the status of a reply that rejects the request", func() {
+			addr, stop := alteredReflector("target", func(reply *echo.Reply) {
+				reply.Error, reply.Code = "the sender is not allowed", uint32(codes.PermissionDenied)
+			})
+			defer stop()
+
+			target := &Device{Name: "target", IPAddr: addr}
+			_, err := app.GetStore().SaveDevice(target)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			prober, err := app.NewProber(EchoProbe, UDPTransport)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.Ping(context.Background(), target, prober)).Should(Succeed())
+
+			pings, err := QueryPings(app.GetStore(), new(PingQuery))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pings).Should(HaveLen(1))
+			Ω(pings[0].Status).Should(Equal(PingRejected))
+			Ω(pings[0].Error.String).Should(ContainSubstring("not allowed"))
+		})
+
+		It("should not check the payload of a reply that omits it", func() {
+			app.Config.Reply = &ReplyConfig{PayloadConfig: PayloadConfig{Size: 16}}
+
+			p := ping(func(reply *echo.Reply) {})
+			Ω(p.PayloadMatch.Valid).Should(BeFalse())
+			Ω(p.SequenceMatch.Bool).Should(BeTrue())
+			Ω(p.ReceiverMatch.Bool).Should(BeTrue())
+		})
+
+	})
+
+})
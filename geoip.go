@@ -33,7 +33,7 @@ func NewMaxMindClient(userid string, license string) *MaxMindClient {
 func (mm *MaxMindClient) NewRequest(ipaddr string) (*http.Request, error) {
 
 	// Check the credentials to ensure they're set.
-	if mm.userid == "" || mm.license == "" {
+	if mm == nil || mm.userid == "" || mm.license == "" {
 		return nil, errors.New("Cannot make GeoIP requests without MaxMind user id and license key!")
 	}
 
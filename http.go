@@ -0,0 +1,173 @@
+package orca
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/orca/echo"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// EchoPath is the path of the HTTP/JSON echo endpoint of the reflector.
+const EchoPath = "/echo"
+
+// EchoHTTP handles POST requests to the echo endpoint: it accepts a JSON
+// encoded echo request and replies with the JSON encoded echo reply, created
+// in the same way as the gRPC replies, for clients that only speak HTTP.
+func (app *App) EchoHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// Store the RECV timestamp before any work
+	recv := time.Now()
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// JSON encodes the payload with base64, so allow for the larger encoding
+	in := new(echo.Request)
+	if err := json.NewDecoder(io.LimitReader(r.Body, 2*MaxDatagramSize)).Decode(in); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode echo request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	reply, err := app.reflect(in, recv)
+	if err != nil {
+		http.Error(w, grpc.ErrorDesc(err), httpStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// Helper function that maps the gRPC status of a rejected echo request to the
+// HTTP status of the response.
+func httpStatus(err error) int {
+	switch grpc.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.DeadlineExceeded:
+		return http.StatusRequestTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Helper function that creates the HTTP client used by the generator. In cold
+// mode connections are not kept alive so that every ping dials the device.
+func newHTTPClient(mode string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: mode == ColdMode,
+		},
+	}
+}
+
+// Helper function that posts the echo request as JSON to the HTTP echo
+// endpoint of the target of the ping and decodes the reply. The time to
+// connect (if a connection was dialed), to the first byte of the response,
+// and to the full reply are stored on the ping in milliseconds.
+func invokeEchoHTTP(ctx context.Context, client *http.Client, url string, request *echo.Request, ping *Ping) (*echo.Reply, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Trace the connection and the first byte of the response
+	var (
+		mu        sync.Mutex
+		connected time.Time
+		dialed    time.Duration
+		firstByte time.Duration
+	)
+
+	started := time.Now()
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connected = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			dialed = time.Since(connected)
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			firstByte = time.Since(started)
+			mu.Unlock()
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	rep, err := client.Do(req)
+	if err != nil {
+		// Report timeouts and cancellations from the context
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer rep.Body.Close()
+
+	// Report rejections with the same status as the gRPC transports
+	if rep.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(rep.Body, 512))
+		switch rep.StatusCode {
+		case http.StatusBadRequest:
+			return nil, grpc.Errorf(codes.InvalidArgument, "%s", bytes.TrimSpace(msg))
+		case http.StatusUnauthorized:
+			return nil, grpc.Errorf(codes.Unauthenticated, "%s", bytes.TrimSpace(msg))
+		case http.StatusForbidden:
+			return nil, grpc.Errorf(codes.PermissionDenied, "%s", bytes.TrimSpace(msg))
+		case http.StatusRequestTimeout:
+			return nil, grpc.Errorf(codes.DeadlineExceeded, "%s", bytes.TrimSpace(msg))
+		default:
+			return nil, fmt.Errorf("HTTP %d: %s", rep.StatusCode, bytes.TrimSpace(msg))
+		}
+	}
+
+	reply := new(echo.Reply)
+	if err := json.NewDecoder(rep.Body).Decode(reply); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("Could not decode the reply: %s", err)
+	}
+
+	ping.RPCLatency = milliseconds(time.Since(started))
+
+	mu.Lock()
+	if dialed > 0 {
+		ping.DialLatency = milliseconds(dialed)
+	}
+	if firstByte > 0 {
+		ping.FirstByte = milliseconds(firstByte)
+	}
+	mu.Unlock()
+
+	return reply, nil
+}
@@ -0,0 +1,159 @@
+package orca
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Directions in which a lost ping was lost, determined by whether or not the
+// reflector journaled the echo request.
+const (
+	LostForward = "forward" // The request never reached the reflector
+	LostReverse = "reverse" // The reflector received the request but the reply was lost
+)
+
+// Reflection is a journal record of an echo request received by a reflector.
+// Reflections are stored in the reflections table of the reflector database
+// so that lost pings can be matched against the requests that arrived.
+type Reflection struct {
+	ID          int64     // Unique ID of the record
+	Sender      string    // The name of the device that sent the request
+	Receiver    string    // The name of the device that received the request
+	Request     int64     // The request sequence number of the sender
+	Ping        int64     // The ID of the ping in the database of the sender
+	Sent        time.Time // The time the request was sent by the sender's clock
+	Recv        time.Time // The time the request was received by the local clock
+	PayloadSize int64     // The size of the request payload in bytes
+	ReplySize   int64     // The size of the reply payload in bytes, including the echoed payload
+}
+
+// Loss is a ping that was not replied to, along with the direction in which
+// it was lost according to the journal of the reflector.
+type Loss struct {
+	Ping      *Ping  // The lost ping (only the ID, devices, request, status, and sent time)
+	Direction string // Either forward or reverse
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Reflection Methods
+/////////////////////////////////////////////////////////////////////////////
+
+// Get a reflection from the database by ID and populate the struct fields.
+func (r *Reflection) Get(id int64, db Executor) error {
+	row := db.QueryRow("SELECT * FROM reflections WHERE id = $1", id)
+	err := row.Scan(&r.ID, &r.Sender, &r.Receiver, &r.Request, &r.Ping, &r.Sent, &r.Recv, &r.PayloadSize, &r.ReplySize)
+
+	return err
+}
+
+// Save a reflection struct to the database. This function checks if the
+// reflection has an ID or not. If it does, it will execute a SQL UPDATE,
+// otherwise it will execute a SQL INSERT. Returns a boolean if the reflection
+// was inserted.
+func (r *Reflection) Save(db Executor) (bool, error) {
+	if r.ID > 0 {
+		// This is the UPDATE method so return false.
+		query := "UPDATE reflections SET sender=$1, receiver=$2, request=$3, ping_id=$4, sent=$5, recv=$6, payload_size=$7, reply_size=$8 WHERE id = $9"
+		_, err := db.Exec(query, r.Sender, r.Receiver, r.Request, r.Ping, r.Sent, r.Recv, r.PayloadSize, r.ReplySize, r.ID)
+
+		return false, err
+	}
+
+	// This is the INSERT method, so return true
+	query := "INSERT INTO reflections (sender, receiver, request, ping_id, sent, recv, payload_size, reply_size) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	res, err := db.Exec(query, r.Sender, r.Receiver, r.Request, r.Ping, r.Sent, r.Recv, r.PayloadSize, r.ReplySize)
+	if err != nil {
+		return false, err
+	}
+
+	// Look up the last inserted ID from sqlite3
+	rid, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+
+	// Store the ID and return
+	r.ID = rid
+	return true, err
+}
+
+// Delete a reflection from the database. Returns true if the number of rows
+// affected is 1 or false otherwise.
+func (r *Reflection) Delete(db Executor) (bool, error) {
+	return deleteFromDatabase(db, "reflections", r.ID)
+}
+
+// String returns a pretty representation of the reflection
+func (r *Reflection) String() string {
+	output := "%s -> %s order=%d ping=%d %d bytes"
+	return fmt.Sprintf(output, r.Sender, r.Receiver, r.Request, r.Ping, r.PayloadSize)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Loss Analysis
+/////////////////////////////////////////////////////////////////////////////
+
+// FetchLosses attaches the reflector database at the given path to the local
+// (generator) database and returns the echo pings that failed to the devices
+// that are journaled in it. A ping that appears in the reflections of the
+// reflector was lost in the reverse direction; otherwise it was lost in the
+// forward direction before it reached the reflector.
+func (app *App) FetchLosses(path string) ([]*Loss, error) {
+	var losses []*Loss
+
+	// The losses are only stored in the database of the generator
+	if app.sqlDB() == nil {
+		return losses, errors.New("Losses can only be fetched from the sqlite store")
+	}
+
+	// Attached databases are per connection, so use a single connection
+	db, err := sql.Open("sqlite3", sqliteDSN(app.Config.DBPath))
+	if err != nil {
+		return losses, err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("ATTACH DATABASE $1 AS journal", path); err != nil {
+		return losses, err
+	}
+
+	// Construct the losses query
+	query := "SELECT p.id, p.request, p.status, p.sent, s.name, t.name, r.id IS NOT NULL FROM pings p "
+	query += "   JOIN devices s ON p.source_id = s.id "
+	query += "   JOIN devices t ON p.target_id = t.id "
+	query += "   LEFT JOIN journal.reflections r ON r.ping_id = p.id AND r.sender = s.name AND r.receiver = t.name "
+	query += "WHERE p.status NOT IN ($1, $2) AND p.probe = $3 "
+	query += "   AND t.name IN (SELECT DISTINCT receiver FROM journal.reflections) "
+	query += "ORDER BY p.id"
+
+	rows, err := db.Query(query, PingSent, PingReplied, EchoProbe)
+	if err != nil {
+		return losses, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var received bool
+		p := &Ping{Source: new(Device), Target: new(Device)}
+		if err := rows.Scan(&p.ID, &p.Request, &p.Status, &p.Sent, &p.Source.Name, &p.Target.Name, &received); err != nil {
+			return losses, err
+		}
+
+		loss := &Loss{Ping: p, Direction: LostForward}
+		if received {
+			loss.Direction = LostReverse
+		}
+
+		losses = append(losses, loss)
+	}
+
+	return losses, rows.Err()
+}
+
+// String returns a pretty representation of the loss
+func (l *Loss) String() string {
+	return fmt.Sprintf("%s (ping %d) lost %s", l.Ping.String(), l.Ping.ID, l.Direction)
+}
@@ -0,0 +1,111 @@
+package orca_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Journal", func() {
+
+	var dir string
+	var generator, reflector *App
+
+	// Helper function that creates an app with a migrated database in the
+	// temporary directory.
+	newApp := func(name string) *App {
+		app := &App{Config: &Config{Name: name, DBPath: filepath.Join(dir, name+".db")}}
+		Ω(app.ConnectDB()).Should(Succeed())
+		Ω(app.CreateDB()).Should(Succeed())
+		return app
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		generator = newApp("alpha")
+		reflector = newApp("bravo")
+	})
+
+	AfterEach(func() {
+		generator.GetStore().Close()
+		reflector.GetStore().Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should journal a reflection", func() {
+		sent := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		reflection := &Reflection{
+			Sender: "alpha", Receiver: "bravo", Request: 3, Ping: 7,
+			Sent: sent, Recv: sent.Add(time.Millisecond), PayloadSize: 50, ReplySize: 64,
+		}
+		Ω(reflector.GetStore().SaveReflection(reflection)).Should(BeTrue())
+
+		fetched := new(Reflection)
+		Ω(fetched.Get(reflection.ID, reflector.GetStore().(*SQLiteStore).DB())).Should(Succeed())
+		Ω(fetched.Sender).Should(Equal("alpha"))
+		Ω(fetched.Receiver).Should(Equal("bravo"))
+		Ω(fetched.Request).Should(BeEquivalentTo(3))
+		Ω(fetched.Ping).Should(BeEquivalentTo(7))
+		Ω(fetched.Sent.Equal(sent)).Should(BeTrue())
+		Ω(fetched.PayloadSize).Should(BeEquivalentTo(50))
+		Ω(fetched.ReplySize).Should(BeEquivalentTo(64))
+	})
+
+	It("should tell the direction of the lost pings from the journal", func() {
+		store := generator.GetStore()
+		alpha, bravo, charlie := &Device{Name: "alpha"}, &Device{Name: "bravo"}, &Device{Name: "charlie"}
+		for _, device := range []*Device{alpha, bravo, charlie} {
+			_, err := store.SaveDevice(device)
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+
+		pings := []*Ping{
+			{Target: bravo, Request: 1, Status: PingReplied, Probe: EchoProbe},
+			{Target: bravo, Request: 2, Status: PingTimeout, Probe: EchoProbe},
+			{Target: bravo, Request: 3, Status: PingTimeout, Probe: EchoProbe},
+			{Target: bravo, Status: PingRefused, Probe: TCPProbe},
+			{Target: charlie, Request: 1, Status: PingTimeout, Probe: EchoProbe},
+		}
+		for _, ping := range pings {
+			ping.Source, ping.Sent = alpha, time.Now()
+			Ω(store.SavePing(ping)).Should(BeTrue())
+		}
+
+		// The reflector journaled the requests of the first two pings
+		for _, ping := range pings[:2] {
+			reflection := &Reflection{Sender: "alpha", Receiver: "bravo", Request: ping.Request, Ping: ping.ID, Sent: ping.Sent, Recv: time.Now()}
+			Ω(reflector.GetStore().SaveReflection(reflection)).Should(BeTrue())
+		}
+
+		// Only the echo pings to the devices in the journal are reported
+		losses, err := generator.FetchLosses(reflector.Config.DBPath)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(losses).Should(HaveLen(2))
+
+		Ω(losses[0].Ping.ID).Should(Equal(pings[1].ID))
+		Ω(losses[0].Ping.Target.Name).Should(Equal("bravo"))
+		Ω(losses[0].Direction).Should(Equal(LostReverse))
+
+		Ω(losses[1].Ping.ID).Should(Equal(pings[2].ID))
+		Ω(losses[1].Ping.Status).Should(Equal(PingTimeout))
+		Ω(losses[1].Direction).Should(Equal(LostForward))
+	})
+
+	It("should not fetch losses from the memory store", func() {
+		app := &App{Config: &Config{Name: "alpha", Store: MemoryBackend}}
+		Ω(app.ConnectDB()).Should(Succeed())
+
+		_, err := app.FetchLosses(reflector.Config.DBPath)
+		Ω(err).Should(MatchError(ContainSubstring("sqlite store")))
+	})
+
+})
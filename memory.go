@@ -0,0 +1,432 @@
+package orca
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the models in memory, so that the reflector and the
+// generator can run (and be tested) without a database file. Models are
+// copied when they are saved and fetched so that the models held by the
+// application are not shared with the store. The records of every table are
+// kept in the order of their IDs (pings by ID since their IDs can be
+// reserved), and like the database, the store enforces the unique names of
+// devices, IP addresses of locations, and pairs of the sequences and clock
+// offsets. Nothing is kept after the process exits.
+type MemoryStore struct {
+	sync.RWMutex
+	devices     []*Device
+	locations   []*Location
+	pings       map[int64]*Ping
+	lastPing    int64
+	sequences   []*Sequence
+	offsets     []*ClockOffset
+	reflections []*Reflection
+}
+
+// NewMemoryStore creates an empty store in memory.
+func NewMemoryStore() *MemoryStore {
+	return new(MemoryStore)
+}
+
+// Close the store, which releases nothing.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Devices and Locations
+/////////////////////////////////////////////////////////////////////////////
+
+// GetDevice by ID.
+func (s *MemoryStore) GetDevice(id int64) (*Device, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.device(id)
+}
+
+// GetDeviceByName returns the device with the unique name.
+func (s *MemoryStore) GetDeviceByName(name string) (*Device, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, d := range s.devices {
+		if d.Name == name {
+			return copyDevice(d), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveDevice inserts or updates the device.
+func (s *MemoryStore) SaveDevice(device *Device) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, d := range s.devices {
+		if d.Name == device.Name && d.ID != device.ID {
+			return false, errors.New("UNIQUE constraint failed: devices.name")
+		}
+	}
+
+	if device.ID > 0 {
+		device.Updated = time.Now()
+		if idx := device.ID - 1; idx < int64(len(s.devices)) {
+			s.devices[idx] = copyDevice(device)
+		}
+		return false, nil
+	}
+
+	device.Created = time.Now()
+	device.Updated = device.Created
+	device.ID = int64(len(s.devices)) + 1
+	s.devices = append(s.devices, copyDevice(device))
+	return true, nil
+}
+
+// ListDevices returns all devices with the most recently created first.
+func (s *MemoryStore) ListDevices() (Devices, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	devices := make(Devices, 0, len(s.devices))
+	for i := len(s.devices) - 1; i >= 0; i-- {
+		devices = append(devices, copyDevice(s.devices[i]))
+	}
+	return devices, nil
+}
+
+// GetLocation by ID.
+func (s *MemoryStore) GetLocation(id int64) (*Location, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.location(id)
+}
+
+// GetLocationByIP returns the location with the unique IP address.
+func (s *MemoryStore) GetLocationByIP(ipaddr string) (*Location, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, loc := range s.locations {
+		if loc.IPAddr == ipaddr {
+			stored := *loc
+			return &stored, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveLocation inserts or updates the location.
+func (s *MemoryStore) SaveLocation(loc *Location) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, l := range s.locations {
+		if l.IPAddr == loc.IPAddr && l.ID != loc.ID {
+			return false, errors.New("UNIQUE constraint failed: locations.ipaddr")
+		}
+	}
+
+	stored := *loc
+	if loc.ID > 0 {
+		loc.Updated = time.Now()
+		stored.Updated = loc.Updated
+		if idx := loc.ID - 1; idx < int64(len(s.locations)) {
+			s.locations[idx] = &stored
+		}
+		return false, nil
+	}
+
+	loc.Created = time.Now()
+	loc.Updated = loc.Created
+	loc.ID = int64(len(s.locations)) + 1
+	stored.ModelMeta = loc.ModelMeta
+	s.locations = append(s.locations, &stored)
+	return true, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Pings
+/////////////////////////////////////////////////////////////////////////////
+
+// GetPing by ID along with its devices and location.
+func (s *MemoryStore) GetPing(id int64) (*Ping, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	stored, ok := s.pings[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.ping(stored)
+}
+
+// SavePing inserts or updates the ping. Only the IDs of the devices and the
+// location of the ping are stored; the location is optional.
+func (s *MemoryStore) SavePing(ping *Ping) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	stored := *ping
+	stored.Source = &Device{ModelMeta: ModelMeta{ID: ping.Source.ID}}
+	stored.Target = &Device{ModelMeta: ModelMeta{ID: ping.Target.ID}}
+	stored.Location = nil
+	if ping.Location != nil && ping.Location.ID > 0 {
+		stored.Location = &Location{ModelMeta: ModelMeta{ID: ping.Location.ID}}
+	}
+
+	if s.pings == nil {
+		s.pings = make(map[int64]*Ping)
+	}
+
+	// Like the database, only update the ping itself (the same source and sent
+	// timestamp) and fail to insert a ping with the ID of another ping
+	if existing, ok := s.pings[ping.ID]; ok {
+		if existing.Source.ID != ping.Source.ID || !existing.Sent.Equal(ping.Sent) {
+			return false, errors.New("UNIQUE constraint failed: pings.id")
+		}
+		s.pings[ping.ID] = &stored
+		return false, nil
+	}
+
+	// Insert the ping with its reserved ID or the next ID
+	if ping.ID <= 0 {
+		ping.ID = s.lastPing + 1
+		stored.ID = ping.ID
+	}
+
+	if ping.ID > s.lastPing {
+		s.lastPing = ping.ID
+	}
+
+	s.pings[ping.ID] = &stored
+	return true, nil
+}
+
+// LastPingID returns the largest ID of the stored pings.
+func (s *MemoryStore) LastPingID() (int64, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.lastPing, nil
+}
+
+// EachPing calls fn with the page of the pings that match the query, ordered by
+// ID, and stops at the first error that fn returns. The store is not locked
+// while fn is called, so fn may use the store.
+func (s *MemoryStore) EachPing(q *PingQuery, fn func(*Ping) error) error {
+	for _, ping := range s.queryPings(q) {
+		if err := fn(ping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function that returns the page of the pings that match the query.
+func (s *MemoryStore) queryPings(q *PingQuery) []*Ping {
+	s.RLock()
+	defer s.RUnlock()
+
+	ids := make([]int64, 0, len(s.pings))
+	for id := range s.pings {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var pings []*Ping
+	for _, id := range ids {
+		// Like the join in the database, skip pings without devices
+		ping, err := s.ping(s.pings[id])
+		if err != nil {
+			continue
+		}
+
+		if q.Match(ping) {
+			pings = append(pings, ping)
+		}
+	}
+	return q.Paginate(pings)
+}
+
+// PingSequences returns the echo pings along with their devices, ordered by
+// pair and request.
+func (s *MemoryStore) PingSequences() ([]*Ping, error) {
+	pings := s.queryPings(&PingQuery{Probe: EchoProbe})
+
+	sort.SliceStable(pings, func(i, j int) bool {
+		a, b := pings[i], pings[j]
+		if a.Source.ID != b.Source.ID {
+			return a.Source.ID < b.Source.ID
+		}
+		if a.Target.ID != b.Target.ID {
+			return a.Target.ID < b.Target.ID
+		}
+		return a.Request < b.Request
+	})
+
+	return pings, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Sequences, Clock Offsets and Reflections
+/////////////////////////////////////////////////////////////////////////////
+
+// GetSequence of the source and target devices.
+func (s *MemoryStore) GetSequence(source, target *Device) (*Sequence, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, seq := range s.sequences {
+		if seq.SourceID == source.ID && seq.TargetID == target.ID {
+			stored := *seq
+			return &stored, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveSequence inserts or updates the sequence.
+func (s *MemoryStore) SaveSequence(seq *Sequence) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, other := range s.sequences {
+		if other.SourceID == seq.SourceID && other.TargetID == seq.TargetID && other.ID != seq.ID {
+			return false, errors.New("UNIQUE constraint failed: sequences.source_id, sequences.target_id")
+		}
+	}
+
+	if seq.ID > 0 {
+		seq.Updated = time.Now()
+		if idx := seq.ID - 1; idx < int64(len(s.sequences)) {
+			stored := *seq
+			s.sequences[idx] = &stored
+		}
+		return false, nil
+	}
+
+	seq.Created = time.Now()
+	seq.Updated = seq.Created
+	seq.ID = int64(len(s.sequences)) + 1
+	stored := *seq
+	s.sequences = append(s.sequences, &stored)
+	return true, nil
+}
+
+// GetClockOffset of the source and target devices.
+func (s *MemoryStore) GetClockOffset(source, target *Device) (*ClockOffset, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, offset := range s.offsets {
+		if offset.SourceID == source.ID && offset.TargetID == target.ID {
+			stored := *offset
+			return &stored, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveClockOffset inserts or updates the clock offset.
+func (s *MemoryStore) SaveClockOffset(offset *ClockOffset) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, other := range s.offsets {
+		if other.SourceID == offset.SourceID && other.TargetID == offset.TargetID && other.ID != offset.ID {
+			return false, errors.New("UNIQUE constraint failed: clock_offsets.source_id, clock_offsets.target_id")
+		}
+	}
+
+	if offset.ID > 0 {
+		offset.Updated = time.Now()
+		if idx := offset.ID - 1; idx < int64(len(s.offsets)) {
+			stored := *offset
+			s.offsets[idx] = &stored
+		}
+		return false, nil
+	}
+
+	offset.Created = time.Now()
+	offset.Updated = offset.Created
+	offset.ID = int64(len(s.offsets)) + 1
+	stored := *offset
+	s.offsets = append(s.offsets, &stored)
+	return true, nil
+}
+
+// SaveReflection inserts or updates the reflection.
+func (s *MemoryStore) SaveReflection(reflection *Reflection) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	stored := *reflection
+	if reflection.ID > 0 {
+		if idx := reflection.ID - 1; idx < int64(len(s.reflections)) {
+			s.reflections[idx] = &stored
+		}
+		return false, nil
+	}
+
+	reflection.ID = int64(len(s.reflections)) + 1
+	stored.ID = reflection.ID
+	s.reflections = append(s.reflections, &stored)
+	return true, nil
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Helper Functions
+/////////////////////////////////////////////////////////////////////////////
+
+// Helper function that returns a copy of the device with the ID, which must
+// be called with the lock held.
+func (s *MemoryStore) device(id int64) (*Device, error) {
+	if id < 1 || id > int64(len(s.devices)) {
+		return nil, ErrNotFound
+	}
+	return copyDevice(s.devices[id-1]), nil
+}
+
+// Helper function that returns a copy of the location with the ID, which
+// must be called with the lock held.
+func (s *MemoryStore) location(id int64) (*Location, error) {
+	if id < 1 || id > int64(len(s.locations)) {
+		return nil, ErrNotFound
+	}
+
+	stored := *s.locations[id-1]
+	return &stored, nil
+}
+
+// Helper function that returns a copy of the stored ping with copies of its
+// devices and location, which must be called with the lock held. Like the
+// LEFT JOIN in the database, the location is nil if it is not stored.
+func (s *MemoryStore) ping(stored *Ping) (*Ping, error) {
+	var err error
+	ping := *stored
+
+	if ping.Source, err = s.device(stored.Source.ID); err != nil {
+		return nil, err
+	}
+
+	if ping.Target, err = s.device(stored.Target.ID); err != nil {
+		return nil, err
+	}
+
+	ping.Location = nil
+	if stored.Location != nil {
+		ping.Location, _ = s.location(stored.Location.ID)
+	}
+
+	return &ping, nil
+}
+
+// Helper function that copies a device without its cached protocol buffer.
+func copyDevice(device *Device) *Device {
+	stored := *device
+	stored.echo = nil
+	return &stored
+}
@@ -0,0 +1,344 @@
+package orca
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MigrationsDir is the directory of the numbered SQL migrations stored as
+// binary data in the application. Migrations are named NNNN_name.sql and are
+// applied in the order of their numbers.
+const MigrationsDir = "fixtures/migrations"
+
+// Migration is a numbered change to the database schema. The version of the
+// schema of a database is the number of the last migration applied to it,
+// which is stored in the schema_version table.
+type Migration struct {
+	Version int    // The number of the migration
+	Name    string // The name of the migration without the number
+	Path    string // The path to the SQL of the migration in the assets
+}
+
+// Migrations returns the migrations stored in the application ordered by
+// their version.
+func Migrations() ([]*Migration, error) {
+	names, err := AssetDir(MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The numbers are zero padded, so the names sort in the order of versions
+	sort.Strings(names)
+
+	var migrations []*Migration
+	for _, name := range names {
+		if path.Ext(name) != ".sql" {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(name, ".sql"), "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("Could not parse the version of migration %s", name)
+		}
+
+		if n := len(migrations); n > 0 && migrations[n-1].Version >= version {
+			return nil, fmt.Errorf("Migration %s is out of order", name)
+		}
+
+		migrations = append(migrations, &Migration{
+			Version: version,
+			Name:    parts[1],
+			Path:    path.Join(MigrationsDir, name),
+		})
+	}
+
+	return migrations, nil
+}
+
+// LatestVersion returns the version of the last migration, which is the
+// version of the schema that the application requires.
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// SQL loads the statements of the migration from the binary data.
+func (m *Migration) SQL() (string, error) {
+	data, err := Asset(m.Path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// String returns the version and name of the migration.
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d %s", m.Version, m.Name)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Migrating the Database
+/////////////////////////////////////////////////////////////////////////////
+
+// The tables and columns that were added by the migrations, which identify
+// the version of a database created from the single schema before the
+// migrations were added (and so without a schema_version table). The schema
+// changed along with the migrations, so these databases can be at any of the
+// versions.
+var legacyVersions = []struct {
+	version int
+	table   string
+	column  string // Empty if the version added the table
+}{
+	{1, "devices", ""},
+	{2, "pings", "status"},
+	{3, "clock_offsets", ""},
+	{4, "reflections", ""},
+	{5, "pings", "probe"},
+	{6, "pings", "payload_size"},
+}
+
+// SchemaVersion returns the version of the schema of the database, or 0 if
+// the database is empty. Stores that are not databases are always at the
+// latest version. Databases created from the single schema before the
+// migrations were added have no schema_version table, so their version is
+// detected from the tables and columns they have.
+func (app *App) SchemaVersion() (int, error) {
+	// Stores that are not databases do not need to be migrated
+	if app.sqlDB() == nil {
+		return LatestVersion()
+	}
+
+	versioned, err := app.tableExists("schema_version")
+	if err != nil {
+		return 0, err
+	}
+
+	if !versioned {
+		return app.legacyVersion()
+	}
+
+	var version sql.NullInt64
+	if err := app.sqlDB().QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// CheckSchema returns an error if the schema of the database is not at the
+// version required by the application, so that the daemons do not start
+// against a database that has not been migrated.
+func (app *App) CheckSchema() error {
+	version, err := app.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version < latest:
+		return fmt.Errorf("The database schema is at version %d but version %d is required, run orca migrate", version, latest)
+	case version > latest:
+		return fmt.Errorf("The database schema is at version %d which is newer than version %d of this orca", version, latest)
+	default:
+		return nil
+	}
+}
+
+// Migrate applies the migrations after the version of the schema of the
+// database up to and including the given version, or all of them if the
+// version is 0. Every migration is applied in a transaction along with its
+// version. If dryRun is true, nothing is applied. Returns the migrations that
+// were applied (or would be applied).
+func (app *App) Migrate(to int, dryRun bool) ([]*Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := LatestVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if to == 0 {
+		to = latest
+	}
+
+	if to < 0 || to > latest {
+		return nil, fmt.Errorf("Unknown schema version %d (the latest version is %d)", to, latest)
+	}
+
+	version, err := app.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if to < version {
+		return nil, fmt.Errorf("Cannot migrate the database from version %d down to version %d", version, to)
+	}
+
+	// Find the migrations that have not been applied yet
+	var pending []*Migration
+	for _, m := range migrations {
+		if m.Version > version && m.Version <= to {
+			pending = append(pending, m)
+		}
+	}
+
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	if err := app.createSchemaVersion(version); err != nil {
+		return nil, err
+	}
+
+	for i, m := range pending {
+		if err := app.applyMigration(m); err != nil {
+			return pending[:i], fmt.Errorf("Could not apply migration %s: %s", m, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// Helper function that detects the version of a database without a
+// schema_version table from the tables and columns it has. Since every version
+// includes the changes of the versions before it, a database that has the
+// changes of a version but not of an earlier one was not created by orca and
+// cannot be migrated.
+func (app *App) legacyVersion() (int, error) {
+	version := 0
+	for _, legacy := range legacyVersions {
+		var found bool
+		var err error
+		if legacy.column == "" {
+			found, err = app.tableExists(legacy.table)
+		} else {
+			found, err = app.columnExists(legacy.table, legacy.column)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case found && version < legacy.version-1:
+			return 0, fmt.Errorf("Could not detect the schema version of the database: it has the changes of version %d but not of version %d", legacy.version, version+1)
+		case found:
+			version = legacy.version
+		}
+	}
+
+	return version, nil
+}
+
+// Helper function that creates the schema_version table if it does not exist
+// and records the version of a database created before the migrations.
+func (app *App) createSchemaVersion(version int) error {
+	query := "CREATE TABLE IF NOT EXISTS schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied DATETIME)"
+	if _, err := app.sqlDB().Exec(query); err != nil {
+		return err
+	}
+
+	// Databases without versions are empty or at the detected version
+	if version > 0 {
+		query = "INSERT OR IGNORE INTO schema_version (version, name, applied) VALUES ($1, $2, $3)"
+		if _, err := app.sqlDB().Exec(query, version, "detected", time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Helper function that executes the statements of the migration and records
+// its version in a single transaction.
+func (app *App) applyMigration(m *Migration) error {
+	stmts, err := m.SQL()
+	if err != nil {
+		return err
+	}
+
+	tx, err := app.sqlDB().Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(stmts); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := "INSERT INTO schema_version (version, name, applied) VALUES ($1, $2, $3)"
+	if _, err := tx.Exec(query, m.Version, m.Name, time.Now()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Helper function that checks if the table exists in the database.
+func (app *App) tableExists(name string) (bool, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1"
+	if err := app.sqlDB().QueryRow(query, name).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Helper function that checks if the table has the column.
+func (app *App) columnExists(table, column string) (bool, error) {
+	rows, err := app.sqlDB().Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notnull, pk int
+			name, kind       string
+			value            sql.NullString
+		)
+
+		if err := rows.Scan(&cid, &name, &kind, &notnull, &value, &pk); err != nil {
+			return false, err
+		}
+
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// Helper function that returns the connection to the database of the SQLite
+// store, or nil if the models are not stored in a database.
+func (app *App) sqlDB() *sql.DB {
+	if store, ok := app.store.(*SQLiteStore); ok {
+		return store.DB()
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package orca_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrations", func() {
+
+	var dir string
+	var app *App
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		app = &App{Config: &Config{DBPath: filepath.Join(dir, "orca.db")}}
+		Ω(app.ConnectDB()).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		app.GetStore().Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should order the migrations by version", func() {
+		migrations, err := Migrations()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(migrations).ShouldNot(BeEmpty())
+		Ω(migrations[0].Version).Should(Equal(1))
+		Ω(migrations[0].Name).Should(Equal("baseline"))
+
+		for i, m := range migrations {
+			Ω(m.Version).Should(Equal(i + 1))
+			Ω(m.SQL()).ShouldNot(BeEmpty())
+		}
+
+		latest, err := LatestVersion()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(latest).Should(Equal(len(migrations)))
+	})
+
+	It("should migrate an empty database to the latest version", func() {
+		latest, _ := LatestVersion()
+		Ω(app.SchemaVersion()).Should(Equal(0))
+		Ω(app.CheckSchema()).ShouldNot(Succeed())
+
+		migrations, err := app.Migrate(0, false)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(migrations).Should(HaveLen(latest))
+		Ω(app.SchemaVersion()).Should(Equal(latest))
+		Ω(app.CheckSchema()).Should(Succeed())
+
+		// Migrating again does nothing
+		Ω(app.Migrate(0, false)).Should(BeEmpty())
+	})
+
+	It("should migrate to a version and not apply a dry run", func() {
+		latest, _ := LatestVersion()
+
+		migrations, err := app.Migrate(2, false)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(migrations).Should(HaveLen(2))
+		Ω(app.SchemaVersion()).Should(Equal(2))
+
+		migrations, err = app.Migrate(0, true)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(migrations).Should(HaveLen(latest - 2))
+		Ω(migrations[0].Version).Should(Equal(3))
+		Ω(app.SchemaVersion()).Should(Equal(2))
+
+		_, err = app.Migrate(1, false)
+		Ω(err).Should(MatchError(ContainSubstring("down to version 1")))
+		_, err = app.Migrate(latest+1, false)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	// Helper function that creates the schema of a version without the
+	// schema_version table, like the databases created before the migrations.
+	legacy := func(version int) {
+		migrations, err := Migrations()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		for _, m := range migrations[:version] {
+			stmts, err := m.SQL()
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = app.GetStore().(*SQLiteStore).DB().Exec(stmts)
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+	}
+
+	It("should migrate a database created before the migrations", func() {
+		legacy(1)
+
+		Ω(app.SchemaVersion()).Should(Equal(1))
+		Ω(app.CheckSchema()).Should(MatchError(ContainSubstring("run orca migrate")))
+
+		migrations, err := app.Migrate(0, false)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(migrations[0].Version).Should(Equal(2))
+		Ω(app.CheckSchema()).Should(Succeed())
+	})
+
+	It("should read the pings of a database created before the migrations", func() {
+		legacy(1)
+		db := app.GetStore().(*SQLiteStore).DB()
+		_, err := db.Exec("INSERT INTO devices (id, name, ipaddr, domain, sequence, created, updated) VALUES (1, 'alpha', '', '', 0, $1, $1), (2, 'bravo', '', '', 0, $1, $1)", time.Now())
+		Ω(err).ShouldNot(HaveOccurred())
+		_, err = db.Exec("INSERT INTO pings (id, source_id, target_id, request, response, sent, recv, latency) VALUES (1, 1, 2, 1, 1, $1, $1, 12.5), (2, 1, 2, 2, 0, $1, $1, NULL)", time.Now())
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(app.Migrate(0, false)).ShouldNot(BeEmpty())
+
+		ping, err := app.GetStore().GetPing(1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ping.Mode).Should(Equal(ColdMode))
+		Ω(ping.Transport).Should(Equal(UnaryTransport))
+		Ω(ping.Probe).Should(Equal(EchoProbe))
+		Ω(ping.Latency.Float64).Should(Equal(12.5))
+		Ω(ping.Status).Should(Equal(PingReplied))
+
+		pings, err := QueryPings(app.GetStore(), &PingQuery{Status: PingReplied})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(pings).Should(HaveLen(1))
+		Ω(pings[0].ID).Should(BeEquivalentTo(1))
+	})
+
+	It("should detect the version of a database created before the migrations", func() {
+		legacy(4)
+		Ω(app.SchemaVersion()).Should(Equal(4))
+
+		migrations, err := app.Migrate(0, false)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(migrations[0].Version).Should(Equal(5))
+		Ω(app.CheckSchema()).Should(Succeed())
+	})
+
+	It("should refuse to migrate a database of an unknown version", func() {
+		legacy(1)
+		_, err := app.GetStore().(*SQLiteStore).DB().Exec("CREATE TABLE reflections (id INTEGER PRIMARY KEY)")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = app.SchemaVersion()
+		Ω(err).Should(MatchError(ContainSubstring("not of version 2")))
+		_, err = app.Migrate(0, false)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should store and fetch pings in a migrated database", func() {
+		Ω(app.CreateDB()).Should(Succeed())
+		store := app.GetStore()
+
+		source := &Device{Name: "alpha"}
+		target := &Device{Name: "bravo"}
+		location := &Location{IPAddr: "127.0.0.1"}
+		Ω(store.SaveDevice(source)).Should(BeTrue())
+		Ω(store.SaveDevice(target)).Should(BeTrue())
+		Ω(store.SaveLocation(location)).Should(BeTrue())
+
+		ping := &Ping{
+			Source: source, Target: target, Location: location, Request: 1,
+			Sent: time.Now(), Status: PingReplied, Probe: EchoProbe, Transport: UnaryTransport,
+			PayloadSize: 50, ReplySize: 4096,
+		}
+		ping.ReceiverMatch.Valid = true
+		ping.ReceiverMatch.Bool = true
+		Ω(store.SavePing(ping)).Should(BeTrue())
+
+		fetched, err := store.GetPing(ping.ID)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(fetched.Target.Name).Should(Equal("bravo"))
+		Ω(fetched.Probe).Should(Equal(EchoProbe))
+		Ω(fetched.PayloadSize).Should(BeEquivalentTo(50))
+		Ω(fetched.ReplySize).Should(BeEquivalentTo(4096))
+		Ω(fetched.ReceiverMatch.Bool).Should(BeTrue())
+		Ω(fetched.PayloadMatch.Valid).Should(BeFalse())
+	})
+
+})
@@ -15,10 +15,18 @@ import (
 
 // Model specifies types that can interact with the database.
 type Model interface {
-	Get(id int64, db *sql.DB) error            // Populate model fields from the database
-	Save(db *sql.DB) (bool, error)             // Insert or update the model
-	Delete(db *sql.DB) (bool, error)           // Delete the model from the database
-	Exists(id int64, db *sql.DB) (bool, error) // Determine if the model exists by ID
+	Get(id int64, db Executor) error            // Populate model fields from the database
+	Save(db Executor) (bool, error)             // Insert or update the model
+	Delete(db Executor) (bool, error)           // Delete the model from the database
+	Exists(id int64, db Executor) (bool, error) // Determine if the model exists by ID
+}
+
+// Executor executes the queries of the models, either on the connection to
+// the database (*sql.DB) or in a transaction (*sql.Tx).
+type Executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // ModelMeta specifies the fields that all models should have via embedding.
@@ -34,7 +42,7 @@ type Device struct {
 	Name     string       // Hostname of the device
 	IPAddr   string       // IP Address of the device
 	Domain   string       // Domain name of the device
-	Sequence int64        // The response/reply counter for a device
+	Sequence int64        // Unused: replaced by the per pair counters in the sequences table
 	echo     *echo.Device // The protocol buffer representation
 	ModelMeta
 }
@@ -57,17 +65,55 @@ type Location struct {
 	ModelMeta
 }
 
+// Ping status values record the outcome of every echo request so that
+// failures to reach a reflector are measured as lost pings.
+const (
+	PingSent      = "sent"      // The request was sent and is awaiting a reply
+	PingReplied   = "replied"   // A reply was received from the reflector
+	PingTimeout   = "timeout"   // No reply was received before the timeout
+	PingRefused   = "refused"   // The reflector refused the connection
+	PingCancelled = "cancelled" // The generator shut down before a reply was received
+	PingRejected  = "rejected"  // The reflector rejected the sender or its signature
+	PingError     = "error"     // The request failed for any other reason
+)
+
 // Ping is a timeseries record of latency requests reflected from echo servers.
 type Ping struct {
-	ID       int64           //  Unique ID of the record
-	Source   *Device         // Source device of the ping (always the local node)
-	Target   *Device         // Target device that the ping was sent to
-	Location *Location       // The location of the source at the time of the ping
-	Request  int64           // Request sequence number for the source/target pair
-	Response int64           // Response sequence number for the target/source pair
-	Sent     time.Time       // The time that the ping was sent
-	Recv     time.Time       // The time that the ping was received
-	Latency  sql.NullFloat64 // The latency in milliseconds of the ping
+	ID        int64           //  Unique ID of the record
+	Source    *Device         // Source device of the ping (always the local node)
+	Target    *Device         // Target device that the ping was sent to
+	Location  *Location       // The location of the source at the time of the ping
+	Request   int64           // Request sequence number for the source/target pair
+	Response  int64           // Response sequence number for the target/source pair
+	Sent      time.Time       // The time that the ping was sent
+	Recv      time.Time       // The time that the ping was received
+	Latency   sql.NullFloat64 // The latency in milliseconds of the ping
+	Status    string          // The outcome of the ping (sent, replied, timeout, etc.)
+	Error     sql.NullString  // The error message if the ping failed
+	Mode      string          // The connection mode of the generator (cold or warm)
+	Transport string          // The transport of the echo request (unary or stream)
+	Probe     string          // The type of probe that was sent (echo, tcp, or dns)
+	// Sizes of the echo request and reply
+	PayloadSize int64 // The size in bytes of the payload of the echo request
+	ReplySize   int64 // The size in bytes of the payload of the reply, including the echoed payload
+	// Timings of the phases of the echo request in milliseconds
+	DialLatency sql.NullFloat64 // Time to connect, if the target was dialed
+	FirstByte   sql.NullFloat64 // Time from sending the request to the reply headers
+	RPCLatency  sql.NullFloat64 // Time from sending the request to the full reply
+	Handshake   sql.NullFloat64 // Time of the TLS handshake, if the target was dialed with TLS
+	// Clock offset and delays estimated from the four echo timestamps
+	Offset  sql.NullFloat64 // Clock offset of the target computed from this ping
+	Delay   sql.NullFloat64 // Round trip network delay excluding the reflector
+	Forward sql.NullFloat64 // One-way delay from source to target
+	Reverse sql.NullFloat64 // One-way delay from target to source
+	// Time the reflector took to reply and the latency without it
+	Processing sql.NullFloat64 // Processing time reported by the reflector
+	NetworkRTT sql.NullFloat64 // Latency minus the processing time of the reflector
+	// Whether the echo in the reply matched the request that was sent
+	PayloadMatch  sql.NullBool // The echoed payload and ping ID matched (null if the payload was omitted)
+	SequenceMatch sql.NullBool // The echoed request sequence number matched
+	ReceiverMatch sql.NullBool // The receiver of the reply was the target device
+	digest        []byte       // Digest of the payload of the echo request, if it is echoed
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -75,7 +121,7 @@ type Ping struct {
 /////////////////////////////////////////////////////////////////////////////
 
 // Get a device from the database by ID and populate the struct fields.
-func (d *Device) Get(id int64, db *sql.DB) error {
+func (d *Device) Get(id int64, db Executor) error {
 	row := db.QueryRow("SELECT * FROM devices WHERE id = $1", id)
 	err := row.Scan(&d.ID, &d.Name, &d.IPAddr, &d.Domain, &d.Sequence, &d.Created, &d.Updated)
 
@@ -83,7 +129,7 @@ func (d *Device) Get(id int64, db *sql.DB) error {
 }
 
 // GetByName a device from the database and populate the struct fields.
-func (d *Device) GetByName(name string, db *sql.DB) error {
+func (d *Device) GetByName(name string, db Executor) error {
 
 	row := db.QueryRow("SELECT * FROM devices WHERE name = $1", name)
 	err := row.Scan(&d.ID, &d.Name, &d.IPAddr, &d.Domain, &d.Sequence, &d.Created, &d.Updated)
@@ -94,7 +140,9 @@ func (d *Device) GetByName(name string, db *sql.DB) error {
 // has an ID or not. If it does, it will execute a SQL UPDATE, otherwise it
 // will execute a SQL INSERT. Returns a boolean if the device was inserted.
 // This method handles setting the created and updated timestamps as well.
-func (d *Device) Save(db *sql.DB) (bool, error) {
+// Devices with an ID that is not in the database (e.g. because the batch
+// that inserted them was rolled back) are inserted with their ID.
+func (d *Device) Save(db Executor) (bool, error) {
 	if d.ID > 0 {
 		// This is the UPDATE method so return false.
 		// Update the updated timestamp on the device.
@@ -102,9 +150,16 @@ func (d *Device) Save(db *sql.DB) (bool, error) {
 
 		// Execute the query against the database
 		query := "UPDATE devices SET name=$1, ipaddr=$2, domain=$3, sequence=$4, updated=$5 WHERE id = $6"
-		_, err := db.Exec(query, d.Name, d.IPAddr, d.Domain, d.Sequence, d.Updated, d.ID)
+		res, err := db.Exec(query, d.Name, d.IPAddr, d.Domain, d.Sequence, d.Updated, d.ID)
+		if err != nil {
+			return false, err
+		}
 
-		return false, err
+		// If the device was updated we're done, otherwise its insert was
+		// rolled back so insert it again with its ID
+		if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+			return false, err
+		}
 	}
 
 	// This is the INSERT method, so return true
@@ -113,10 +168,13 @@ func (d *Device) Save(db *sql.DB) (bool, error) {
 	d.Updated = time.Now()
 
 	// Create the query to insert the device into the database
-	query := "INSERT INTO devices (name, ipaddr, domain, sequence, created, updated) VALUES ($1, $2, $3, $4, $5, $6)"
+	query := "INSERT INTO devices (id, name, ipaddr, domain, sequence, created, updated) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+
+	// A NULL id is assigned by sqlite3
+	id := sql.NullInt64{Int64: d.ID, Valid: d.ID > 0}
 
 	// Execute the INSERT query against the dtabase
-	res, err := db.Exec(query, d.Name, d.IPAddr, d.Domain, d.Sequence, d.Created, d.Updated)
+	res, err := db.Exec(query, id, d.Name, d.IPAddr, d.Domain, d.Sequence, d.Created, d.Updated)
 	if err != nil {
 		return false, err
 	}
@@ -134,12 +192,12 @@ func (d *Device) Save(db *sql.DB) (bool, error) {
 
 // Delete a device from the database. Returns true if the number of rows
 // affected is 1 or false otherwise.
-func (d *Device) Delete(db *sql.DB) (bool, error) {
+func (d *Device) Delete(db Executor) (bool, error) {
 	return deleteFromDatabase(db, "devices", d.ID)
 }
 
 // Exists checks if the specified device is in the database.
-func (d *Device) Exists(id int64, db *sql.DB) (bool, error) {
+func (d *Device) Exists(id int64, db Executor) (bool, error) {
 	if id == 0 {
 		id = d.ID
 	}
@@ -180,7 +238,7 @@ func (d *Device) String() string {
 /////////////////////////////////////////////////////////////////////////////
 
 // Get a location from the database by ID and populate the struct fields.
-func (loc *Location) Get(id int64, db *sql.DB) error {
+func (loc *Location) Get(id int64, db Executor) error {
 	row := db.QueryRow("SELECT * FROM locations WHERE id = $1", id)
 	err := row.Scan(
 		&loc.ID, &loc.IPAddr, &loc.Latitude, &loc.Longitude,
@@ -195,7 +253,7 @@ func (loc *Location) Get(id int64, db *sql.DB) error {
 // location has an ID or not. If it does, it will execute a SQL UPDATE,
 // otherwise it will execute a SQL INSERT. Returns a boolean if the location
 // was inserted. This method handles setting the meta timestamps as well.
-func (loc *Location) Save(db *sql.DB) (bool, error) {
+func (loc *Location) Save(db Executor) (bool, error) {
 	if loc.ID > 0 {
 		// This is the UPDATE method so return false.
 		// Update the updated timestamp on the device.
@@ -236,12 +294,12 @@ func (loc *Location) Save(db *sql.DB) (bool, error) {
 
 // Delete a location from the database. Returns true if the number of rows
 // affected is 1 or false otherwise.
-func (loc *Location) Delete(db *sql.DB) (bool, error) {
+func (loc *Location) Delete(db Executor) (bool, error) {
 	return deleteFromDatabase(db, "locations", loc.ID)
 }
 
 // Exists checks if the specified location is in the database.
-func (loc *Location) Exists(id int64, db *sql.DB) (bool, error) {
+func (loc *Location) Exists(id int64, db Executor) (bool, error) {
 	if id == 0 {
 		id = loc.ID
 	}
@@ -250,7 +308,7 @@ func (loc *Location) Exists(id int64, db *sql.DB) (bool, error) {
 
 // IPExists sets the ID on the location if the location's IP address is
 // already in the database, otherwise it sets it to zero.
-func (loc *Location) IPExists(db *sql.DB) error {
+func (loc *Location) IPExists(db Executor) error {
 	query := "SELECT id FROM locations WHERE ipaddr = $1 LIMIT 1"
 	row := db.QueryRow(query, loc.IPAddr)
 	err := row.Scan(&loc.ID)
@@ -258,8 +316,30 @@ func (loc *Location) IPExists(db *sql.DB) error {
 	return err
 }
 
+// Echo converts a location to an echo.Location protocol buffer message.
+func (loc *Location) Echo() *echo.Location {
+	if loc == nil {
+		return nil
+	}
+
+	return &echo.Location{
+		Ipaddr:       loc.IPAddr,
+		Latitude:     loc.Latitude,
+		Longitude:    loc.Longitude,
+		City:         loc.City,
+		Postal:       loc.PostCode,
+		Country:      loc.Country,
+		Organization: loc.Organization,
+		Domain:       loc.Domain,
+	}
+}
+
 // String returns a pretty representation of the location
 func (loc *Location) String() string {
+	if loc == nil {
+		return "unknown location"
+	}
+
 	output := fmt.Sprintf("%s is located at %s, %s (%f, %f)", loc.IPAddr, loc.City, loc.Country, loc.Latitude, loc.Longitude)
 	if loc.Organization != "" {
 		output += fmt.Sprintf("\nOrganization: %s", loc.Organization)
@@ -274,59 +354,100 @@ func (loc *Location) String() string {
 // Ping Methods
 /////////////////////////////////////////////////////////////////////////////
 
+// Selects the pings along with their devices and locations in the order of
+// the columns scanned by the scan method of the ping. The location is
+// optional, so pings without a location are selected with NULL columns.
+const pingQuery = "SELECT * FROM pings p " +
+	"   JOIN devices s ON p.source_id = s.id " +
+	"   JOIN devices t ON p.target_id = t.id " +
+	"   LEFT JOIN locations l ON p.location_id = l.id "
+
 // Get a ping from the database by ID and populate the struct fields.
-func (p *Ping) Get(id int64, db *sql.DB) error {
+func (p *Ping) Get(id int64, db Executor) error {
+	row := db.QueryRow(pingQuery+"WHERE p.id=$1", id)
+	return p.scan(row)
+}
 
-	// Construct the Ping query
-	query := "SELECT * from pings p "
-	query += "   JOIN devices s on p.source_id = s.id "
-	query += "   JOIN devices t on p.target_id = t.id "
-	query += "   JOIN locations l on p.location_id = l.id "
-	query += "WHERE p.id=$1"
+// Helper function that scans a row of the ping query into the struct fields,
+// creating the source, target, and location. The location is nil if the ping
+// was not sent from a known location.
+func (p *Ping) scan(row interface {
+	Scan(dest ...interface{}) error
+}) error {
 
 	// Create the empty struct targets
 	p.Source = new(Device)
 	p.Target = new(Device)
-	p.Location = new(Location)
 
-	// Execute the query and scann the ping
-	row := db.QueryRow(query, id)
+	var locationID sql.NullInt64
+	loc := new(nullLocation)
+
 	err := row.Scan(
-		&p.ID, &p.Source.ID, &p.Target.ID, &p.Location.ID, &p.Request, &p.Response, &p.Sent, &p.Recv, &p.Latency,
+		&p.ID, &p.Source.ID, &p.Target.ID, &locationID, &p.Request, &p.Response, &p.Sent, &p.Recv, &p.Latency, &p.Status, &p.Error, &p.Mode, &p.DialLatency, &p.FirstByte, &p.RPCLatency,
+		&p.Offset, &p.Delay, &p.Forward, &p.Reverse, &p.Transport, &p.Probe, &p.Handshake, &p.Processing, &p.NetworkRTT, &p.PayloadSize, &p.ReplySize, &p.PayloadMatch, &p.SequenceMatch, &p.ReceiverMatch,
 		&p.Source.ID, &p.Source.Name, &p.Source.IPAddr, &p.Source.Domain, &p.Source.Sequence, &p.Source.Created, &p.Source.Updated,
 		&p.Target.ID, &p.Target.Name, &p.Target.IPAddr, &p.Target.Domain, &p.Target.Sequence, &p.Target.Created, &p.Target.Updated,
-		&p.Location.ID, &p.Location.IPAddr, &p.Location.Latitude, &p.Location.Longitude, &p.Location.City, &p.Location.PostCode,
-		&p.Location.Country, &p.Location.Organization, &p.Location.Domain, &p.Location.Note, &p.Location.Created, &p.Location.Updated,
+		&loc.ID, &loc.IPAddr, &loc.Latitude, &loc.Longitude, &loc.City, &loc.PostCode,
+		&loc.Country, &loc.Organization, &loc.Domain, &loc.Note, &loc.Created, &loc.Updated,
 	)
 
+	p.Location = loc.Location()
 	return err
 }
 
+// Helper function that returns the ID of the location of the ping to store in
+// the location_id column, which is NULL if the ping has no location.
+func (p *Ping) locationID() sql.NullInt64 {
+	if p.Location == nil || p.Location.ID == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: p.Location.ID, Valid: true}
+}
+
 // Save a ping struct to the database. This function checks if the ping
 // has an ID or not. If it does, it will execute a SQL UPDATE, otherwise it
 // will execute a SQL INSERT. Returns a boolean if the device was inserted.
-// This method handles setting the created and updated timestamps as well.
-func (p *Ping) Save(db *sql.DB) (bool, error) {
+// Pings with an ID that is not in the database yet (reserved by the
+// generator) are inserted with their ID. Only the row of the ping itself (with
+// the same source and sent timestamp) is updated, so if another generator has
+// stored a ping with the reserved ID the insert fails instead of overwriting it.
+func (p *Ping) Save(db Executor) (bool, error) {
 	if p.ID > 0 {
 		// This is the UPDATE method so return false.
 		// Execute the query against the database
 		query := "UPDATE pings SET "
 		query += "source_id=$1, target_id=$2, location_id=$3, request=$4, "
-		query += "response=$5, sent=$6, recv=$7, latency=$8 "
-		query += "WHERE id = $9"
-		_, err := db.Exec(query, p.Source.ID, p.Target.ID, p.Location.ID, p.Request, p.Response, p.Sent, p.Recv, p.Latency, p.ID)
+		query += "response=$5, sent=$6, recv=$7, latency=$8, status=$9, error=$10, "
+		query += "mode=$11, dial_latency=$12, first_byte=$13, rpc_latency=$14, "
+		query += "clock_offset=$15, network_delay=$16, forward_delay=$17, reverse_delay=$18, "
+		query += "transport=$19, probe=$20, tls_handshake=$21, processing=$22, network_rtt=$23, "
+		query += "payload_size=$24, reply_size=$25, payload_match=$26, sequence_match=$27, receiver_match=$28 "
+		query += "WHERE id = $29 AND source_id = $1 AND sent = $6"
+		res, err := db.Exec(query, p.Source.ID, p.Target.ID, p.locationID(), p.Request, p.Response, p.Sent, p.Recv, p.Latency, p.Status, p.Error, p.Mode, p.DialLatency, p.FirstByte, p.RPCLatency, p.Offset, p.Delay, p.Forward, p.Reverse, p.Transport, p.Probe, p.Handshake, p.Processing, p.NetworkRTT, p.PayloadSize, p.ReplySize, p.PayloadMatch, p.SequenceMatch, p.ReceiverMatch, p.ID)
+		if err != nil {
+			return false, err
+		}
 
-		return false, err
+		// If the ping was updated we're done, otherwise insert it with its ID,
+		// which fails if the ID belongs to the ping of another generator
+		if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+			return false, err
+		}
 	}
 
 	// This is the INSERT method, so return true
 	// Create the query to insert the device into the database
 	query := "INSERT INTO pings "
-	query += "(source_id, target_id, location_id, request, response, sent, recv, latency) "
-	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	query += "(id, source_id, target_id, location_id, request, response, sent, recv, latency, status, error, mode, dial_latency, first_byte, rpc_latency, "
+	query += "clock_offset, network_delay, forward_delay, reverse_delay, transport, probe, tls_handshake, processing, network_rtt, "
+	query += "payload_size, reply_size, payload_match, sequence_match, receiver_match) "
+	query += "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29)"
+
+	// A NULL id is assigned by sqlite3
+	id := sql.NullInt64{Int64: p.ID, Valid: p.ID > 0}
 
 	// Execute the INSERT query against the dtabase
-	res, err := db.Exec(query, p.Source.ID, p.Target.ID, p.Location.ID, p.Request, p.Response, p.Sent, p.Recv, p.Latency)
+	res, err := db.Exec(query, id, p.Source.ID, p.Target.ID, p.locationID(), p.Request, p.Response, p.Sent, p.Recv, p.Latency, p.Status, p.Error, p.Mode, p.DialLatency, p.FirstByte, p.RPCLatency, p.Offset, p.Delay, p.Forward, p.Reverse, p.Transport, p.Probe, p.Handshake, p.Processing, p.NetworkRTT, p.PayloadSize, p.ReplySize, p.PayloadMatch, p.SequenceMatch, p.ReceiverMatch)
 	if err != nil {
 		return false, err
 	}
@@ -344,14 +465,88 @@ func (p *Ping) Save(db *sql.DB) (bool, error) {
 
 // Delete a ping from the database. Returns true if the number of rows
 // affected is 1 or false otherwise.
-func (p *Ping) Delete(db *sql.DB) (bool, error) {
+func (p *Ping) Delete(db Executor) (bool, error) {
 	return deleteFromDatabase(db, "pings", p.ID)
 }
 
+// Fail records the error from a failed echo request on the ping, setting the
+// status to timeout, refused, or error depending on the kind of failure.
+func (p *Ping) Fail(err error) {
+	p.Status = failureStatus(err)
+	p.Error = sql.NullString{String: err.Error(), Valid: true}
+}
+
 // String returns a pretty representation of the ping
 func (p *Ping) String() string {
+	if p.Probe != "" && p.Probe != EchoProbe {
+		output := "%s -> %s %s %s"
+		return fmt.Sprintf(output, p.Source.Name, p.Target.Name, p.Probe, p.Status)
+	}
+
+	if p.Status != PingReplied {
+		output := "%s -> %s order=%d %s"
+		return fmt.Sprintf(output, p.Source.Name, p.Target.Name, p.Request, p.Status)
+	}
+
 	output := "%s -> %s order=%d seq=%d %0.3fms"
-	return fmt.Sprintf(output, p.Source.Name, p.Target.Name, p.Request, p.Response, p.Latency)
+	return fmt.Sprintf(output, p.Source.Name, p.Target.Name, p.Request, p.Response, p.Latency.Float64)
+}
+
+// Echo converts a ping to an echo.Ping protocol buffer message to export it.
+// The measurements that are NULL are left unset (nil).
+func (p *Ping) Echo() *echo.Ping {
+	msg := &echo.Ping{
+		ID:            p.ID,
+		Source:        p.Source.Echo(),
+		Target:        p.Target.Echo(),
+		Location:      p.Location.Echo(),
+		Request:       p.Request,
+		Response:      p.Response,
+		Sent:          &echo.Time{Nanoseconds: p.Sent.UnixNano()},
+		Latency:       echoDouble(p.Latency),
+		Status:        p.Status,
+		Error:         p.Error.String,
+		Mode:          p.Mode,
+		Transport:     p.Transport,
+		Probe:         p.Probe,
+		PayloadSize:   p.PayloadSize,
+		ReplySize:     p.ReplySize,
+		DialLatency:   echoDouble(p.DialLatency),
+		FirstByte:     echoDouble(p.FirstByte),
+		RPCLatency:    echoDouble(p.RPCLatency),
+		Handshake:     echoDouble(p.Handshake),
+		Offset:        echoDouble(p.Offset),
+		Delay:         echoDouble(p.Delay),
+		Forward:       echoDouble(p.Forward),
+		Reverse:       echoDouble(p.Reverse),
+		Processing:    echoDouble(p.Processing),
+		NetworkRTT:    echoDouble(p.NetworkRTT),
+		PayloadMatch:  echoBool(p.PayloadMatch),
+		SequenceMatch: echoBool(p.SequenceMatch),
+		ReceiverMatch: echoBool(p.ReceiverMatch),
+	}
+
+	if !p.Recv.IsZero() {
+		msg.Recv = &echo.Time{Nanoseconds: p.Recv.UnixNano()}
+	}
+
+	return msg
+}
+
+// Helper functions that wrap the measurements of a ping in messages, which are
+// nil if the measurement is NULL.
+func echoDouble(n sql.NullFloat64) *echo.DoubleValue {
+	if !n.Valid {
+		return nil
+	}
+	return &echo.DoubleValue{Value: n.Float64}
+}
+
+func echoBool(n sql.NullBool) *echo.BoolValue {
+	if !n.Valid {
+		return nil
+	}
+	return &echo.BoolValue{Value: n.Bool}
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -359,7 +554,7 @@ func (p *Ping) String() string {
 /////////////////////////////////////////////////////////////////////////////
 
 // Helper function that deletes an item from a table by ID.
-func deleteFromDatabase(db *sql.DB, table string, id int64) (bool, error) {
+func deleteFromDatabase(db Executor, table string, id int64) (bool, error) {
 	if id == 0 {
 		msg := fmt.Sprintf("Cannot delete a row with id=0 from the %s table", table)
 		return false, errors.New(msg)
@@ -389,7 +584,7 @@ func deleteFromDatabase(db *sql.DB, table string, id int64) (bool, error) {
 }
 
 // Helper function that checks if a row exists in a table by ID
-func existsInDatabase(db *sql.DB, table string, id int64) (bool, error) {
+func existsInDatabase(db Executor, table string, id int64) (bool, error) {
 	var exists bool
 	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE id = $1 LIMIT 1)", table)
 	row := db.QueryRow(query, id)
@@ -397,3 +592,62 @@ func existsInDatabase(db *sql.DB, table string, id int64) (bool, error) {
 
 	return exists, err
 }
+
+// Helper type that scans the columns of a location that is LEFT JOINed to a
+// ping, which are all NULL if the ping has no location.
+type nullLocation struct {
+	ID           sql.NullInt64
+	IPAddr       sql.NullString
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	City         sql.NullString
+	PostCode     sql.NullString
+	Country      sql.NullString
+	Organization sql.NullString
+	Domain       sql.NullString
+	Note         sql.NullString
+	Created      nullTime
+	Updated      nullTime
+}
+
+// Location returns the scanned location, or nil if the columns were NULL.
+func (l *nullLocation) Location() *Location {
+	if !l.ID.Valid {
+		return nil
+	}
+
+	return &Location{
+		IPAddr:       l.IPAddr.String,
+		Latitude:     l.Latitude.Float64,
+		Longitude:    l.Longitude.Float64,
+		City:         l.City.String,
+		PostCode:     l.PostCode.String,
+		Country:      l.Country.String,
+		Organization: l.Organization.String,
+		Domain:       l.Domain.String,
+		Note:         l.Note.String,
+		ModelMeta:    ModelMeta{ID: l.ID.Int64, Created: l.Created.Time, Updated: l.Updated.Time},
+	}
+}
+
+// Helper type that scans a timestamp that may be NULL.
+type nullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (t *nullTime) Scan(value interface{}) error {
+	if value == nil {
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	}
+
+	ts, ok := value.(time.Time)
+	if !ok {
+		return fmt.Errorf("Cannot scan %T into a timestamp", value)
+	}
+
+	t.Time, t.Valid = ts, true
+	return nil
+}
@@ -1,8 +1,14 @@
 package orca_test
 
 import (
+	"errors"
+	"net"
+
 	. "github.com/bbengfort/orca"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -33,4 +39,48 @@ var _ = Describe("Models", func() {
 		})
 	})
 
+	Describe("Pings", func() {
+
+		It("should record a refused connection as a refused ping", func() {
+			_, err := net.Dial("tcp", "127.0.0.1:1")
+			Ω(err).Should(HaveOccurred())
+
+			ping := &Ping{Status: PingSent}
+			ping.Fail(err)
+			Ω(ping.Status).Should(Equal(PingRefused))
+			Ω(ping.Error.Valid).Should(BeTrue())
+			Ω(ping.Error.String).Should(Equal(err.Error()))
+		})
+
+		It("should record a deadline as a timed out ping", func() {
+			ping := &Ping{Status: PingSent}
+			ping.Fail(grpc.Errorf(codes.DeadlineExceeded, "context deadline exceeded"))
+			Ω(ping.Status).Should(Equal(PingTimeout))
+		})
+
+		It("should record a cancelled request as a cancelled ping", func() {
+			ping := &Ping{Status: PingSent}
+			ping.Fail(grpc.Errorf(codes.Canceled, "context canceled"))
+			Ω(ping.Status).Should(Equal(PingCancelled))
+		})
+
+		It("should record a rejected request as a rejected ping", func() {
+			ping := &Ping{Status: PingSent}
+			ping.Fail(grpc.Errorf(codes.Unauthenticated, "could not authenticate the request"))
+			Ω(ping.Status).Should(Equal(PingRejected))
+
+			ping = &Ping{Status: PingSent}
+			ping.Fail(grpc.Errorf(codes.PermissionDenied, "unknown sender"))
+			Ω(ping.Status).Should(Equal(PingRejected))
+		})
+
+		It("should record any other failure as an error", func() {
+			ping := &Ping{Status: PingSent}
+			ping.Fail(errors.New("something bad happened"))
+			Ω(ping.Status).Should(Equal(PingError))
+			Ω(ping.Error.String).Should(Equal("something bad happened"))
+		})
+
+	})
+
 })
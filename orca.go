@@ -3,8 +3,10 @@
 package orca
 
 import (
-	"database/sql"
+	"crypto/tls"
 	"log"
+	"net/http"
+	"sync"
 )
 
 // Version specifies the current version of the Orca library.
@@ -14,12 +16,25 @@ const Version = "0.1"
 // and device details as well as initializes the environment and runs the
 // reflect and generate commands.
 type App struct {
-	Config     *Config        // The configuration loaded from the YAML file
-	GeoIP      *MaxMindClient // GeoIP Lookup API client
-	Device     *Device        // Descriptor for the device in the database
-	Location   *Location      // Current location of the application
-	ExternalIP string         // Current external IP address of the machine
-	db         *sql.DB        // Connection to the database stored on the app
+	Config     *Config                 // The configuration loaded from the YAML file
+	GeoIP      *MaxMindClient          // GeoIP Lookup API client
+	Device     *Device                 // Descriptor for the device in the database
+	Location   *Location               // Current location of the application
+	ExternalIP string                  // Current external IP address of the machine
+	store      Store                   // Stores the models, by default in the database
+	conns      *ConnManager            // Persistent connections to devices in warm mode
+	streams    *StreamManager          // Echo streams to devices with the stream transport
+	client     *http.Client            // The client for the HTTP transport
+	tls        *tls.Config             // Secures the gRPC connections of the generator if not nil
+	offsets    map[int64]*ClockOffset  // Rolling clock offset estimates by target
+	sequences  map[int64]*Sequence     // Request sequence counters by target
+	payloads   *PayloadGenerator       // Creates the payloads of the echo requests
+	replies    *PayloadGenerator       // Draws the sizes of the echo replies
+	senders    map[string]*senderState // Reflector state of the senders by name
+	writer     *BatchWriter            // Writes the reflector state or the generator pings in the background
+	lastPing   int64                   // The last ping ID reserved by the generator
+	reload     chan struct{}           // Signals the generator to reload
+	mu         sync.RWMutex            // Guards the config, location, IP address, offsets, sequences, payloads, replies, senders, and ping IDs
 }
 
 // Init the orca application
@@ -29,7 +44,9 @@ func Init() (*App, error) {
 	app := new(App)
 
 	// Load the configuration from the YAML files
-	app.Config = LoadConfig()
+	if app.Config, err = LoadConfig(); err != nil {
+		return nil, err
+	}
 
 	// Connect to the database
 	if err = app.ConnectDB(); err != nil {
@@ -46,34 +63,96 @@ func Init() (*App, error) {
 
 // GetDevice returns the device on the app, if it is nil, it performs a
 // database query for the device based on the Config name; if there is nothing
-// in the database, it inserts the record from the configuration.
+// in the database, it inserts the record from the configuration. The device
+// is not modified once it is returned (SyncLocation replaces it), so it can be
+// shared by the pings and replies in flight.
 func (app *App) GetDevice() *Device {
+	app.mu.RLock()
+	device := app.Device
+	app.mu.RUnlock()
 
-	if app.Device == nil {
-		// Allocate a new device
-		app.Device = new(Device)
-
+	if device == nil {
 		// Attempt to fetch device info from database by name
-		if err := app.Device.GetByName(app.Config.Name, app.db); err != nil {
+		device, err := app.store.GetDeviceByName(app.Config.Name)
+		if err != nil {
 			// If no record is in the database, populate and save!
-			app.Device.Name = app.Config.Name
-			app.Device.IPAddr, _ = app.GetListenAddr()
-			app.Device.Domain = app.Config.Domain
+			device = &Device{Name: app.Config.Name, Domain: app.Config.Domain}
+			device.IPAddr, _ = app.GetListenAddr()
 
 			// Save the device to the database, if that's not possible, die.
-			if _, err := app.Device.Save(app.db); err != nil {
+			if _, err := app.store.SaveDevice(device); err != nil {
 				log.Fatal(err)
 			}
 		}
+
+		// Create the protocol buffer before the device is shared
+		device.Echo()
+
+		app.mu.Lock()
+		if app.Device == nil {
+			app.Device = device
+		}
+		app.mu.Unlock()
 	}
 
+	app.mu.RLock()
+	defer app.mu.RUnlock()
 	return app.Device
 }
 
+// GetConfig returns the current configuration, which can be replaced by
+// ReloadConfig while the generator is running.
+func (app *App) GetConfig() *Config {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.Config
+}
+
+// ReloadConfig re-reads the configuration from the files it was read from and
+// applies the settings that can be changed while the generator is running:
+// the ping interval, debug mode, and MaxMind credentials. The configuration
+// is replaced rather than modified so that it can be read by the pings in
+// flight.
+func (app *App) ReloadConfig() error {
+	conf, err := app.Config.Reload()
+	if err != nil {
+		return err
+	}
+
+	next := *app.Config
+	next.Debug = conf.Debug
+	next.MaxMind = conf.MaxMind
+	if conf.Interval > 0 {
+		next.Interval = conf.Interval
+	}
+
+	app.mu.Lock()
+	app.Config = &next
+	app.GeoIP = NewMaxMindClient(next.MaxMind.Username, next.MaxMind.License)
+	app.mu.Unlock()
+	return nil
+}
+
+// Reload signals the generator to reload its configuration and devices.
+func (app *App) Reload() {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	if app.reload != nil {
+		select {
+		case app.reload <- struct{}{}:
+		default:
+		}
+	}
+}
+
 // SyncLocation checks the external IP address against the current IP address,
 // if they're different then it performs another location lookup to track
 // mobility in the generator application, but does not perform GeoIP lookups
-// if they're not necessary (to save bandwidth and cost).
+// if they're not necessary (to save bandwidth and cost). The external IP
+// address is only stored once its location is saved, so that a failed lookup
+// is retried on the next sync. The IP address of the local device, if it is
+// loaded, is updated even if the lookup fails.
 func (app *App) SyncLocation() error {
 
 	// Get the external IP address
@@ -82,40 +161,77 @@ func (app *App) SyncLocation() error {
 		return err
 	}
 
-	// Compare to current IP address and if different, fetch new location.
-	if eip != app.ExternalIP {
-		// Store the current external IP address on the app
-		app.ExternalIP = eip
+	// Send the current IP address with the echo requests of the device
+	app.setDeviceIP(eip)
 
-		// Initialize the current location for geographic tracking
+	// Compare to current IP address and if different, fetch new location.
+	app.mu.RLock()
+	changed := eip != app.ExternalIP
+	app.mu.RUnlock()
+
+	if changed {
+		// Initialize the current location for geographic tracking; until the
+		// lookup succeeds (e.g. without MaxMind credentials) the location is
+		// unknown and pings are stored without one.
 		loc, err := app.GeoIP.GetCurrentLocation()
 		if err != nil {
+			app.SetLocation(nil, false)
 			return err
 		}
 
 		// Set the location on the app and save to database
 		if err = app.SetLocation(loc, true); err != nil {
+			app.SetLocation(nil, false)
 			return err
 		}
+
+		// Store the current external IP address on the app
+		app.mu.Lock()
+		app.ExternalIP = eip
+		app.mu.Unlock()
 	}
 
 	return nil
 }
 
+// Helper function that replaces the local device, if it has been loaded, with
+// a copy at the IP address, so that the pings in flight keep the device they
+// were created with. The address is not saved to the database since it is the
+// address that the device is pinged at.
+func (app *App) setDeviceIP(ipaddr string) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	if app.Device == nil || app.Device.IPAddr == ipaddr {
+		return
+	}
+
+	device := *app.Device
+	device.IPAddr = ipaddr
+	device.echo = nil
+	device.Echo()
+	app.Device = &device
+}
+
 // SetLocation is a wrapper method that sets the location on the app struct,
-// but also does a check about whether or not to save it to the database.
+// but also does a check about whether or not to save it to the database. The
+// location is nil if it is not known.
 func (app *App) SetLocation(loc *Location, save bool) error {
-	if save {
+	if save && loc != nil {
 		// Check to make sure the location isn't in the database.
-		loc.IPExists(app.db)
+		if stored, err := app.store.GetLocationByIP(loc.IPAddr); err == nil {
+			loc.ID = stored.ID
+		}
 
-		if _, err := loc.Save(app.db); err != nil {
+		if _, err := app.store.SaveLocation(loc); err != nil {
 			return err
 		}
 	}
 
 	// Store the location with the application
+	app.mu.Lock()
 	app.Location = loc
+	app.mu.Unlock()
 	return nil
 }
 
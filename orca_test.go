@@ -13,4 +13,40 @@ var _ = Describe("Orca", func() {
 		Ω(Version).Should(Equal("0.1"))
 	})
 
+	It("should retry the location lookup after it fails", func() {
+		if _, err := ExternalIP(); err != nil {
+			Skip("no external IP address to look up")
+		}
+
+		// Without MaxMind credentials the lookup always fails
+		app := &App{Config: &Config{Name: "generator", Store: MemoryBackend}}
+		Ω(app.ConnectDB()).Should(Succeed())
+
+		for i := 0; i < 2; i++ {
+			Ω(app.SyncLocation()).Should(MatchError(ContainSubstring("MaxMind")))
+			Ω(app.ExternalIP).Should(BeEmpty())
+			Ω(app.Location).Should(BeNil())
+		}
+	})
+
+	It("should send the current IP address of the local device", func() {
+		eip, err := ExternalIP()
+		if err != nil {
+			Skip("no external IP address to look up")
+		}
+
+		app := &App{Config: &Config{Name: "generator", Store: MemoryBackend}}
+		Ω(app.ConnectDB()).Should(Succeed())
+
+		local := app.GetDevice()
+		Ω(local.IPAddr).ShouldNot(Equal(eip))
+
+		// The device is updated even though the location lookup fails, while
+		// the pings in flight keep the device they were created with
+		app.SyncLocation()
+		Ω(app.GetDevice().IPAddr).Should(Equal(eip))
+		Ω(app.GetDevice().Echo().IPAddr).Should(Equal(eip))
+		Ω(local.Echo().IPAddr).ShouldNot(Equal(eip))
+	})
+
 })
@@ -0,0 +1,239 @@
+package orca
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// DefaultPayloadSize is the size in bytes of the payload of every echo request
+// if the configuration does not specify the payload sizes.
+const DefaultPayloadSize = 50
+
+// MaxPayloadSize is the largest payload that fits in an echo request sent in
+// a single UDP datagram along with the other fields of the request.
+const MaxPayloadSize = 65000
+
+// Payload distributions specify how the payload sizes are drawn at random:
+// uniformly between the min and max, normally around the mean with the
+// standard deviation, or exponentially with the mean. Sizes are clamped to
+// the min and max.
+const (
+	UniformPayloads     = "uniform"
+	NormalPayloads      = "normal"
+	ExponentialPayloads = "exponential"
+)
+
+// PayloadConfig specifies the size of the payload of the echo requests as a
+// fixed size, a list of sizes to sweep through, or a distribution.
+type PayloadConfig struct {
+	Size         int     `yaml:"size"`         // Fixed payload size in bytes
+	Sweep        []int   `yaml:"sweep"`        // Sizes cycled through by the pings to each device
+	Distribution string  `yaml:"distribution"` // Draw the sizes from a distribution
+	Min          int     `yaml:"min"`          // Smallest size drawn from the distribution
+	Max          int     `yaml:"max"`          // Largest size drawn from the distribution
+	Mean         float64 `yaml:"mean"`         // Mean of the normal and exponential distributions
+	StdDev       float64 `yaml:"stddev"`       // Standard deviation of the normal distribution
+	Seed         int64   `yaml:"seed"`         // Seed of the random sizes and payload bytes
+}
+
+// Validate the payload configuration and set the defaults.
+func (conf *PayloadConfig) Validate() error {
+	if conf.modes() == 0 {
+		// If no payload sizes are specified, use the default size
+		conf.Size = DefaultPayloadSize
+	}
+	return conf.validate()
+}
+
+// Helper function that validates the payload sizes without the default size,
+// so that a size of zero bytes can be specified for the replies.
+func (conf *PayloadConfig) validate() error {
+	if conf.modes() > 1 {
+		return errors.New("Specify only one of a payload size, sweep, or distribution")
+	}
+
+	if conf.Size < 0 || conf.Size > MaxPayloadSize {
+		return fmt.Errorf("The payload size must be between 0 and %d bytes", MaxPayloadSize)
+	}
+
+	for _, size := range conf.Sweep {
+		if size < 0 || size > MaxPayloadSize {
+			return fmt.Errorf("The payload sizes must be between 0 and %d bytes", MaxPayloadSize)
+		}
+	}
+
+	if conf.Max <= 0 || conf.Max > MaxPayloadSize {
+		conf.Max = MaxPayloadSize
+	}
+
+	if conf.Min < 0 || conf.Min > conf.Max {
+		return fmt.Errorf("The minimum payload size must be between 0 and %d bytes", conf.Max)
+	}
+
+	switch conf.Distribution {
+	case "", UniformPayloads:
+		// The distribution is valid
+	case NormalPayloads:
+		if conf.Mean <= 0 || conf.StdDev <= 0 {
+			return errors.New("The normal payload distribution requires a mean and stddev")
+		}
+	case ExponentialPayloads:
+		if conf.Mean <= 0 {
+			return errors.New("The exponential payload distribution requires a mean")
+		}
+	default:
+		return fmt.Errorf("Unknown payload distribution %q (use uniform, normal, or exponential)", conf.Distribution)
+	}
+
+	return nil
+}
+
+// Helper function that counts how many of the ways to specify the sizes of
+// the payloads are used by the configuration.
+func (conf *PayloadConfig) modes() int {
+	modes := 0
+	if conf.Size != 0 {
+		modes++
+	}
+	if len(conf.Sweep) > 0 {
+		modes++
+	}
+	if conf.Distribution != "" {
+		modes++
+	}
+	return modes
+}
+
+// MaxSize returns the largest payload size of the configuration.
+func (conf *PayloadConfig) MaxSize() int {
+	switch {
+	case len(conf.Sweep) > 0:
+		max := 0
+		for _, size := range conf.Sweep {
+			if size > max {
+				max = size
+			}
+		}
+		return max
+	case conf.Distribution != "":
+		return conf.Max
+	default:
+		return conf.Size
+	}
+}
+
+// String returns a description of the payload sizes.
+func (conf *PayloadConfig) String() string {
+	switch {
+	case len(conf.Sweep) > 0:
+		return fmt.Sprintf("sweep %v bytes", conf.Sweep)
+	case conf.Distribution == UniformPayloads:
+		return fmt.Sprintf("uniform %d-%d bytes (seed %d)", conf.Min, conf.Max, conf.Seed)
+	case conf.Distribution == NormalPayloads:
+		return fmt.Sprintf("normal mean=%0.0f stddev=%0.0f bytes (seed %d)", conf.Mean, conf.StdDev, conf.Seed)
+	case conf.Distribution == ExponentialPayloads:
+		return fmt.Sprintf("exponential mean=%0.0f bytes (seed %d)", conf.Mean, conf.Seed)
+	default:
+		return fmt.Sprintf("%d bytes", conf.Size)
+	}
+}
+
+// ReplyConfig specifies the size of the payload of the echo replies in the
+// same way as the payload of the requests, and whether the reflector echoes
+// the payload of the request, so that replies can be smaller or larger than
+// the requests (e.g. to emulate storage reads and writes). Unlike requests,
+// the replies have no payload unless a size is specified.
+type ReplyConfig struct {
+	PayloadConfig `yaml:",inline"`
+	Echo          bool `yaml:"echo"` // Echo the payload of the request in the reply
+}
+
+// Validate the reply configuration.
+func (conf *ReplyConfig) Validate() error {
+	return conf.PayloadConfig.validate()
+}
+
+// String returns a description of the reply sizes.
+func (conf *ReplyConfig) String() string {
+	return fmt.Sprintf("%s (echo = %t)", &conf.PayloadConfig, conf.Echo)
+}
+
+// PayloadGenerator creates the payloads of the echo requests with the sizes
+// specified by the configuration, filled with random bytes from the seed so
+// that an experiment can be repeated.
+type PayloadGenerator struct {
+	sync.Mutex
+	conf  *PayloadConfig
+	rand  *rand.Rand
+	sweep map[string]int // The next index of the sweep by target and transport
+}
+
+// NewPayloadGenerator creates a payload generator from a validated config. If
+// the config is nil, payloads have the default size.
+func NewPayloadGenerator(conf *PayloadConfig) *PayloadGenerator {
+	if conf == nil {
+		conf = &PayloadConfig{Size: DefaultPayloadSize, Max: MaxPayloadSize}
+	}
+
+	return &PayloadGenerator{
+		conf:  conf,
+		rand:  rand.New(rand.NewSource(conf.Seed)),
+		sweep: make(map[string]int),
+	}
+}
+
+// Next returns the payload of the next echo request to the target with the
+// transport. Each target and transport pair sweeps through the sizes on its
+// own so that every transport is measured with every size.
+func (g *PayloadGenerator) Next(target *Device, transport string) []byte {
+	g.Lock()
+	defer g.Unlock()
+
+	payload := make([]byte, g.size(target, transport))
+	g.rand.Read(payload)
+	return payload
+}
+
+// NextSize returns the size of the next payload to the target with the
+// transport without creating the payload, e.g. for the size of a reply.
+func (g *PayloadGenerator) NextSize(target *Device, transport string) int {
+	g.Lock()
+	defer g.Unlock()
+	return g.size(target, transport)
+}
+
+// Helper function that returns the size of the next payload.
+func (g *PayloadGenerator) size(target *Device, transport string) int {
+	conf := g.conf
+
+	if len(conf.Sweep) > 0 {
+		key := fmt.Sprintf("%d/%s", target.ID, transport)
+		idx := g.sweep[key]
+		g.sweep[key] = (idx + 1) % len(conf.Sweep)
+		return conf.Sweep[idx]
+	}
+
+	var size float64
+	switch conf.Distribution {
+	case UniformPayloads:
+		size = float64(conf.Min + g.rand.Intn(conf.Max-conf.Min+1))
+	case NormalPayloads:
+		size = g.rand.NormFloat64()*conf.StdDev + conf.Mean
+	case ExponentialPayloads:
+		size = g.rand.ExpFloat64() * conf.Mean
+	default:
+		return conf.Size
+	}
+
+	// Clamp the size drawn from the distribution
+	switch {
+	case size < float64(conf.Min):
+		return conf.Min
+	case size > float64(conf.Max):
+		return conf.Max
+	default:
+		return int(size)
+	}
+}
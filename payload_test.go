@@ -0,0 +1,97 @@
+package orca_test
+
+import (
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Payloads", func() {
+
+	var alpha, bravo *Device
+
+	BeforeEach(func() {
+		alpha = &Device{Name: "alpha", ModelMeta: ModelMeta{ID: 1}}
+		bravo = &Device{Name: "bravo", ModelMeta: ModelMeta{ID: 2}}
+	})
+
+	It("should default to a fixed payload size", func() {
+		conf := new(PayloadConfig)
+		Ω(conf.Validate()).Should(Succeed())
+		Ω(conf.Size).Should(Equal(DefaultPayloadSize))
+
+		payloads := NewPayloadGenerator(conf)
+		Ω(payloads.Next(alpha, UnaryTransport)).Should(HaveLen(DefaultPayloadSize))
+	})
+
+	It("should sweep the sizes for every target and transport", func() {
+		conf := &PayloadConfig{Sweep: []int{16, 256, 4096}}
+		Ω(conf.Validate()).Should(Succeed())
+
+		payloads := NewPayloadGenerator(conf)
+		for _, size := range []int{16, 256, 4096, 16} {
+			Ω(payloads.Next(alpha, UnaryTransport)).Should(HaveLen(size))
+		}
+
+		Ω(payloads.Next(alpha, UDPTransport)).Should(HaveLen(16))
+		Ω(payloads.Next(bravo, UnaryTransport)).Should(HaveLen(16))
+	})
+
+	It("should draw the sizes from a distribution within the bounds", func() {
+		for _, conf := range []*PayloadConfig{
+			{Distribution: UniformPayloads, Min: 100, Max: 200},
+			{Distribution: NormalPayloads, Mean: 150, StdDev: 100, Min: 100, Max: 200},
+			{Distribution: ExponentialPayloads, Mean: 150, Min: 100, Max: 200},
+		} {
+			Ω(conf.Validate()).Should(Succeed())
+
+			payloads := NewPayloadGenerator(conf)
+			for i := 0; i < 100; i++ {
+				size := len(payloads.Next(alpha, UnaryTransport))
+				Ω(size).Should(BeNumerically(">=", 100))
+				Ω(size).Should(BeNumerically("<=", 200))
+			}
+		}
+	})
+
+	It("should fill the payloads with random bytes from the seed", func() {
+		conf := &PayloadConfig{Size: 64, Seed: 42}
+		Ω(conf.Validate()).Should(Succeed())
+
+		first := NewPayloadGenerator(conf).Next(alpha, UnaryTransport)
+		second := NewPayloadGenerator(conf).Next(alpha, UnaryTransport)
+		Ω(first).Should(Equal(second))
+
+		conf.Seed = 43
+		Ω(NewPayloadGenerator(conf).Next(alpha, UnaryTransport)).ShouldNot(Equal(first))
+	})
+
+	It("should allow replies without a payload", func() {
+		conf := new(ReplyConfig)
+		Ω(conf.Validate()).Should(Succeed())
+		Ω(conf.Size).Should(Equal(0))
+		Ω(NewPayloadGenerator(&conf.PayloadConfig).NextSize(alpha, UnaryTransport)).Should(Equal(0))
+
+		conf = &ReplyConfig{PayloadConfig: PayloadConfig{Sweep: []int{0, 65000}}, Echo: true}
+		Ω(conf.Validate()).Should(Succeed())
+		Ω(conf.String()).Should(Equal("sweep [0 65000] bytes (echo = true)"))
+
+		replies := NewPayloadGenerator(&conf.PayloadConfig)
+		Ω(replies.NextSize(alpha, HTTPTransport)).Should(Equal(0))
+		Ω(replies.NextSize(alpha, HTTPTransport)).Should(Equal(65000))
+
+		Ω((&ReplyConfig{PayloadConfig: PayloadConfig{Size: -1}}).Validate()).ShouldNot(Succeed())
+	})
+
+	It("should reject invalid payload configurations", func() {
+		Ω((&PayloadConfig{Size: 10, Sweep: []int{10}}).Validate()).ShouldNot(Succeed())
+		Ω((&PayloadConfig{Size: -1}).Validate()).ShouldNot(Succeed())
+		Ω((&PayloadConfig{Size: MaxPayloadSize + 1}).Validate()).ShouldNot(Succeed())
+		Ω((&PayloadConfig{Sweep: []int{-1}}).Validate()).ShouldNot(Succeed())
+		Ω((&PayloadConfig{Distribution: "zipf"}).Validate()).ShouldNot(Succeed())
+		Ω((&PayloadConfig{Distribution: NormalPayloads}).Validate()).ShouldNot(Succeed())
+		Ω((&PayloadConfig{Distribution: UniformPayloads, Min: 10, Max: 5}).Validate()).ShouldNot(Succeed())
+	})
+
+})
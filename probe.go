@@ -0,0 +1,217 @@
+package orca
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bbengfort/orca/echo"
+	"golang.org/x/net/context"
+)
+
+// Probe types specify what the generator measures with each ping. The echo
+// probe sends an echo request to the reflector with one of the transports,
+// the TCP probe times the handshake of a new connection to the device, and
+// the DNS probe times resolving the domain of the device.
+const (
+	EchoProbe    = "echo"
+	TCPProbe     = "tcp"
+	DNSProbe     = "dns"
+	DefaultProbe = EchoProbe
+)
+
+// Prober sends a single kind of probe to the target of a ping. The prober
+// may record timings of the phases of the probe on the ping, but the ping is
+// created, completed, and saved by the generator.
+type Prober interface {
+	Type() string                                                // The probe type recorded with the ping
+	Transport() string                                           // The transport recorded with the ping, if any
+	Probe(ctx context.Context, ping *Ping) (*ProbeResult, error) // Send the probe and wait for the result
+}
+
+// ProbeResult is the outcome of a successful probe that is common to every
+// type of probe.
+type ProbeResult struct {
+	Recv    time.Time     // The time that the probe completed
+	Latency time.Duration // The round trip time of the probe
+	Reply   *echo.Reply   // The reply to an echo probe, nil for other probes
+}
+
+// NewProber creates a prober of the given type; echo probers send their
+// requests with the given transport.
+func (app *App) NewProber(probe, transport string) (Prober, error) {
+	switch probe {
+	case EchoProbe:
+		return &EchoProber{app: app, transport: transport}, nil
+	case TCPProbe:
+		return new(TCPProber), nil
+	case DNSProbe:
+		return new(DNSProber), nil
+	default:
+		return nil, fmt.Errorf("Unknown probe %q", probe)
+	}
+}
+
+// Helper function that creates the probers that every device is probed with
+// each round: an echo prober for the configured transport, and for UDP and
+// HTTP if enabled, followed by the other configured probes.
+func (app *App) probers() ([]Prober, error) {
+	conf := app.GetConfig()
+	probers := make([]Prober, 0, len(conf.Probes)+2)
+
+	for _, probe := range conf.Probes {
+		transports := []string{""}
+		if probe == EchoProbe {
+			transports = []string{conf.Transport}
+			if conf.UDP {
+				transports = append(transports, UDPTransport)
+			}
+
+			if conf.HTTP {
+				transports = append(transports, HTTPTransport)
+			}
+		}
+
+		for _, transport := range transports {
+			prober, err := app.NewProber(probe, transport)
+			if err != nil {
+				return nil, err
+			}
+			probers = append(probers, prober)
+		}
+	}
+
+	return probers, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// Echo Probes
+///////////////////////////////////////////////////////////////////////////
+
+// EchoProber sends echo requests to the reflector on the target device with
+// a single transport. The request and response sequence numbers and the
+// clock offset of the target are only tracked by echo probes.
+type EchoProber struct {
+	app       *App
+	transport string
+}
+
+// Type returns the echo probe type.
+func (p *EchoProber) Type() string {
+	return EchoProbe
+}
+
+// Transport returns the transport the echo requests are sent with.
+func (p *EchoProber) Transport() string {
+	return p.transport
+}
+
+// Probe sends the echo request and computes the latency from the sent time
+// of the request to the receipt of the reply.
+func (p *EchoProber) Probe(ctx context.Context, ping *Ping) (*ProbeResult, error) {
+	reply, err := p.app.SendPing(ctx, ping)
+
+	// Store the recv timestamp before any work.
+	recv := time.Now()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A reply that echoes another request is recorded as a mismatch, but its
+	// latency is measured from when the ping was created
+	sent := ping.Sent
+	if in := reply.GetEcho(); in != nil && in.Ping == ping.ID {
+		sent = in.GetSentTime()
+	}
+
+	latency := recv.Sub(sent)
+	return &ProbeResult{Recv: recv, Latency: latency, Reply: reply}, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// TCP Probes
+///////////////////////////////////////////////////////////////////////////
+
+// TCPProber times the handshake of a new TCP connection to the address of
+// the target device, then closes the connection without sending any data.
+type TCPProber struct{}
+
+// Type returns the TCP probe type.
+func (p *TCPProber) Type() string {
+	return TCPProbe
+}
+
+// Transport returns an empty string since the probe is not an echo request.
+func (p *TCPProber) Transport() string {
+	return ""
+}
+
+// Probe connects to the target, recording the handshake as the latency and
+// the dial latency of the ping.
+func (p *TCPProber) Probe(ctx context.Context, ping *Ping) (*ProbeResult, error) {
+	var dialer net.Dialer
+
+	started := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", ping.Target.IPAddr)
+	recv := time.Now()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	conn.Close()
+
+	latency := recv.Sub(started)
+	ping.DialLatency = milliseconds(latency)
+	return &ProbeResult{Recv: recv, Latency: latency}, nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+// DNS Probes
+///////////////////////////////////////////////////////////////////////////
+
+// DNSProber times resolving the domain of the target device with the system
+// resolver. Note that the resolver or the network may cache the answer, so
+// the latency is that of the lookup as the applications on the device see it.
+type DNSProber struct {
+	Resolver *net.Resolver // The resolver to use, the default resolver if nil
+}
+
+// Type returns the DNS probe type.
+func (p *DNSProber) Type() string {
+	return DNSProbe
+}
+
+// Transport returns an empty string since the probe is not an echo request.
+func (p *DNSProber) Transport() string {
+	return ""
+}
+
+// Probe resolves the domain of the target, which fails if the device does
+// not have a domain.
+func (p *DNSProber) Probe(ctx context.Context, ping *Ping) (*ProbeResult, error) {
+	if ping.Target.Domain == "" {
+		return nil, fmt.Errorf("%s has no domain to resolve", ping.Target.Name)
+	}
+
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	started := time.Now()
+	_, err := resolver.LookupHost(ctx, ping.Target.Domain)
+	recv := time.Now()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	return &ProbeResult{Recv: recv, Latency: recv.Sub(started)}, nil
+}
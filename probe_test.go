@@ -0,0 +1,77 @@
+package orca_test
+
+import (
+	"net"
+
+	. "github.com/bbengfort/orca"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Probes", func() {
+
+	// Helper function to create a ping to a device with the address and domain
+	newPing := func(addr, domain string) *Ping {
+		return &Ping{
+			Source: &Device{Name: "source"},
+			Target: &Device{Name: "target", IPAddr: addr, Domain: domain},
+		}
+	}
+
+	It("should create probers by type", func() {
+		app := new(App)
+		for _, probe := range []string{EchoProbe, TCPProbe, DNSProbe} {
+			prober, err := app.NewProber(probe, UnaryTransport)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(prober.Type()).Should(Equal(probe))
+		}
+
+		_, err := app.NewProber("icmp", "")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	Describe("TCP", func() {
+
+		It("should time the handshake to the device", func() {
+			sock, err := net.Listen("tcp", "127.0.0.1:0")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer sock.Close()
+
+			ping := newPing(sock.Addr().String(), "")
+			result, err := new(TCPProber).Probe(context.Background(), ping)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.Reply).Should(BeNil())
+			Ω(result.Latency).Should(BeNumerically(">", 0))
+			Ω(ping.DialLatency.Valid).Should(BeTrue())
+		})
+
+		It("should record a refused connection", func() {
+			ping := newPing("127.0.0.1:1", "")
+			_, err := new(TCPProber).Probe(context.Background(), ping)
+			Ω(err).Should(HaveOccurred())
+
+			ping.Fail(err)
+			Ω(ping.Status).Should(Equal(PingRefused))
+		})
+
+	})
+
+	Describe("DNS", func() {
+
+		It("should time resolving the domain of the device", func() {
+			result, err := new(DNSProber).Probe(context.Background(), newPing("", "localhost"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.Reply).Should(BeNil())
+			Ω(result.Latency).Should(BeNumerically(">", 0))
+		})
+
+		It("should fail for a device without a domain", func() {
+			_, err := new(DNSProber).Probe(context.Background(), newPing("", ""))
+			Ω(err).Should(HaveOccurred())
+		})
+
+	})
+
+})
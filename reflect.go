@@ -1,78 +1,451 @@
 package orca
 
 import (
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/bbengfort/orca/echo"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 )
 
-// Echo implements the echo.EchoServer interface on the App
+// Holds the in-memory state of a device that sends echo requests to the
+// reflector so that replies do not wait on the database. The state is guarded
+// by the app mutex and written to the database by the batch writer.
+type senderState struct {
+	device    *Device   // The sender device and its metadata
+	sequence  *Sequence // The response counter of the sender to the local device
+	transient bool      // Unknown senders are not saved with the transient policy
+}
+
+// Echo implements the echo.OrcaServer interface on the App
 func (app *App) Echo(ctx context.Context, in *echo.Request) (*echo.Reply, error) {
 
 	// Store the RECV timestamp before any work
 	recv := time.Now()
+	return app.reflect(in, recv)
+
+}
+
+// EchoStream implements the echo.OrcaServer interface on the App, replying to
+// every request on the stream in the order that they are received until the
+// generator closes the stream. A rejected request is answered with a reply
+// that holds the status of the rejection, so that the stream stays open for
+// the requests that follow it.
+func (app *App) EchoStream(stream echo.Orca_EchoStreamServer) error {
+	for {
+		in, err := stream.Recv()
+
+		// Store the RECV timestamp before any work
+		recv := time.Now()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		reply, err := app.reflect(in, recv)
+		if err != nil {
+			reply = app.rejection(in, recv, err)
+		}
+
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// Helper function that creates the reply to an echo request that was received
+// at the recv timestamp, and journals the request. Returns a gRPC status
+// error if the request expired, the sender is rejected, or the request
+// cannot be authenticated.
+func (app *App) reflect(in *echo.Request, recv time.Time) (*echo.Reply, error) {
 
 	// Log the echo request
 	if app.Config.Debug {
 		log.Println(in.LogRecord())
 	}
 
-	// Figure out who the sender is to update meta
-	source := new(Device)
+	// Figure out who the sender is
 	sender := in.GetSender()
+	if sender == nil {
+		sender = new(echo.Device)
+	}
+
+	// Reject requests that outlived their TTL before they were received
+	if in.Expired(recv) {
+		err := grpc.Errorf(codes.DeadlineExceeded, "the request outlived its ttl of %d seconds", in.TTL)
+		if app.Config.Debug {
+			log.Printf("rejected echo request: %s\n", err)
+		}
+		return nil, err
+	}
 
-	if source != nil {
-		// Populate the source from the database
-		if err := source.GetByName(sender.Name, app.db); err != nil {
-			// No record is in the database so populate it
-			source.Name = sender.Name
-			source.IPAddr = sender.IPAddr
-			source.Domain = sender.Domain
+	// Check the sender against the policy and verify the request
+	if err := app.authenticate(in, sender); err != nil {
+		if app.Config.Debug {
+			log.Printf("rejected echo request: %s\n", err)
 		}
+		return nil, err
 	}
 
-	// Bump the source sequence number and save
-	source.Sequence++
-	source.Save(app.db)
+	// Reject replies that are too large to send
+	if in.ReplySize < 0 || in.ReplySize > MaxPayloadSize {
+		err := grpc.Errorf(codes.InvalidArgument, "the reply size must be between 0 and %d bytes", MaxPayloadSize)
+		if app.Config.Debug {
+			log.Printf("rejected echo request: %s\n", err)
+		}
+		return nil, err
+	}
 
-	// Return the Reply
-	return &echo.Reply{
-		Sequence: source.Sequence,
+	// Bump the response sequence number of the sender in memory
+	sequence := app.nextResponse(sender)
+
+	// Create the Reply
+	reply := &echo.Reply{
+		Sequence: sequence,
 		Receiver: app.GetDevice().Echo(),
 		Received: &echo.Time{Nanoseconds: recv.UnixNano()},
 		Echo:     in,
-	}, nil
+		Payload:  make([]byte, in.ReplySize),
+	}
+
+	// Echo the request without its payload if the sender asked to omit it
+	if in.OmitPayload {
+		echoed := *in
+		echoed.Payload = nil
+		reply.Echo = &echoed
+	}
 
+	// Stamp the transmit time and the processing time as late as possible
+	transmitted := time.Now()
+	reply.Transmitted = &echo.Time{Nanoseconds: transmitted.UnixNano()}
+	reply.Processing = int64(transmitted.Sub(recv))
+
+	// Journal the request so that lost pings can be traced, then reply
+	reflection := &Reflection{
+		Sender:      sender.Name,
+		Receiver:    reply.Receiver.Name,
+		Request:     in.Sequence,
+		Ping:        in.Ping,
+		Sent:        in.GetSentTime(),
+		Recv:        recv,
+		PayloadSize: int64(len(in.Payload)),
+		ReplySize:   int64(reply.GetPayloadSize()),
+	}
+	app.writer.Append(func(store Store) error {
+		// Insert a copy so that a retried flush inserts the reflection again
+		snapshot := *reflection
+		_, err := store.SaveReflection(&snapshot)
+		return err
+	})
+
+	return reply, nil
+}
+
+// Helper function that creates the reply to a request on a stream that was
+// rejected with the status error, since the stream can only end with a
+// status. The request is echoed without its payload so that the generator can
+// match the reply to its ping.
+func (app *App) rejection(in *echo.Request, recv time.Time, err error) *echo.Reply {
+	echoed := *in
+	echoed.Payload = nil
+
+	return &echo.Reply{
+		Receiver: app.GetDevice().Echo(),
+		Received: &echo.Time{Nanoseconds: recv.UnixNano()},
+		Echo:     &echoed,
+		Error:    grpc.ErrorDesc(err),
+		Code:     uint32(grpc.Code(err)),
+	}
+}
+
+// Helper function that increments the response counter of the sequence from
+// the sender to the local device and queues the sender to be saved unless it
+// is transient. The lock ensures that concurrent requests from the same
+// sender get unique responses in the order they were received. The save is
+// queued after the lock is released since the write blocks while the queue
+// is full, and the flush that makes room needs the lock to save the senders.
+func (app *App) nextResponse(sender *echo.Device) int64 {
+	state, response := app.respond(sender)
+	if !state.transient {
+		app.writer.Write(sender.Name, app.saveSender(state))
+	}
+	return response
+}
+
+// Helper function that creates or updates the state of the sender and
+// increments its response counter under the lock.
+func (app *App) respond(sender *echo.Device) (*senderState, int64) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	// Create the state for a sender that has not been seen before
+	state, ok := app.senders[sender.Name]
+	if !ok {
+		_, keyed := app.Config.SenderKeys[sender.Name]
+		state = &senderState{
+			device:    &Device{Name: sender.Name},
+			sequence:  &Sequence{TargetID: app.Device.ID},
+			transient: !keyed && app.Config.Senders == TransientSenders,
+		}
+		app.senders[sender.Name] = state
+	}
+
+	// Update the sender metadata from the request
+	state.device.IPAddr = sender.IPAddr
+	state.device.Domain = sender.Domain
+
+	return state, state.sequence.NextResponse()
+}
+
+// Helper function that returns a write operation that saves a snapshot of the
+// sender device and its sequence to the store. The operation is executed
+// by the batch writer, so the state is copied under the lock and the new IDs
+// are stored back on the state after the records are saved.
+func (app *App) saveSender(state *senderState) WriteFunc {
+	return func(store Store) error {
+		app.mu.Lock()
+		device, sequence := *state.device, *state.sequence
+		app.mu.Unlock()
+
+		// Adopt the device if it was created by another process on the store
+		if device.ID == 0 {
+			if stored, err := store.GetDeviceByName(device.Name); err == nil {
+				device.ModelMeta = stored.ModelMeta
+			}
+		}
+
+		if _, err := store.SaveDevice(&device); err != nil {
+			return err
+		}
+
+		sequence.SourceID = device.ID
+		if sequence.ID == 0 {
+			if stored, err := store.GetSequence(&device, app.GetDevice()); err == nil {
+				sequence.ModelMeta = stored.ModelMeta
+			}
+		}
+
+		if _, err := store.SaveSequence(&sequence); err != nil {
+			return err
+		}
+
+		app.mu.Lock()
+		state.device.ModelMeta = device.ModelMeta
+		state.sequence.SourceID = sequence.SourceID
+		state.sequence.ModelMeta = sequence.ModelMeta
+		app.mu.Unlock()
+		return nil
+	}
 }
 
-// Reflect listens for EchoRequests and Replies to them.
-func (app *App) Reflect() error {
+// Helper function that loads the devices and their sequences to the local
+// device from the database so that the reflector can reply from memory.
+func (app *App) loadSenders() error {
+	local := app.GetDevice()
+	devices, err := app.FetchDevicesExcept(local)
+	if err != nil {
+		return err
+	}
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	app.senders = make(map[string]*senderState)
+	for _, device := range devices {
+		sequence, err := app.store.GetSequence(device, local)
+		if err != nil {
+			if err != ErrNotFound {
+				return err
+			}
+
+			sequence = &Sequence{SourceID: device.ID, TargetID: local.ID}
+		}
+
+		app.senders[device.Name] = &senderState{device: device, sequence: sequence}
+	}
+
+	return nil
+}
+
+// Reflect listens for EchoRequests and Replies to them until the context is
+// cancelled, then stops the server gracefully and flushes the sender state
+// and journal to the database.
+func (app *App) Reflect(ctx context.Context) (err error) {
+	// Refuse to start against a database that has not been migrated
+	if err := app.CheckSchema(); err != nil {
+		return err
+	}
+
 	// Look up the address to listen on
 	addr, err := app.GetListenAddr()
 	if err != nil {
 		return err
 	}
 
+	// Load the senders so that replies do not need to access the database
+	if err := app.loadSenders(); err != nil {
+		return err
+	}
+
+	// Write the sender state to the database in the background
+	interval := app.Config.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	app.writer = NewBatchWriter(app.store, time.Duration(interval)*time.Second, app.Config.QueueSize)
+	defer func() {
+		// Flush the pending writes after the server has stopped
+		if werr := app.writer.Close(); werr != nil && err == nil {
+			err = werr
+		}
+	}()
+
+	// Secure the gRPC service with TLS if it is configured
+	options := make([]grpc.ServerOption, 0, 3)
+	if app.Config.TLS.Enabled() {
+		config, err := app.Config.TLS.ServerConfig()
+		if err != nil {
+			return err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(config)))
+	}
+
 	// Create the socket to listen on
 	sock, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 
+	// Listen for echo requests in UDP datagrams on the same address
+	packets, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		sock.Close()
+		return err
+	}
+
+	// Listen for HTTP/JSON echo requests on the HTTP port
+	haddr, err := HTTPAddr(addr, app.Config.HTTPPort)
+	if err != nil {
+		sock.Close()
+		packets.Close()
+		return err
+	}
+
+	hsock, err := net.Listen("tcp", haddr)
+	if err != nil {
+		sock.Close()
+		packets.Close()
+		return err
+	}
+
 	// Log the fact that we are listening on the address
 	if app.Config.Debug {
-		log.Printf("Listening for Echo Requests on %s\n", addr)
+		log.Printf("Listening for Echo Requests on %s (tcp and udp) and http://%s%s\n", addr, haddr, EchoPath)
 	}
 
 	// Create the grpc server, handler, and listen
-	server := grpc.NewServer()
+	tracker := new(requestTracker)
+	options = append(options, grpc.UnaryInterceptor(tracker.unary), grpc.StreamInterceptor(tracker.stream))
+	server := grpc.NewServer(options...)
 	echo.RegisterOrcaServer(server, app)
-	server.Serve(sock)
 
-	// Serve until finished
-	return nil
+	// Create the HTTP server and handler
+	mux := http.NewServeMux()
+	mux.HandleFunc(EchoPath, app.EchoHTTP)
+	web := &http.Server{Handler: mux}
+
+	// The UDP reflector is stopped after the other servers
+	udp, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Serve until one of the servers fails or the context is cancelled
+	errc := make(chan error, 3)
+	go func() { errc <- server.Serve(sock) }()
+	go func() { errc <- web.Serve(hsock) }()
+	go func() { errc <- app.ReflectUDP(udp, packets) }()
+
+	select {
+	case err = <-errc:
+		server.Stop()
+		web.Close()
+		cancel()
+	case <-ctx.Done():
+		gracefulStop(server, sock, tracker)
+
+		shutdown, done := context.WithTimeout(context.Background(), ShutdownTimeout)
+		web.Shutdown(shutdown)
+		done()
+
+		cancel()
+		<-errc
+	}
+
+	// Wait for the other servers to stop
+	<-errc
+	<-errc
+	return err
+}
+
+// Tracks the requests that are being handled by the gRPC server so that it
+// can be stopped gracefully; the vendored gRPC server can only be stopped by
+// closing all of its connections, which cancels the requests in flight.
+type requestTracker struct {
+	sync.Mutex
+	requests sync.WaitGroup // The requests in flight
+	stopping bool           // Set when new requests should be refused
+}
+
+// Helper function that implements grpc.UnaryServerInterceptor to track the
+// requests in flight and refuse new requests once the server is stopping.
+func (t *requestTracker) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	t.Lock()
+	if t.stopping {
+		t.Unlock()
+		return nil, grpc.Errorf(codes.Unavailable, "the reflector is shutting down")
+	}
+	t.requests.Add(1)
+	t.Unlock()
+
+	defer t.requests.Done()
+	return handler(ctx, req)
+}
+
+// Helper function that implements grpc.StreamServerInterceptor to refuse new
+// streams once the server is stopping. Streams are long lived, so they are not
+// waited on; they are closed when the server stops.
+func (t *requestTracker) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	t.Lock()
+	stopping := t.stopping
+	t.Unlock()
+
+	if stopping {
+		return grpc.Errorf(codes.Unavailable, "the reflector is shutting down")
+	}
+	return handler(srv, ss)
+}
+
+// Helper function that stops the server gracefully: it stops accepting new
+// connections and requests, waits up to the ShutdownTimeout for the requests
+// in flight to finish, then closes all of the connections.
+func gracefulStop(server *grpc.Server, sock net.Listener, tracker *requestTracker) {
+	sock.Close()
+
+	tracker.Lock()
+	tracker.stopping = true
+	tracker.Unlock()
+
+	waitTimeout(&tracker.requests, ShutdownTimeout)
+	server.Stop()
 }
@@ -0,0 +1,502 @@
+package orca_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/bbengfort/orca"
+	"github.com/bbengfort/orca/echo"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Helper function that returns a free TCP port on the loopback address.
+func freePort() int {
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	Ω(err).ShouldNot(HaveOccurred())
+	defer sock.Close()
+	return sock.Addr().(*net.TCPAddr).Port
+}
+
+// Helper function that runs a reflector with the configuration on free
+// loopback ports until the returned function is called, which stops the
+// reflector and returns its error.
+func runReflector(conf *Config) (*App, func() error) {
+	conf.Addr = fmt.Sprintf("127.0.0.1:%d", freePort())
+	conf.HTTPPort = freePort()
+
+	app := &App{Config: conf}
+	Ω(app.ConnectDB()).Should(Succeed())
+	Ω(app.CreateDB()).Should(Succeed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- app.Reflect(ctx) }()
+
+	// Wait until the reflector is listening
+	Eventually(func() error {
+		conn, err := net.Dial("tcp", conf.Addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}, 5*time.Second).Should(Succeed())
+
+	return app, func() error {
+		cancel()
+
+		var err error
+		Eventually(errc, 10*time.Second).Should(Receive(&err))
+		return err
+	}
+}
+
+// Helper function that creates an echo request from the sender.
+func newRequest(sender string, sequence int64) *echo.Request {
+	return &echo.Request{
+		Sequence: sequence,
+		Sender:   &echo.Device{Name: sender},
+		Sent:     &echo.Time{Nanoseconds: time.Now().UnixNano()},
+		TTL:      30,
+		Ping:     sequence,
+		Payload:  []byte("payload"),
+	}
+}
+
+// Helper function that sends the echo request to the address in a datagram and
+// returns the decoded reply.
+func echoUDP(addr string, in *echo.Request) *echo.Reply {
+	data, err := proto.Marshal(in)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	conn, err := net.Dial("udp", addr)
+	Ω(err).ShouldNot(HaveOccurred())
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write(data)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	buf := make([]byte, MaxDatagramSize)
+	n, err := conn.Read(buf)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	reply := new(echo.Reply)
+	Ω(proto.Unmarshal(buf[:n], reply)).Should(Succeed())
+	return reply
+}
+
+var _ = Describe("Reflector", func() {
+
+	var dir string
+	var conf *Config
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		conf = &Config{Name: "reflector", DBPath: filepath.Join(dir, "orca.db"), FlushInterval: 1}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// Helper function that counts the rows of a table in the database
+	count := func(app *App, table string) int {
+		var n int
+		row := app.GetStore().(*SQLiteStore).DB().QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table))
+		Ω(row.Scan(&n)).Should(Succeed())
+		return n
+	}
+
+	// Helper function that sends an echo request to the reflector over gRPC
+	send := func(in *echo.Request) (*echo.Reply, error) {
+		conn, err := grpc.Dial(conf.Addr, grpc.WithInsecure())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return echo.NewOrcaClient(conn).Echo(ctx, in)
+	}
+
+	Describe("sender policies", func() {
+
+		var app *App
+		var stop func() error
+
+		// Helper function that signs a request from the sender with the key
+		signed := func(sender, key string) *echo.Request {
+			in := newRequest(sender, 1)
+			in.Sign([]byte(key))
+			return in
+		}
+
+		JustBeforeEach(func() {
+			conf.SenderKeys = map[string]string{"keyed": "secret"}
+			app, stop = runReflector(conf)
+		})
+
+		AfterEach(func() {
+			app.GetStore().Close()
+		})
+
+		It("should reply to keyed senders that sign their requests", func() {
+			reply, err := send(signed("keyed", "secret"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reply.Sequence).Should(BeEquivalentTo(1))
+			Ω(stop()).Should(Succeed())
+		})
+
+		It("should refuse keyed senders with a bad or missing signature", func() {
+			_, err := send(signed("keyed", "guess"))
+			Ω(grpc.Code(err)).Should(Equal(codes.Unauthenticated))
+
+			_, err = send(newRequest("keyed", 1))
+			Ω(grpc.Code(err)).Should(Equal(codes.Unauthenticated))
+
+			// A request that was modified after it was signed does not verify
+			in := signed("keyed", "secret")
+			in.ReplySize = 1024
+			_, err = send(in)
+			Ω(grpc.Code(err)).Should(Equal(codes.Unauthenticated))
+
+			// Refused requests are not journaled
+			Ω(stop()).Should(Succeed())
+			Ω(count(app, "reflections")).Should(BeZero())
+		})
+
+		It("should refuse signed requests that were not sent recently", func() {
+			in := newRequest("keyed", 1)
+			in.Sent = &echo.Time{Nanoseconds: time.Now().Add(time.Minute).UnixNano()}
+			in.Sign([]byte("secret"))
+
+			_, err := send(in)
+			Ω(grpc.Code(err)).Should(Equal(codes.Unauthenticated))
+
+			// Requests without a TTL are checked with the timeout
+			in = newRequest("keyed", 1)
+			in.TTL = 0
+			in.Sent = &echo.Time{Nanoseconds: time.Now().Add(-time.Minute).UnixNano()}
+			in.Sign([]byte("secret"))
+
+			_, err = send(in)
+			Ω(grpc.Code(err)).Should(Equal(codes.Unauthenticated))
+			Ω(stop()).Should(Succeed())
+		})
+
+		Context("when unknown senders are allowed", func() {
+
+			It("should reply to and store unkeyed senders", func() {
+				_, err := send(newRequest("unkeyed", 1))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(stop()).Should(Succeed())
+
+				Ω(count(app, "devices")).Should(Equal(2))
+				Ω(count(app, "sequences")).Should(Equal(1))
+				Ω(count(app, "reflections")).Should(Equal(1))
+			})
+
+		})
+
+		Context("when unknown senders are rejected", func() {
+
+			BeforeEach(func() {
+				conf.Senders = RejectSenders
+			})
+
+			It("should only reply to keyed and known senders", func() {
+				_, err := send(newRequest("unkeyed", 1))
+				Ω(grpc.Code(err)).Should(Equal(codes.PermissionDenied))
+
+				_, err = send(signed("keyed", "secret"))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(stop()).Should(Succeed())
+
+				Ω(count(app, "devices")).Should(Equal(2))
+				Ω(count(app, "reflections")).Should(Equal(1))
+			})
+
+		})
+
+		Context("when unknown senders are transient", func() {
+
+			BeforeEach(func() {
+				conf.Senders = TransientSenders
+			})
+
+			It("should reply to unknown senders without storing them", func() {
+				for seq := int64(1); seq <= 3; seq++ {
+					reply, err := send(newRequest("unkeyed", seq))
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(reply.Sequence).Should(Equal(seq))
+				}
+				Ω(stop()).Should(Succeed())
+
+				// Transient senders are still journaled
+				Ω(count(app, "devices")).Should(Equal(1))
+				Ω(count(app, "sequences")).Should(BeZero())
+				Ω(count(app, "reflections")).Should(Equal(3))
+			})
+
+		})
+
+	})
+
+	It("should journal the echo requests that it reflects", func() {
+		app, stop := runReflector(conf)
+		defer app.GetStore().Close()
+
+		in := newRequest("sender", 3)
+		in.Ping = 42
+		_, err := send(in)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stop()).Should(Succeed())
+
+		reflection := new(Reflection)
+		Ω(reflection.Get(1, app.GetStore().(*SQLiteStore).DB())).Should(Succeed())
+		Ω(reflection.Sender).Should(Equal("sender"))
+		Ω(reflection.Receiver).Should(Equal("reflector"))
+		Ω(reflection.Request).Should(BeEquivalentTo(3))
+		Ω(reflection.Ping).Should(BeEquivalentTo(42))
+		Ω(reflection.Sent.Equal(in.GetSentTime())).Should(BeTrue())
+		Ω(reflection.PayloadSize).Should(BeEquivalentTo(len(in.Payload)))
+	})
+
+	It("should refuse requests that outlived their TTL without journaling them", func() {
+		app, stop := runReflector(conf)
+		defer app.GetStore().Close()
+
+		in := newRequest("sender", 1)
+		in.TTL = 1
+		in.Sent = &echo.Time{Nanoseconds: time.Now().Add(-5 * time.Second).UnixNano()}
+
+		_, err := send(in)
+		Ω(grpc.Code(err)).Should(Equal(codes.DeadlineExceeded))
+
+		// A request within its TTL is replied to and journaled
+		_, err = send(newRequest("sender", 2))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stop()).Should(Succeed())
+
+		var request int64
+		row := app.GetStore().(*SQLiteStore).DB().QueryRow("SELECT request FROM reflections")
+		Ω(row.Scan(&request)).Should(Succeed())
+		Ω(request).Should(BeEquivalentTo(2))
+		Ω(count(app, "reflections")).Should(Equal(1))
+	})
+
+	It("should reply to a rejected request on a stream without ending it", func() {
+		app, stop := runReflector(conf)
+		defer app.GetStore().Close()
+
+		conn, err := grpc.Dial(conf.Addr, grpc.WithInsecure())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		stream, err := echo.NewOrcaClient(conn).EchoStream(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// The expired request is answered with the status of the rejection
+		expired := newRequest("sender", 1)
+		expired.TTL = 1
+		expired.Sent = &echo.Time{Nanoseconds: time.Now().Add(-5 * time.Second).UnixNano()}
+		Ω(stream.Send(expired)).Should(Succeed())
+
+		reply, err := stream.Recv()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(codes.Code(reply.Code)).Should(Equal(codes.DeadlineExceeded))
+		Ω(reply.Error).Should(ContainSubstring("ttl"))
+		Ω(reply.Echo.Ping).Should(BeEquivalentTo(1))
+		Ω(reply.Echo.Payload).Should(BeEmpty())
+
+		// The request that follows it on the stream is replied to
+		Ω(stream.Send(newRequest("sender", 2))).Should(Succeed())
+
+		reply, err = stream.Recv()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(reply.Error).Should(BeEmpty())
+		Ω(reply.Echo.Ping).Should(BeEquivalentTo(2))
+		Ω(reply.Sequence).Should(BeEquivalentTo(1))
+
+		Ω(stream.CloseSend()).Should(Succeed())
+		Ω(stop()).Should(Succeed())
+		Ω(count(app, "reflections")).Should(Equal(1))
+	})
+
+	It("should not deadlock when the write queue is full", func() {
+		conf.QueueSize = 2
+		app, stop := runReflector(conf)
+
+		conn, err := grpc.Dial(conf.Addr, grpc.WithInsecure())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+		client := echo.NewOrcaClient(conn)
+
+		// Several senders fill the queue with their reflections and state
+		const senders, requests = 8, 40
+		var wg sync.WaitGroup
+		errs := make(chan error, senders*requests)
+		for i := 0; i < senders; i++ {
+			wg.Add(1)
+			go func(sender string) {
+				defer wg.Done()
+				for seq := int64(1); seq <= requests; seq++ {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					_, err := client.Echo(ctx, newRequest(sender, seq))
+					cancel()
+					if err != nil {
+						errs <- err
+					}
+				}
+			}(fmt.Sprintf("sender%d", i))
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		Eventually(done, 20*time.Second).Should(BeClosed())
+		Ω(errs).Should(BeEmpty())
+		Ω(stop()).Should(Succeed())
+
+		Ω(count(app, "reflections")).Should(Equal(senders * requests))
+		Ω(count(app, "devices")).Should(Equal(senders + 1))
+		app.GetStore().Close()
+	})
+
+	It("should journal every request it replied to when it is stopped", func() {
+		// Nothing is written until the reflector is stopped
+		conf.FlushInterval, conf.QueueSize = 3600, 1<<20
+		app, stop := runReflector(conf)
+		defer app.GetStore().Close()
+
+		conn, err := grpc.Dial(conf.Addr, grpc.WithInsecure())
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+		client := echo.NewOrcaClient(conn)
+
+		// A stream that is still open when the reflector stops is closed
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.EchoStream(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stream.Send(newRequest("streamer", 1))).Should(Succeed())
+		_, err = stream.Recv()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		// Senders keep sending requests until the reflector is stopped
+		const senders = 4
+		var replied int64
+		var wg sync.WaitGroup
+		for i := 0; i < senders; i++ {
+			wg.Add(1)
+			go func(sender string) {
+				defer wg.Done()
+				for seq := int64(1); ; seq++ {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+					_, err := client.Echo(ctx, newRequest(sender, seq))
+					cancel()
+					if err != nil {
+						return
+					}
+					atomic.AddInt64(&replied, 1)
+				}
+			}(fmt.Sprintf("sender%d", i))
+		}
+
+		Eventually(func() int64 { return atomic.LoadInt64(&replied) }).Should(BeNumerically(">=", 20))
+		Ω(count(app, "reflections")).Should(BeZero())
+		Ω(stop()).Should(Succeed())
+		wg.Wait()
+
+		_, err = stream.Recv()
+		Ω(err).Should(HaveOccurred())
+
+		// Every request that was replied to was journaled (requests can also
+		// be journaled if the reply was cut off by the shutdown), along with
+		// the senders and their sequences
+		Ω(count(app, "reflections")).Should(BeNumerically(">=", atomic.LoadInt64(&replied)+1))
+		Ω(count(app, "devices")).Should(Equal(senders + 2))
+		Ω(count(app, "sequences")).Should(Equal(senders + 1))
+	})
+
+	It("should not reply over UDP with more than an unkeyed sender sent", func() {
+		conf.SenderKeys = map[string]string{"keyed": "secret"}
+		app, stop := runReflector(conf)
+		defer app.GetStore().Close()
+		defer stop()
+
+		// The reply is trimmed to the size of the request, whether it asks
+		// for a larger payload or for its own payload to be echoed
+		in := newRequest("unkeyed", 1)
+		in.Payload = make([]byte, 256)
+		in.ReplySize = 4096
+		in.OmitPayload = true
+
+		reply := echoUDP(conf.Addr, in)
+		Ω(reply.Error).Should(BeEmpty())
+		Ω(proto.Size(reply)).Should(BeNumerically("<=", proto.Size(in)))
+		Ω(reply.Payload).ShouldNot(BeEmpty())
+		Ω(reply.GetEcho().Ping).Should(Equal(in.Ping))
+
+		in = newRequest("unkeyed", 2)
+		in.Payload = make([]byte, 256)
+
+		reply = echoUDP(conf.Addr, in)
+		Ω(reply.Error).Should(BeEmpty())
+		Ω(proto.Size(reply)).Should(BeNumerically("<=", proto.Size(in)))
+		Ω(reply.GetEcho().OmitPayload).Should(BeTrue())
+		Ω(reply.GetEcho().Payload).Should(BeEmpty())
+		Ω(reply.GetEcho().Sequence).Should(Equal(in.Sequence))
+
+		// Keyed senders are authenticated, so they get the size they asked for
+		in = newRequest("keyed", 1)
+		in.ReplySize = 4096
+		in.OmitPayload = true
+		in.Sign([]byte("secret"))
+
+		reply = echoUDP(conf.Addr, in)
+		Ω(reply.Error).Should(BeEmpty())
+		Ω(reply.Payload).Should(HaveLen(4096))
+		Ω(proto.Size(reply)).Should(BeNumerically(">", proto.Size(in)))
+	})
+
+	It("should reply over UDP with an error if the reply does not fit in a datagram", func() {
+		conf.SenderKeys = map[string]string{"keyed": "secret"}
+		app, stop := runReflector(conf)
+		defer app.GetStore().Close()
+		defer stop()
+
+		in := newRequest("keyed", 1)
+		in.Payload = make([]byte, 40000)
+		in.ReplySize = 40000
+		in.Sign([]byte("secret"))
+
+		reply := echoUDP(conf.Addr, in)
+		Ω(reply.Error).Should(ContainSubstring("does not fit in a datagram"))
+		Ω(reply.Payload).Should(BeEmpty())
+		Ω(reply.GetEcho().Ping).Should(Equal(in.Ping))
+		Ω(reply.GetEcho().Payload).Should(BeEmpty())
+	})
+
+})
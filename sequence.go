@@ -0,0 +1,241 @@
+package orca
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Sequence holds the request and response counters for echo requests from a
+// source device to a target device, stored in the sequences table. The
+// generator (the source) increments the request counter for every ping it
+// sends and the reflector (the target) increments the response counter for
+// every request it receives, so each side only maintains its own counter.
+type Sequence struct {
+	SourceID int64 // The device that sends the echo requests
+	TargetID int64 // The device that reflects the echo requests
+	Request  int64 // The number of requests sent by the source to the target
+	Response int64 // The number of requests received by the target from the source
+	ModelMeta
+}
+
+// SequenceReport summarizes the sequence numbers of the pings from a source
+// to a target device. Because the reflector only increments the response
+// counter when it receives a request, gaps in the response numbers are
+// replies that were lost on the way back to the source; the remaining lost
+// pings were lost on the way to the target (or not answered by it).
+type SequenceReport struct {
+	Source     string // The name of the source device
+	Target     string // The name of the target device
+	Sent       int64  // The number of pings recorded by the source
+	Replied    int64  // The number of pings that were replied to
+	Lost       int64  // The number of pings that were not replied to
+	Missing    int64  // Request numbers that were never recorded by the source
+	Gaps       int64  // Response numbers that are missing from the replies
+	Duplicates int64  // Request or response numbers that were seen more than once
+	Reordered  int64  // Replies with a response lower than that of an earlier request
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Sequence Methods
+/////////////////////////////////////////////////////////////////////////////
+
+// Get a sequence from the database by ID and populate the struct fields.
+func (s *Sequence) Get(id int64, db Executor) error {
+	row := db.QueryRow("SELECT * FROM sequences WHERE id = $1", id)
+	err := row.Scan(&s.ID, &s.SourceID, &s.TargetID, &s.Request, &s.Response, &s.Created, &s.Updated)
+
+	return err
+}
+
+// GetByPair a sequence from the database by the source and target device and
+// populate the struct fields.
+func (s *Sequence) GetByPair(source, target *Device, db Executor) error {
+	query := "SELECT * FROM sequences WHERE source_id = $1 AND target_id = $2"
+	row := db.QueryRow(query, source.ID, target.ID)
+	err := row.Scan(&s.ID, &s.SourceID, &s.TargetID, &s.Request, &s.Response, &s.Created, &s.Updated)
+
+	return err
+}
+
+// Save a sequence struct to the database. This function checks if the
+// sequence has an ID or not. If it does, it will execute a SQL UPDATE,
+// otherwise it will execute a SQL INSERT. Returns a boolean if the sequence
+// was inserted. This method handles setting the meta timestamps as well.
+// Sequences with an ID that is not in the database (e.g. because the batch
+// that inserted them was rolled back) are inserted with their ID.
+func (s *Sequence) Save(db Executor) (bool, error) {
+	if s.ID > 0 {
+		// This is the UPDATE method so return false.
+		s.Updated = time.Now()
+
+		query := "UPDATE sequences SET source_id=$1, target_id=$2, request=$3, response=$4, updated=$5 WHERE id = $6"
+		res, err := db.Exec(query, s.SourceID, s.TargetID, s.Request, s.Response, s.Updated, s.ID)
+		if err != nil {
+			return false, err
+		}
+
+		// If the sequence was updated we're done, otherwise its insert was
+		// rolled back so insert it again with its ID
+		if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+			return false, err
+		}
+	}
+
+	// This is the INSERT method, so return true
+	s.Created = time.Now()
+	s.Updated = time.Now()
+
+	// A NULL id is assigned by sqlite3
+	id := sql.NullInt64{Int64: s.ID, Valid: s.ID > 0}
+
+	query := "INSERT INTO sequences (id, source_id, target_id, request, response, created, updated) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	res, err := db.Exec(query, id, s.SourceID, s.TargetID, s.Request, s.Response, s.Created, s.Updated)
+	if err != nil {
+		return false, err
+	}
+
+	// Look up the last inserted ID from sqlite3
+	sid, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+
+	// Store the ID and return
+	s.ID = sid
+	return true, err
+}
+
+// Delete a sequence from the database. Returns true if the number of rows
+// affected is 1 or false otherwise.
+func (s *Sequence) Delete(db Executor) (bool, error) {
+	return deleteFromDatabase(db, "sequences", s.ID)
+}
+
+// Exists checks if the specified sequence is in the database.
+func (s *Sequence) Exists(id int64, db Executor) (bool, error) {
+	if id == 0 {
+		id = s.ID
+	}
+	return existsInDatabase(db, "sequences", id)
+}
+
+// NextRequest increments and returns the request counter.
+func (s *Sequence) NextRequest() int64 {
+	s.Request++
+	return s.Request
+}
+
+// NextResponse increments and returns the response counter.
+func (s *Sequence) NextResponse() int64 {
+	s.Response++
+	return s.Response
+}
+
+// String returns a pretty representation of the sequence
+func (s *Sequence) String() string {
+	output := "%d -> %d request=%d response=%d"
+	return fmt.Sprintf(output, s.SourceID, s.TargetID, s.Request, s.Response)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Sequence Analysis
+/////////////////////////////////////////////////////////////////////////////
+
+// AnalyzeSequences groups the pings by their source and target devices and
+// reports the gaps, duplicates, and reordering in their sequence numbers.
+// The pings only need the source, target, request, response, and status.
+func AnalyzeSequences(pings []*Ping) []*SequenceReport {
+	var reports []*SequenceReport
+	var pairs [][]*Ping
+	index := make(map[[2]int64]int)
+
+	// Group the pings by pair, keeping the order that the pairs appear in
+	for _, ping := range pings {
+		key := [2]int64{ping.Source.ID, ping.Target.ID}
+		idx, ok := index[key]
+		if !ok {
+			idx = len(pairs)
+			index[key] = idx
+			pairs = append(pairs, nil)
+			reports = append(reports, &SequenceReport{Source: ping.Source.Name, Target: ping.Target.Name})
+		}
+		pairs[idx] = append(pairs[idx], ping)
+	}
+
+	// Analyze each of the pairs
+	for idx, report := range reports {
+		report.analyze(pairs[idx])
+	}
+
+	return reports
+}
+
+// Helper function that computes the report for the pings of a single pair.
+func (r *SequenceReport) analyze(pings []*Ping) {
+	// Order the pings by the request sequence number
+	sort.Sort(byRequest(pings))
+
+	requests := make(map[int64]bool)
+	responses := make(map[int64]bool)
+	var prev, min, max, highest int64
+
+	for i, ping := range pings {
+		r.Sent++
+
+		// Detect duplicate and missing request numbers
+		if requests[ping.Request] {
+			r.Duplicates++
+		} else if i > 0 && ping.Request > prev+1 {
+			r.Missing += ping.Request - prev - 1
+		}
+		requests[ping.Request] = true
+		prev = ping.Request
+
+		if ping.Status != PingReplied {
+			r.Lost++
+			continue
+		}
+
+		// Detect duplicate and reordered response numbers
+		r.Replied++
+		if responses[ping.Response] {
+			r.Duplicates++
+			continue
+		}
+
+		if ping.Response < highest {
+			r.Reordered++
+		} else {
+			highest = ping.Response
+		}
+
+		if len(responses) == 0 || ping.Response < min {
+			min = ping.Response
+		}
+		if ping.Response > max {
+			max = ping.Response
+		}
+		responses[ping.Response] = true
+	}
+
+	// Any response numbers between the lowest and highest that are missing
+	// were counted by the target but their replies never arrived.
+	if len(responses) > 0 {
+		r.Gaps = (max - min + 1) - int64(len(responses))
+	}
+}
+
+// String returns a pretty representation of the sequence report
+func (r *SequenceReport) String() string {
+	output := "%s -> %s sent=%d replied=%d lost=%d missing=%d gaps=%d duplicates=%d reordered=%d"
+	return fmt.Sprintf(output, r.Source, r.Target, r.Sent, r.Replied, r.Lost, r.Missing, r.Gaps, r.Duplicates, r.Reordered)
+}
+
+// Helper type to sort pings by their request sequence number.
+type byRequest []*Ping
+
+func (p byRequest) Len() int           { return len(p) }
+func (p byRequest) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byRequest) Less(i, j int) bool { return p[i].Request < p[j].Request }
@@ -0,0 +1,98 @@
+package orca_test
+
+import (
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sequence", func() {
+
+	var alpha, bravo, charlie *Device
+
+	// Helper function to create a ping between two devices
+	ping := func(source, target *Device, request, response int64, status string) *Ping {
+		return &Ping{Source: source, Target: target, Request: request, Response: response, Status: status}
+	}
+
+	BeforeEach(func() {
+		alpha = &Device{Name: "alpha"}
+		alpha.ID = 1
+		bravo = &Device{Name: "bravo"}
+		bravo.ID = 2
+		charlie = &Device{Name: "charlie"}
+		charlie.ID = 3
+	})
+
+	It("should implement the Model interface", func() {
+		var iface interface{} = &Sequence{}
+		_, ok := iface.(Model)
+		Ω(ok).Should(BeTrue())
+	})
+
+	It("should increment the request and response counters separately", func() {
+		seq := new(Sequence)
+		Ω(seq.NextRequest()).Should(BeEquivalentTo(1))
+		Ω(seq.NextRequest()).Should(BeEquivalentTo(2))
+		Ω(seq.NextResponse()).Should(BeEquivalentTo(1))
+	})
+
+	It("should report each source and target pair separately", func() {
+		reports := AnalyzeSequences([]*Ping{
+			ping(alpha, bravo, 1, 1, PingReplied),
+			ping(alpha, charlie, 1, 1, PingReplied),
+			ping(alpha, bravo, 2, 2, PingReplied),
+		})
+
+		Ω(reports).Should(HaveLen(2))
+		Ω(reports[0].Target).Should(Equal("bravo"))
+		Ω(reports[0].Sent).Should(BeEquivalentTo(2))
+		Ω(reports[1].Target).Should(Equal("charlie"))
+		Ω(reports[1].Sent).Should(BeEquivalentTo(1))
+	})
+
+	It("should report a clean sequence", func() {
+		reports := AnalyzeSequences([]*Ping{
+			ping(alpha, bravo, 1, 1, PingReplied),
+			ping(alpha, bravo, 2, 2, PingReplied),
+			ping(alpha, bravo, 3, 3, PingReplied),
+		})
+
+		Ω(reports).Should(HaveLen(1))
+		Ω(*reports[0]).Should(Equal(SequenceReport{Source: "alpha", Target: "bravo", Sent: 3, Replied: 3}))
+	})
+
+	It("should tell forward loss apart from reverse loss", func() {
+		reports := AnalyzeSequences([]*Ping{
+			ping(alpha, bravo, 1, 1, PingReplied),
+			ping(alpha, bravo, 2, 0, PingTimeout), // lost on the way to bravo
+			ping(alpha, bravo, 3, 2, PingReplied),
+			ping(alpha, bravo, 4, 0, PingTimeout), // reply lost on the way back
+			ping(alpha, bravo, 5, 4, PingReplied),
+		})
+
+		report := reports[0]
+		Ω(report.Lost).Should(BeEquivalentTo(2))
+		Ω(report.Gaps).Should(BeEquivalentTo(1))
+		Ω(report.Reordered).Should(BeZero())
+	})
+
+	It("should report duplicates, reordering, and missing requests", func() {
+		reports := AnalyzeSequences([]*Ping{
+			ping(alpha, bravo, 1, 1, PingReplied),
+			ping(alpha, bravo, 2, 3, PingReplied),
+			ping(alpha, bravo, 3, 2, PingReplied),
+			ping(alpha, bravo, 4, 3, PingReplied),
+			ping(alpha, bravo, 7, 4, PingReplied),
+		})
+
+		report := reports[0]
+		Ω(report.Sent).Should(BeEquivalentTo(5))
+		Ω(report.Missing).Should(BeEquivalentTo(2))
+		Ω(report.Duplicates).Should(BeEquivalentTo(1))
+		Ω(report.Reordered).Should(BeEquivalentTo(1))
+		Ω(report.Gaps).Should(BeZero())
+	})
+
+})
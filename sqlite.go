@@ -0,0 +1,335 @@
+package orca
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// Imports the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// BusyTimeout is the number of milliseconds that a connection to the SQLite3
+// database waits for the locks held by other connections (e.g. a reflector
+// and a generator that share a database) before it fails with "database is
+// locked".
+const BusyTimeout = 5000
+
+// The largest offset of a time zone from UTC, and the format of the text that
+// bounds the sent times of the pings in the queries.
+const (
+	maxZoneOffset   = 14 * time.Hour
+	sentBoundFormat = "2006-01-02 15:04:05"
+)
+
+// SQLiteStore stores the models in a SQLite3 database with the methods of the
+// models, which execute the queries on the database. The schema of the
+// database is created and upgraded by the migrations.
+type SQLiteStore struct {
+	conn *sql.DB  // Connection to the database
+	db   Executor // Executes the queries, on the connection or in a transaction
+}
+
+// OpenSQLiteStore opens the SQLite3 database at the path. The database is put
+// in write-ahead log mode so that readers do not block the writer, and the
+// connections wait on locks for the BusyTimeout. Transactions take the write
+// lock when they begin so that they wait on the other writers rather than
+// failing when they try to upgrade a read lock.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	conn, err := sql.Open("sqlite3", sqliteDSN(path))
+	if err != nil {
+		return nil, err
+	}
+
+	// The journal mode is stored in the database so it applies to every connection
+	if _, err := conn.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{conn: conn, db: conn}, nil
+}
+
+// DB returns the connection to the database, e.g. for analyses that are
+// specific to SQLite. Use with care.
+func (s *SQLiteStore) DB() *sql.DB {
+	return s.conn
+}
+
+// Close the connection to the database.
+func (s *SQLiteStore) Close() error {
+	return s.conn.Close()
+}
+
+// Batch executes the operations on a store that writes to the database in a
+// single transaction, which is committed if the operations succeed and rolled
+// back otherwise. Batches cannot be nested.
+func (s *SQLiteStore) Batch(ops func(store Store) error) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := ops(&SQLiteStore{conn: s.conn, db: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Devices and Locations
+/////////////////////////////////////////////////////////////////////////////
+
+// GetDevice from the database by ID.
+func (s *SQLiteStore) GetDevice(id int64) (*Device, error) {
+	device := new(Device)
+	if err := device.Get(id, s.db); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// GetDeviceByName from the database.
+func (s *SQLiteStore) GetDeviceByName(name string) (*Device, error) {
+	device := new(Device)
+	if err := device.GetByName(name, s.db); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// SaveDevice inserts or updates the device in the database.
+func (s *SQLiteStore) SaveDevice(device *Device) (bool, error) {
+	return device.Save(s.db)
+}
+
+// ListDevices returns the devices in the database, ordered by the created
+// timestamp with the most recent device first.
+func (s *SQLiteStore) ListDevices() (Devices, error) {
+	var devices Devices
+
+	rows, err := s.db.Query("SELECT * FROM devices ORDER BY created DESC")
+	if err != nil {
+		return devices, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := new(Device)
+		if err := rows.Scan(&d.ID, &d.Name, &d.IPAddr, &d.Domain, &d.Sequence, &d.Created, &d.Updated); err != nil {
+			return devices, err
+		}
+
+		devices = append(devices, d)
+	}
+
+	return devices, rows.Err()
+}
+
+// GetLocation from the database by ID.
+func (s *SQLiteStore) GetLocation(id int64) (*Location, error) {
+	loc := new(Location)
+	if err := loc.Get(id, s.db); err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// GetLocationByIP from the database.
+func (s *SQLiteStore) GetLocationByIP(ipaddr string) (*Location, error) {
+	loc := &Location{IPAddr: ipaddr}
+	if err := loc.IPExists(s.db); err != nil {
+		return nil, err
+	}
+	return s.GetLocation(loc.ID)
+}
+
+// SaveLocation inserts or updates the location in the database.
+func (s *SQLiteStore) SaveLocation(loc *Location) (bool, error) {
+	return loc.Save(s.db)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Pings
+/////////////////////////////////////////////////////////////////////////////
+
+// GetPing from the database by ID along with its devices and location.
+func (s *SQLiteStore) GetPing(id int64) (*Ping, error) {
+	ping := new(Ping)
+	if err := ping.Get(id, s.db); err != nil {
+		return nil, err
+	}
+	return ping, nil
+}
+
+// SavePing inserts or updates the ping in the database.
+func (s *SQLiteStore) SavePing(ping *Ping) (bool, error) {
+	return ping.Save(s.db)
+}
+
+// LastPingID returns the largest ID of the pings in the database.
+func (s *SQLiteStore) LastPingID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM pings").Scan(&id)
+	return id, err
+}
+
+// EachPing calls fn with every ping in the page of the pings in the database
+// that match the query along with their devices and locations, ordered by ID.
+// The rows are read one at a time and the query stops at the first error that
+// fn returns.
+func (s *SQLiteStore) EachPing(q *PingQuery, fn func(*Ping) error) error {
+	// Add a condition for every filter of the query
+	var where []string
+	var args []interface{}
+	filter := func(condition string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+
+	if q.Source != "" {
+		filter("s.name = $%d", q.Source)
+	}
+	if q.Target != "" {
+		filter("t.name = $%d", q.Target)
+	}
+	if q.Location != "" {
+		filter("$%d IN (l.ipaddr, l.city, l.country)", q.Location)
+	}
+	if q.Probe != "" {
+		filter("p.probe = $%d", q.Probe)
+	}
+	if q.Status != "" {
+		filter("p.status = $%d", q.Status)
+	}
+
+	// Timestamps are stored as text in the time zone of the generator, so they
+	// are compared as julian days. The julian days cannot use the index on the
+	// sent time, so the text is first bounded by the UTC time widened by the
+	// largest zone offset, which every time in the range is within.
+	if !q.Since.IsZero() {
+		filter("p.sent >= $%d", q.Since.UTC().Add(-maxZoneOffset).Format(sentBoundFormat))
+		filter("julianday(p.sent) >= julianday($%d)", q.Since)
+	}
+	if !q.Until.IsZero() {
+		filter("p.sent < $%d", q.Until.UTC().Add(maxZoneOffset).Format(sentBoundFormat))
+		filter("julianday(p.sent) < julianday($%d)", q.Until)
+	}
+
+	// Pings without a latency are NULL, which does not match the bounds
+	if q.MinLatency > 0 {
+		filter("p.latency >= $%d", q.MinLatency)
+	}
+	if q.MaxLatency > 0 {
+		filter("p.latency <= $%d", q.MaxLatency)
+	}
+
+	query := pingQuery
+	if len(where) > 0 {
+		query += "WHERE " + strings.Join(where, " AND ") + " "
+	}
+	query += "ORDER BY p.id"
+
+	// A negative limit returns all of the rows after the offset
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := q.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, q.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := new(Ping)
+		if err := p.scan(rows); err != nil {
+			return err
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// PingSequences returns the request and response numbers and the status of
+// the echo pings along with the IDs and names of their devices, ordered by
+// pair and request. Only the columns of the analysis are selected.
+func (s *SQLiteStore) PingSequences() ([]*Ping, error) {
+	var pings []*Ping
+
+	query := "SELECT p.request, p.response, p.status, s.id, s.name, t.id, t.name FROM pings p JOIN devices s ON p.source_id = s.id JOIN devices t ON p.target_id = t.id WHERE p.probe = $1 ORDER BY p.source_id, p.target_id, p.request"
+	rows, err := s.db.Query(query, EchoProbe)
+	if err != nil {
+		return pings, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := &Ping{Source: new(Device), Target: new(Device)}
+		if err := rows.Scan(&p.Request, &p.Response, &p.Status, &p.Source.ID, &p.Source.Name, &p.Target.ID, &p.Target.Name); err != nil {
+			return pings, err
+		}
+
+		pings = append(pings, p)
+	}
+
+	return pings, rows.Err()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Sequences, Clock Offsets and Reflections
+/////////////////////////////////////////////////////////////////////////////
+
+// GetSequence of the source and target devices from the database.
+func (s *SQLiteStore) GetSequence(source, target *Device) (*Sequence, error) {
+	seq := new(Sequence)
+	if err := seq.GetByPair(source, target, s.db); err != nil {
+		return nil, err
+	}
+	return seq, nil
+}
+
+// SaveSequence inserts or updates the sequence in the database.
+func (s *SQLiteStore) SaveSequence(seq *Sequence) (bool, error) {
+	return seq.Save(s.db)
+}
+
+// GetClockOffset of the source and target devices from the database.
+func (s *SQLiteStore) GetClockOffset(source, target *Device) (*ClockOffset, error) {
+	offset := new(ClockOffset)
+	if err := offset.GetByPair(source, target, s.db); err != nil {
+		return nil, err
+	}
+	return offset, nil
+}
+
+// SaveClockOffset inserts or updates the clock offset in the database.
+func (s *SQLiteStore) SaveClockOffset(offset *ClockOffset) (bool, error) {
+	return offset.Save(s.db)
+}
+
+// SaveReflection inserts or updates the reflection in the database.
+func (s *SQLiteStore) SaveReflection(reflection *Reflection) (bool, error) {
+	return reflection.Save(s.db)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// Helper Functions
+/////////////////////////////////////////////////////////////////////////////
+
+// Helper function that returns the data source name of the database at the
+// path with the busy timeout and the locking of transactions.
+func sqliteDSN(path string) string {
+	return fmt.Sprintf("%s?_busy_timeout=%d&_txlock=immediate", path, BusyTimeout)
+}
@@ -0,0 +1,165 @@
+package orca
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Store backends specify where the models are stored: in the SQLite database
+// at the dbpath of the configuration, or in memory, in which case nothing is
+// kept after the process exits (e.g. to run a reflector or to test without a
+// database file).
+const (
+	SQLiteBackend  = "sqlite"
+	MemoryBackend  = "memory"
+	DefaultBackend = SQLiteBackend
+)
+
+// ErrNotFound is returned by the stores if a model does not exist. It is the
+// same error that the database returns for a query without results.
+var ErrNotFound = sql.ErrNoRows
+
+// Store persists the devices, locations and pings along with the sequences,
+// clock offsets and journal that the generator and reflector keep, so that
+// the application does not depend on how they are stored. Saving a model
+// with an ID updates it, otherwise it is inserted and its ID is set; the
+// returned boolean is true if the model was inserted. Models that do not
+// exist are reported with ErrNotFound. Pings can be saved with an ID that
+// was reserved from the LastPingID, in which case they are inserted with it;
+// a ping never overwrites another ping that was stored with the same ID.
+type Store interface {
+	// Devices
+	GetDevice(id int64) (*Device, error)          // Get a device by ID
+	GetDeviceByName(name string) (*Device, error) // Get a device by its unique name
+	SaveDevice(device *Device) (bool, error)      // Insert or update a device
+	ListDevices() (Devices, error)                // All devices, most recently created first
+
+	// Locations
+	GetLocation(id int64) (*Location, error)          // Get a location by ID
+	GetLocationByIP(ipaddr string) (*Location, error) // Get a location by its unique IP address
+	SaveLocation(loc *Location) (bool, error)         // Insert or update a location
+
+	// Pings
+	GetPing(id int64) (*Ping, error)                       // Get a ping with its devices and location
+	SavePing(ping *Ping) (bool, error)                     // Insert or update a ping
+	EachPing(query *PingQuery, fn func(*Ping) error) error // Calls fn with the pings that match the query, ordered by ID
+	LastPingID() (int64, error)                            // The largest ID of the stored pings
+	PingSequences() ([]*Ping, error)                       // The numbers of the echo pings by pair and request
+
+	// Sequences, clock offsets and reflections
+	GetSequence(source, target *Device) (*Sequence, error)       // Get the sequence of a pair of devices
+	SaveSequence(seq *Sequence) (bool, error)                    // Insert or update a sequence
+	GetClockOffset(source, target *Device) (*ClockOffset, error) // Get the clock offset of a pair of devices
+	SaveClockOffset(offset *ClockOffset) (bool, error)           // Insert or update a clock offset
+	SaveReflection(reflection *Reflection) (bool, error)         // Journal a received echo request
+
+	// Close the store and release its resources
+	Close() error
+}
+
+// Batcher is implemented by stores that can execute several operations in a
+// single transaction, which is much faster than a transaction for every write.
+// The operations are executed on the store that is passed to them.
+type Batcher interface {
+	Batch(ops func(store Store) error) error
+}
+
+// PingQuery filters the pings returned by a store. Fields with zero values do
+// not filter the pings. The location matches the IP address, city or country
+// of the location the ping was sent from, the time range is compared to the
+// sent time, and pings without a latency do not match the latency bounds.
+// The limit and offset paginate the matching pings in the order of their IDs.
+type PingQuery struct {
+	Source     string    // The name of the source device
+	Target     string    // The name of the target device
+	Location   string    // The IP address, city or country of the location
+	Probe      string    // The type of probe (echo, tcp, or dns)
+	Status     string    // The outcome of the ping (sent, replied, timeout, etc.)
+	Since      time.Time // Pings sent at or after this time
+	Until      time.Time // Pings sent before this time
+	MinLatency float64   // The minimum latency in milliseconds
+	MaxLatency float64   // The maximum latency in milliseconds
+	Limit      int       // The maximum number of pings to return
+	Offset     int       // The number of matching pings to skip
+}
+
+// Match returns true if the ping passes the filters of the query. The limit
+// and offset are applied by the stores to the matching pings.
+func (q *PingQuery) Match(ping *Ping) bool {
+	switch {
+	case q.Source != "" && (ping.Source == nil || ping.Source.Name != q.Source):
+		return false
+	case q.Target != "" && (ping.Target == nil || ping.Target.Name != q.Target):
+		return false
+	case q.Location != "" && !q.matchLocation(ping.Location):
+		return false
+	case q.Probe != "" && ping.Probe != q.Probe:
+		return false
+	case q.Status != "" && ping.Status != q.Status:
+		return false
+	case !q.Since.IsZero() && ping.Sent.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !ping.Sent.Before(q.Until):
+		return false
+	case q.MinLatency > 0 && (!ping.Latency.Valid || ping.Latency.Float64 < q.MinLatency):
+		return false
+	case q.MaxLatency > 0 && (!ping.Latency.Valid || ping.Latency.Float64 > q.MaxLatency):
+		return false
+	default:
+		return true
+	}
+}
+
+// Paginate returns the page of the matching pings given by the limit and
+// offset of the query.
+func (q *PingQuery) Paginate(pings []*Ping) []*Ping {
+	if q.Offset > 0 {
+		if q.Offset >= len(pings) {
+			return nil
+		}
+		pings = pings[q.Offset:]
+	}
+
+	if q.Limit > 0 && q.Limit < len(pings) {
+		pings = pings[:q.Limit]
+	}
+
+	return pings
+}
+
+// QueryPings returns the pings in the store that match the query, ordered by
+// ID. Use EachPing to handle the pings one at a time instead of loading all of
+// them into memory.
+func QueryPings(store Store, q *PingQuery) ([]*Ping, error) {
+	var pings []*Ping
+	err := store.EachPing(q, func(ping *Ping) error {
+		pings = append(pings, ping)
+		return nil
+	})
+	return pings, err
+}
+
+// Helper function that matches the location filter of the query.
+func (q *PingQuery) matchLocation(loc *Location) bool {
+	if loc == nil {
+		return false
+	}
+	return loc.IPAddr == q.Location || loc.City == q.Location || loc.Country == q.Location
+}
+
+// OpenStore opens the store of the backend in the configuration.
+func OpenStore(conf *Config) (Store, error) {
+	switch conf.Store {
+	case "", SQLiteBackend:
+		store, err := OpenSQLiteStore(conf.DBPath)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case MemoryBackend:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("Unknown store %q (use sqlite or memory)", conf.Store)
+	}
+}
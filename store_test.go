@@ -0,0 +1,366 @@
+package orca_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stores", func() {
+
+	for _, backend := range []string{SQLiteBackend, MemoryBackend} {
+		backend := backend
+
+		Describe(backend, func() {
+
+			var dir string
+			var store Store
+			var alpha, bravo *Device
+			var location *Location
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "orca")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				app := &App{Config: &Config{Store: backend, DBPath: filepath.Join(dir, "orca.db")}}
+				Ω(app.ConnectDB()).Should(Succeed())
+				Ω(app.CreateDB()).Should(Succeed())
+				Ω(app.CheckSchema()).Should(Succeed())
+				store = app.GetStore()
+
+				alpha = &Device{Name: "alpha", IPAddr: "10.0.0.1:3265"}
+				bravo = &Device{Name: "bravo", IPAddr: "10.0.0.2:3265"}
+				location = &Location{IPAddr: "127.0.0.1", City: "Washington"}
+				Ω(store.SaveDevice(alpha)).Should(BeTrue())
+				Ω(store.SaveDevice(bravo)).Should(BeTrue())
+				Ω(store.SaveLocation(location)).Should(BeTrue())
+			})
+
+			AfterEach(func() {
+				store.Close()
+				os.RemoveAll(dir)
+			})
+
+			It("should save and get devices and locations", func() {
+				Ω(alpha.ID).Should(BeEquivalentTo(1))
+				Ω(bravo.ID).Should(BeEquivalentTo(2))
+
+				device, err := store.GetDeviceByName("bravo")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(device.ID).Should(Equal(bravo.ID))
+				Ω(device.IPAddr).Should(Equal("10.0.0.2:3265"))
+
+				device.Domain = "bravo.example.com"
+				Ω(store.SaveDevice(device)).Should(BeFalse())
+				device, err = store.GetDevice(bravo.ID)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(device.Domain).Should(Equal("bravo.example.com"))
+
+				devices, err := store.ListDevices()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(devices).Should(HaveLen(2))
+
+				loc, err := store.GetLocationByIP("127.0.0.1")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(loc.ID).Should(Equal(location.ID))
+				Ω(loc.City).Should(Equal("Washington"))
+			})
+
+			It("should report models that do not exist", func() {
+				_, err := store.GetDevice(42)
+				Ω(err).Should(Equal(ErrNotFound))
+				_, err = store.GetDeviceByName("charlie")
+				Ω(err).Should(Equal(ErrNotFound))
+				_, err = store.GetLocationByIP("10.0.0.3")
+				Ω(err).Should(Equal(ErrNotFound))
+				_, err = store.GetPing(1)
+				Ω(err).Should(Equal(ErrNotFound))
+				_, err = store.GetSequence(alpha, bravo)
+				Ω(err).Should(Equal(ErrNotFound))
+				_, err = store.GetClockOffset(alpha, bravo)
+				Ω(err).Should(Equal(ErrNotFound))
+			})
+
+			It("should enforce unique names and addresses", func() {
+				_, err := store.SaveDevice(&Device{Name: "alpha"})
+				Ω(err).Should(MatchError(ContainSubstring("UNIQUE")))
+				_, err = store.SaveLocation(&Location{IPAddr: "127.0.0.1"})
+				Ω(err).Should(MatchError(ContainSubstring("UNIQUE")))
+			})
+
+			It("should save and get the sequences and clock offsets of pairs", func() {
+				seq := &Sequence{SourceID: alpha.ID, TargetID: bravo.ID}
+				seq.NextRequest()
+				Ω(store.SaveSequence(seq)).Should(BeTrue())
+
+				stored, err := store.GetSequence(alpha, bravo)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(stored.Request).Should(BeEquivalentTo(1))
+
+				stored.NextRequest()
+				Ω(store.SaveSequence(stored)).Should(BeFalse())
+				stored, _ = store.GetSequence(alpha, bravo)
+				Ω(stored.Request).Should(BeEquivalentTo(2))
+
+				_, err = store.GetSequence(bravo, alpha)
+				Ω(err).Should(Equal(ErrNotFound))
+
+				offset := &ClockOffset{SourceID: alpha.ID, TargetID: bravo.ID, Offset: 1.5}
+				Ω(store.SaveClockOffset(offset)).Should(BeTrue())
+				estimate, err := store.GetClockOffset(alpha, bravo)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(estimate.Offset).Should(Equal(1.5))
+
+				reflection := &Reflection{Sender: "alpha", Receiver: "bravo", Request: 1, Ping: 1, Sent: time.Now(), Recv: time.Now()}
+				Ω(store.SaveReflection(reflection)).Should(BeTrue())
+				Ω(reflection.ID).Should(BeEquivalentTo(1))
+			})
+
+			It("should save, get and query pings", func() {
+				for i, target := range []*Device{bravo, bravo, alpha} {
+					ping := &Ping{
+						Source: alpha, Target: target, Location: location, Request: int64(i + 1),
+						Sent: time.Now(), Status: PingReplied, Probe: EchoProbe, Transport: UnaryTransport,
+					}
+					if i == 1 {
+						ping.Status = PingTimeout
+					}
+					Ω(store.SavePing(ping)).Should(BeTrue())
+					Ω(ping.ID).Should(BeEquivalentTo(i + 1))
+				}
+
+				ping, err := store.GetPing(2)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(ping.Source.Name).Should(Equal("alpha"))
+				Ω(ping.Target.Name).Should(Equal("bravo"))
+				Ω(ping.Location.City).Should(Equal("Washington"))
+				Ω(ping.Status).Should(Equal(PingTimeout))
+
+				ping.Status = PingReplied
+				Ω(store.SavePing(ping)).Should(BeFalse())
+				ping, _ = store.GetPing(2)
+				Ω(ping.Status).Should(Equal(PingReplied))
+
+				pings, err := QueryPings(store, new(PingQuery))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(HaveLen(3))
+				Ω(pings[0].ID).Should(BeEquivalentTo(1))
+
+				pings, err = QueryPings(store, &PingQuery{Target: "bravo", Status: PingReplied})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(HaveLen(2))
+
+				pings, err = QueryPings(store, &PingQuery{Source: "bravo"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(BeEmpty())
+			})
+
+			It("should save and query pings without a location", func() {
+				ping := &Ping{Source: alpha, Target: bravo, Sent: time.Now(), Status: PingSent, Probe: EchoProbe}
+				Ω(store.SavePing(ping)).Should(BeTrue())
+
+				ping.Status = PingReplied
+				Ω(store.SavePing(ping)).Should(BeFalse())
+
+				located := &Ping{Source: alpha, Target: bravo, Location: location, Sent: time.Now(), Status: PingSent, Probe: EchoProbe}
+				Ω(store.SavePing(located)).Should(BeTrue())
+
+				fetched, err := store.GetPing(ping.ID)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(fetched.Location).Should(BeNil())
+				Ω(fetched.Status).Should(Equal(PingReplied))
+				Ω(fetched.Target.Name).Should(Equal("bravo"))
+
+				pings, err := QueryPings(store, &PingQuery{Source: "alpha"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(HaveLen(2))
+				Ω(pings[0].Location).Should(BeNil())
+				Ω(pings[1].Location).ShouldNot(BeNil())
+				Ω(pings[1].Location.City).Should(Equal("Washington"))
+				Ω(pings[1].Location.Created.IsZero()).Should(BeFalse())
+			})
+
+			It("should filter and paginate pings", func() {
+				start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+				for i := 0; i < 6; i++ {
+					ping := &Ping{
+						Source: alpha, Target: bravo, Request: int64(i + 1),
+						Sent: start.Add(time.Duration(i) * time.Hour), Status: PingReplied, Probe: EchoProbe,
+					}
+					ping.Latency.Float64, ping.Latency.Valid = float64(10*(i+1)), true
+					if i%2 == 0 {
+						ping.Location = location
+					}
+					if i == 5 {
+						ping.Latency.Valid = false
+						ping.Status = PingTimeout
+					}
+					Ω(store.SavePing(ping)).Should(BeTrue())
+				}
+
+				ids := func(q *PingQuery) []int64 {
+					pings, err := QueryPings(store, q)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					ids := make([]int64, 0, len(pings))
+					for _, ping := range pings {
+						ids = append(ids, ping.ID)
+					}
+					return ids
+				}
+
+				Ω(ids(&PingQuery{Location: "Washington"})).Should(Equal([]int64{1, 3, 5}))
+				Ω(ids(&PingQuery{Location: "127.0.0.1", Target: "bravo"})).Should(Equal([]int64{1, 3, 5}))
+				Ω(ids(&PingQuery{Location: "Paris"})).Should(BeEmpty())
+				Ω(ids(&PingQuery{Since: start.Add(2 * time.Hour), Until: start.Add(4 * time.Hour)})).Should(Equal([]int64{3, 4}))
+				Ω(ids(&PingQuery{Since: start.Add(90 * time.Minute).In(time.FixedZone("EST", -5*3600))})).Should(Equal([]int64{3, 4, 5, 6}))
+				Ω(ids(&PingQuery{MinLatency: 20, MaxLatency: 40})).Should(Equal([]int64{2, 3, 4}))
+				Ω(ids(&PingQuery{MinLatency: 45})).Should(Equal([]int64{5}))
+				Ω(ids(&PingQuery{Status: PingReplied, Limit: 2})).Should(Equal([]int64{1, 2}))
+				Ω(ids(&PingQuery{Status: PingReplied, Limit: 2, Offset: 4})).Should(Equal([]int64{5}))
+				Ω(ids(&PingQuery{Offset: 3})).Should(Equal([]int64{4, 5, 6}))
+				Ω(ids(&PingQuery{Offset: 6})).Should(BeEmpty())
+			})
+
+			It("should compare the sent times of pings in different time zones", func() {
+				start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+				zones := []*time.Location{
+					time.FixedZone("AEST", 10*3600), time.UTC, time.FixedZone("PST", -8*3600), time.FixedZone("LINT", 14*3600),
+				}
+				for i, zone := range zones {
+					sent := start.Add(time.Duration(i) * time.Hour).In(zone)
+					ping := &Ping{Source: alpha, Target: bravo, Request: int64(i + 1), Sent: sent, Status: PingSent, Probe: EchoProbe}
+					Ω(store.SavePing(ping)).Should(BeTrue())
+				}
+
+				pings, err := QueryPings(store, &PingQuery{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(HaveLen(2))
+				Ω(pings[0].Request).Should(BeEquivalentTo(2))
+				Ω(pings[1].Request).Should(BeEquivalentTo(3))
+
+				pings, err = QueryPings(store, &PingQuery{Since: start.Add(3 * time.Hour).In(zones[2])})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(HaveLen(1))
+				Ω(pings[0].Request).Should(BeEquivalentTo(4))
+			})
+
+			It("should stop iterating the pings at the first error", func() {
+				for i := 0; i < 4; i++ {
+					ping := &Ping{Source: alpha, Target: bravo, Request: int64(i + 1), Sent: time.Now(), Status: PingSent, Probe: EchoProbe}
+					Ω(store.SavePing(ping)).Should(BeTrue())
+				}
+
+				var requests []int64
+				stop := errors.New("stop")
+				err := store.EachPing(new(PingQuery), func(ping *Ping) error {
+					requests = append(requests, ping.Request)
+					if len(requests) == 2 {
+						return stop
+					}
+					return nil
+				})
+
+				Ω(err).Should(Equal(stop))
+				Ω(requests).Should(Equal([]int64{1, 2}))
+			})
+
+			It("should insert pings with reserved IDs", func() {
+				Ω(store.LastPingID()).Should(BeZero())
+
+				for _, id := range []int64{3, 7} {
+					ping := &Ping{
+						ID: id, Source: alpha, Target: bravo, Location: location,
+						Sent: time.Now(), Status: PingSent, Probe: EchoProbe,
+					}
+					Ω(store.SavePing(ping)).Should(BeTrue())
+					Ω(ping.ID).Should(Equal(id))
+
+					ping.Status = PingReplied
+					Ω(store.SavePing(ping)).Should(BeFalse())
+				}
+
+				Ω(store.LastPingID()).Should(BeEquivalentTo(7))
+				ping, err := store.GetPing(7)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(ping.Status).Should(Equal(PingReplied))
+
+				pings, err := QueryPings(store, new(PingQuery))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(pings).Should(HaveLen(2))
+				Ω(pings[0].ID).Should(BeEquivalentTo(3))
+			})
+
+			It("should return the sequences of the echo pings by pair and request", func() {
+				pings := []*Ping{
+					{Source: bravo, Target: alpha, Request: 1, Probe: EchoProbe},
+					{Source: alpha, Target: bravo, Request: 2, Probe: EchoProbe},
+					{Source: alpha, Target: bravo, Request: 0, Probe: TCPProbe},
+					{Source: alpha, Target: bravo, Request: 1, Probe: EchoProbe},
+				}
+				for _, ping := range pings {
+					ping.Sent = time.Now()
+					ping.Status = PingReplied
+					Ω(store.SavePing(ping)).Should(BeTrue())
+				}
+
+				sequences, err := store.PingSequences()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(sequences).Should(HaveLen(3))
+
+				var order []string
+				for _, p := range sequences {
+					order = append(order, fmt.Sprintf("%s->%s %d %s", p.Source.Name, p.Target.Name, p.Request, p.Status))
+				}
+				Ω(order).Should(Equal([]string{"alpha->bravo 1 replied", "alpha->bravo 2 replied", "bravo->alpha 1 replied"}))
+			})
+
+			It("should not overwrite a ping with a colliding reserved ID", func() {
+				ping := &Ping{ID: 3, Source: alpha, Target: bravo, Sent: time.Now(), Status: PingSent, Probe: EchoProbe}
+				Ω(store.SavePing(ping)).Should(BeTrue())
+
+				// Another generator reserved the same ID
+				other := &Ping{ID: 3, Source: bravo, Target: alpha, Sent: time.Now(), Status: PingReplied, Probe: EchoProbe}
+				_, err := store.SavePing(other)
+				Ω(err).Should(MatchError(ContainSubstring("UNIQUE")))
+
+				// The stored ping can still be updated after it is fetched
+				stored, err := store.GetPing(3)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(stored.Source.Name).Should(Equal("alpha"))
+				Ω(stored.Status).Should(Equal(PingSent))
+
+				stored.Status = PingReplied
+				Ω(store.SavePing(stored)).Should(BeFalse())
+				stored, err = store.GetPing(3)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(stored.Status).Should(Equal(PingReplied))
+			})
+
+		})
+	}
+
+	It("should open the database in write-ahead log mode", func() {
+		dir, err := ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		store, err := OpenSQLiteStore(filepath.Join(dir, "orca.db"))
+		Ω(err).ShouldNot(HaveOccurred())
+		defer store.Close()
+
+		var mode string
+		Ω(store.DB().QueryRow("PRAGMA journal_mode").Scan(&mode)).Should(Succeed())
+		Ω(mode).Should(Equal("wal"))
+	})
+
+})
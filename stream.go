@@ -0,0 +1,157 @@
+package orca
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bbengfort/orca/echo"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Transports specify how the generator sends echo requests to reflectors. The
+// unary transport sends every request as its own RPC; the stream transport
+// sends requests on a long lived bidirectional EchoStream to each device. The
+// UDP transport sends requests in datagrams without gRPC as a baseline, and
+// the HTTP transport posts JSON requests to the HTTP/1.1 echo endpoint.
+const (
+	UnaryTransport   = "unary"
+	StreamTransport  = "stream"
+	UDPTransport     = "udp"
+	HTTPTransport    = "http"
+	DefaultTransport = UnaryTransport
+)
+
+// StreamManager keeps an EchoStream open to each device that is pinged with
+// the stream transport. A stream is only used by the worker of its device,
+// which sends one request at a time and waits for the reply, so replies are
+// matched to requests by their order on the stream.
+type StreamManager struct {
+	sync.Mutex
+	streams map[int64]*deviceStream
+}
+
+// Holds the stream to a device along with the connection it was opened on
+// and the function that closes the stream.
+type deviceStream struct {
+	conn   *grpc.ClientConn
+	stream echo.Orca_EchoStreamClient
+	cancel context.CancelFunc
+}
+
+// NewStreamManager creates a stream manager with no open streams.
+func NewStreamManager() *StreamManager {
+	return &StreamManager{streams: make(map[int64]*deviceStream)}
+}
+
+// Get returns the stream to the device, opening a new stream on the connection
+// if there is no stream or the stream was opened on a different connection.
+// Opening the stream is abandoned if the context is done first.
+func (m *StreamManager) Get(ctx context.Context, device *Device, conn *grpc.ClientConn) (echo.Orca_EchoStreamClient, error) {
+	m.Lock()
+	ds, ok := m.streams[device.ID]
+	m.Unlock()
+
+	if ok && ds.conn == conn {
+		return ds.stream, nil
+	}
+
+	// Close the stream on the old connection
+	if ok {
+		m.Reset(device)
+	}
+
+	// The stream outlives the ping, so it is not opened with the ping context
+	sctx, cancel := context.WithCancel(context.Background())
+	opened := make(chan struct{})
+	defer close(opened)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-opened:
+		}
+	}()
+
+	stream, err := echo.NewOrcaClient(conn).EchoStream(sctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	m.Lock()
+	m.streams[device.ID] = &deviceStream{conn: conn, stream: stream, cancel: cancel}
+	m.Unlock()
+
+	return stream, nil
+}
+
+// Reset closes the stream to the device so that a new stream is opened for
+// the next ping, e.g. after a request on the stream failed or timed out.
+func (m *StreamManager) Reset(device *Device) {
+	m.Lock()
+	defer m.Unlock()
+
+	if ds, ok := m.streams[device.ID]; ok {
+		ds.stream.CloseSend()
+		ds.cancel()
+		delete(m.streams, device.ID)
+	}
+}
+
+// Close all of the streams.
+func (m *StreamManager) Close() {
+	m.Lock()
+	defer m.Unlock()
+
+	for id, ds := range m.streams {
+		ds.stream.CloseSend()
+		ds.cancel()
+		delete(m.streams, id)
+	}
+}
+
+// Helper function that sends the echo request on the stream and waits for the
+// reply or for the context to be done. If the context is done the stream must
+// be reset, since the reply may still arrive on it. A reply that echoes
+// another ping is returned so that the mismatch is recorded, but the stream
+// must also be reset since its replies are out of step with its requests. A
+// reply to a rejected request is returned as an error with the status of the
+// rejection. The round trip is stored on the ping in milliseconds; the first byte is not
+// timed since the response headers are only sent once when the stream is
+// opened.
+func invokeEchoStream(ctx context.Context, stream echo.Orca_EchoStreamClient, request *echo.Request, ping *Ping) (*echo.Reply, error) {
+	type result struct {
+		reply *echo.Reply
+		err   error
+	}
+
+	started := time.Now()
+	if err := stream.Send(request); err != nil {
+		return nil, err
+	}
+
+	// Receive in the background so that the request can time out
+	results := make(chan result, 1)
+	go func() {
+		reply, err := stream.Recv()
+		results <- result{reply, err}
+	}()
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		ping.RPCLatency = milliseconds(time.Since(started))
+		if err := replyError(r.reply); err != nil {
+			return nil, err
+		}
+		return r.reply, nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
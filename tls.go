@@ -0,0 +1,130 @@
+package orca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig specifies the certificates that secure the gRPC service. The
+// reflector serves TLS with the certificate and key of the local device, and
+// the generator verifies the reflectors with the CA certificate. In mutual
+// mode the reflector also requires a client certificate signed by the CA and
+// the generator presents the certificate of the local device.
+type TLSConfig struct {
+	Cert   string `yaml:"cert"`   // Path to the PEM certificate of the local device
+	Key    string `yaml:"key"`    // Path to the PEM private key of the local device
+	CA     string `yaml:"ca"`     // Path to the PEM certificate of the certificate authority
+	Mutual bool   `yaml:"mutual"` // Require and present client certificates (mTLS)
+}
+
+// Enabled returns true if any of the certificates are configured, otherwise
+// the gRPC service is insecure.
+func (conf *TLSConfig) Enabled() bool {
+	return conf != nil && (conf.Cert != "" || conf.Key != "" || conf.CA != "")
+}
+
+// Validate the combination of certificates, returning an error if the
+// configuration cannot be used by either the reflector or the generator.
+func (conf *TLSConfig) Validate() error {
+	if !conf.Enabled() {
+		if conf != nil && conf.Mutual {
+			return errors.New("Mutual TLS requires a certificate, key and CA")
+		}
+		return nil
+	}
+
+	if (conf.Cert == "") != (conf.Key == "") {
+		return errors.New("Both a TLS certificate and key are required")
+	}
+
+	if conf.Mutual && (conf.Cert == "" || conf.CA == "") {
+		return errors.New("Mutual TLS requires a certificate, key and CA")
+	}
+
+	return nil
+}
+
+// ServerConfig returns the TLS configuration of the reflector.
+func (conf *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if conf.Cert == "" {
+		return nil, errors.New("The reflector requires a TLS certificate and key")
+	}
+
+	cert, err := tls.LoadX509KeyPair(conf.Cert, conf.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if conf.Mutual {
+		if config.ClientCAs, err = loadCertPool(conf.CA); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// ClientConfig returns the TLS configuration of the generator. If no CA is
+// specified, the reflectors are verified with the system roots.
+func (conf *TLSConfig) ClientConfig() (*tls.Config, error) {
+	config := new(tls.Config)
+
+	if conf.CA != "" {
+		pool, err := loadCertPool(conf.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if conf.Mutual {
+		cert, err := tls.LoadX509KeyPair(conf.Cert, conf.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Helper function that loads a PEM encoded certificate pool from a file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No certificates could be read from %s", path)
+	}
+
+	return pool, nil
+}
+
+// Helper type that wraps the TLS credentials of a connection to record how
+// long the handshake took on the dialer, and the error if it failed. The
+// vendored credentials set the server name on their config during the first
+// handshake, so new credentials must be created for every connection.
+type timedCredentials struct {
+	credentials.TransportAuthenticator
+	dialer *dialer
+}
+
+// ClientHandshake performs and times the TLS handshake with the reflector.
+func (c *timedCredentials) ClientHandshake(addr string, conn net.Conn, timeout time.Duration) (net.Conn, credentials.AuthInfo, error) {
+	started := time.Now()
+	tconn, info, err := c.TransportAuthenticator.ClientHandshake(addr, conn, timeout)
+	c.dialer.handshaked(started, time.Since(started), err)
+	return tconn, info, err
+}
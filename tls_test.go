@@ -0,0 +1,136 @@
+package orca_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// Helper function that creates the CA and the certificate of a device
+	// with the loopback address and returns its TLS configuration.
+	newConfig := func(name string, mutual bool) *TLSConfig {
+		_, err := GenerateCA(dir)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		cert, key, err := GenerateCert(dir, name, []string{"127.0.0.1", "localhost"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		ca, _ := CertPaths(dir, CAName)
+		return &TLSConfig{Cert: cert, Key: key, CA: ca, Mutual: mutual}
+	}
+
+	// Helper function that performs a handshake between a server and a client
+	// with the TLS configurations, returning the client error.
+	handshake := func(server, client *tls.Config) error {
+		sock, err := tls.Listen("tcp", "127.0.0.1:0", server)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer sock.Close()
+
+		go func() {
+			conn, err := sock.Accept()
+			if err == nil {
+				conn.Write([]byte("!"))
+				conn.Close()
+			}
+		}()
+
+		conn, err := tls.Dial("tcp", sock.Addr().String(), client)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		// The server verifies the client certificate after the client handshake,
+		// so read the reply to find out if the handshake succeeded
+		_, err = conn.Read(make([]byte, 1))
+		return err
+	}
+
+	It("should generate a CA once and certificates signed by it", func() {
+		created, err := GenerateCA(dir)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(created).Should(BeTrue())
+
+		created, err = GenerateCA(dir)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(created).Should(BeFalse())
+
+		path, _, err := GenerateCert(dir, "alpha", []string{"10.0.0.1", "alpha.example.com"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		data, err := ioutil.ReadFile(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		block, _ := pem.Decode(data)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(cert.Subject.CommonName).Should(Equal("alpha"))
+		Ω(cert.DNSNames).Should(Equal([]string{"alpha.example.com"}))
+		Ω(cert.IPAddresses).Should(HaveLen(1))
+
+		_, _, err = GenerateCert(dir, "beta", nil)
+		Ω(err).Should(HaveOccurred())
+
+		_, _, err = GenerateCert(dir, CAName, []string{"10.0.0.1"})
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should validate the combination of certificates", func() {
+		Ω((*TLSConfig)(nil).Validate()).Should(Succeed())
+		Ω(new(TLSConfig).Validate()).Should(Succeed())
+		Ω((&TLSConfig{CA: "ca.pem"}).Validate()).Should(Succeed())
+		Ω((&TLSConfig{Cert: "a.pem"}).Validate()).ShouldNot(Succeed())
+		Ω((&TLSConfig{Cert: "a.pem", Key: "a.key", Mutual: true}).Validate()).ShouldNot(Succeed())
+		Ω((&TLSConfig{Mutual: true}).Validate()).ShouldNot(Succeed())
+	})
+
+	It("should verify the reflector with the CA", func() {
+		conf := newConfig("alpha", false)
+		server, err := conf.ServerConfig()
+		Ω(err).ShouldNot(HaveOccurred())
+		client, err := conf.ClientConfig()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		client.ServerName = "127.0.0.1"
+		Ω(handshake(server, client)).Should(Succeed())
+
+		// A client that does not trust the CA refuses the reflector
+		Ω(handshake(server, &tls.Config{ServerName: "127.0.0.1"})).ShouldNot(Succeed())
+	})
+
+	It("should require client certificates in mutual mode", func() {
+		conf := newConfig("alpha", true)
+		server, err := conf.ServerConfig()
+		Ω(err).ShouldNot(HaveOccurred())
+		client, err := conf.ClientConfig()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		client.ServerName = "localhost"
+		Ω(handshake(server, client)).Should(Succeed())
+
+		// A client without a certificate is refused by the reflector
+		client.Certificates = nil
+		Ω(handshake(server, client)).ShouldNot(Succeed())
+	})
+
+})
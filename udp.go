@@ -0,0 +1,223 @@
+package orca
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/bbengfort/orca/echo"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// MaxDatagramSize is the largest echo request or reply that can be sent in a
+// single UDP datagram.
+const MaxDatagramSize = 65507
+
+// ReflectUDP listens for protocol buffer encoded echo requests in UDP
+// datagrams on the socket and replies to them with encoded echo replies
+// until the context is cancelled. This provides a baseline for the latency
+// of the gRPC echo without the HTTP/2 framing. Since the source address of a
+// datagram can be spoofed, the replies to a sender that is not keyed are
+// trimmed so that they are no larger than its request (or dropped if they
+// cannot be), and replies that do not fit in a datagram are replaced by an
+// error reply.
+func (app *App) ReflectUDP(ctx context.Context, sock net.PacketConn) error {
+	// Close the socket when the context is cancelled to stop reading
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sock.Close()
+		case <-done:
+		}
+	}()
+
+	buf := make([]byte, MaxDatagramSize)
+	for {
+		n, addr, err := sock.ReadFrom(buf)
+
+		// Store the RECV timestamp before any work
+		recv := time.Now()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		// Datagrams that are not echo requests are ignored
+		in := new(echo.Request)
+		if err := proto.Unmarshal(buf[:n], in); err != nil {
+			if app.Config.Debug {
+				log.Printf("could not decode datagram from %s: %s\n", addr, err)
+			}
+			continue
+		}
+
+		// Do not amplify the traffic of senders that cannot be authenticated
+		keyed := app.keyed(in.GetSender())
+		if !keyed && in.ReplySize > int64(n) {
+			in.ReplySize = int64(n)
+		}
+
+		// Rejected requests are dropped since there is no status to reply with
+		reply, err := app.reflect(in, recv)
+		if err != nil {
+			continue
+		}
+
+		data, err := proto.Marshal(reply)
+		if err == nil && !keyed && len(data) > n {
+			if data, err = proto.Marshal(trimReply(reply, n)); err == nil && len(data) > n {
+				if app.Config.Debug {
+					log.Printf("dropped echo request from %s: the reply of %d bytes is larger than the request\n", addr, len(data))
+				}
+				continue
+			}
+		}
+
+		if err == nil && len(data) > MaxDatagramSize {
+			data, err = proto.Marshal(datagramError(reply, len(data)))
+		}
+
+		if err != nil {
+			log.Printf("could not encode reply to %s: %s\n", addr, err)
+			continue
+		}
+
+		if _, err := sock.WriteTo(data, addr); err != nil {
+			log.Printf("could not reply to %s: %s\n", addr, err)
+		}
+	}
+}
+
+// Helper function that trims the reply to a sender that is not keyed so that
+// it is encoded in at most size bytes. The request is echoed with only the
+// fields that the generator matches the reply to its ping with, and marked as
+// omitting its payload, then the reply payload is cut to fit. The trimmed reply
+// can still be larger than the size if the request was too small to fit it.
+func trimReply(reply *echo.Reply, size int) *echo.Reply {
+	trimmed := *reply
+	trimmed.Payload = nil
+
+	if in := reply.GetEcho(); in != nil {
+		trimmed.Echo = &echo.Request{
+			Sequence:    in.Sequence,
+			Sent:        in.Sent,
+			TTL:         in.TTL,
+			Ping:        in.Ping,
+			OmitPayload: true,
+		}
+	}
+
+	// Leave room for the tag and length of the payload
+	room := size - proto.Size(&trimmed) - 1 - proto.SizeVarint(uint64(size))
+	if room > len(reply.Payload) {
+		room = len(reply.Payload)
+	}
+
+	if room > 0 {
+		trimmed.Payload = reply.Payload[:room]
+	}
+
+	return &trimmed
+}
+
+// Helper function that replaces a reply that is too large to send in a
+// datagram with an error reply that echoes the request without its payload.
+func datagramError(reply *echo.Reply, size int) *echo.Reply {
+	var echoed *echo.Request
+	if in := reply.GetEcho(); in != nil {
+		echoed = new(echo.Request)
+		*echoed = *in
+		echoed.Payload = nil
+	}
+
+	return &echo.Reply{
+		Sequence: reply.Sequence,
+		Receiver: reply.Receiver,
+		Received: reply.Received,
+		Echo:     echoed,
+		Error:    fmt.Sprintf("the reply of %d bytes does not fit in a datagram of %d bytes", size, MaxDatagramSize),
+	}
+}
+
+// Helper function that sends the echo request to the target of the ping in a
+// UDP datagram and waits for the reply until the context is done. The first
+// reply is returned even if it echoes another ping, so that the mismatch is
+// recorded rather than hidden. The round trip is stored on the ping in
+// milliseconds.
+func invokeEchoUDP(ctx context.Context, request *echo.Request, ping *Ping) (*echo.Reply, error) {
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", ping.Target.IPAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Stop reading when the context is done or its deadline has passed
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	started := time.Now()
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, MaxDatagramSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	ping.RPCLatency = milliseconds(time.Since(started))
+
+	reply := new(echo.Reply)
+	if err := proto.Unmarshal(buf[:n], reply); err != nil {
+		return nil, fmt.Errorf("Could not decode the reply: %s", err)
+	}
+
+	if err := replyError(reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Helper function that returns the error of a reply from a reflector that
+// could not reply to the request, as a gRPC status error if the request was
+// rejected so that the ping is recorded with the status of the rejection.
+func replyError(reply *echo.Reply) error {
+	if reply.Error == "" {
+		return nil
+	}
+
+	if code := codes.Code(reply.Code); code != codes.OK {
+		return grpc.Errorf(code, "The reflector rejected the request: %s", reply.Error)
+	}
+	return fmt.Errorf("The reflector could not reply: %s", reply.Error)
+}
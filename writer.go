@@ -0,0 +1,273 @@
+package orca
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is the number of seconds between flushes of the batch
+// writer if the configuration does not specify a flush interval.
+const DefaultFlushInterval = 1
+
+// DefaultQueueSize is the number of operations that can be pending on the
+// batch writer before writes block if the configuration does not specify it.
+const DefaultQueueSize = 1024
+
+// MaxWriteAttempts is the number of flushes in which an operation can fail
+// before it is dropped, so that an operation that can never succeed does not
+// roll back every flush.
+const MaxWriteAttempts = 3
+
+// WriteFunc is a store operation that is queued on the batch writer. An
+// operation is executed again if its flush fails, so it must be safe to retry.
+type WriteFunc func(store Store) error
+
+// A pending operation and the number of flushes in which it has failed.
+type pendingOp struct {
+	op       WriteFunc
+	attempts int
+}
+
+// BatchWriter queues store operations so that they can be executed in the
+// background rather than in the critical path of a request. Operations are
+// keyed: if an operation is queued with the key of a pending operation it
+// replaces it, so repeated updates to the same record are coalesced into a
+// single write. Operations are executed in the order their keys were first
+// queued, on a single go routine, whenever the flush interval elapses. If the
+// store supports it, every flush is executed in a single transaction.
+//
+// If an operation fails, the flush is rolled back and its operations are
+// queued again under their keys ahead of the newer operations, unless a newer
+// operation has replaced them. Without transactions only the failed
+// operations are queued again. An operation that fails MaxWriteAttempts times
+// is dropped and logged.
+//
+// The number of pending operations is limited so that the writer applies
+// backpressure if the store cannot keep up: once the queue is full it is
+// flushed immediately and new keys block until the flush makes room for them.
+type BatchWriter struct {
+	sync.Mutex
+	store Store                 // The store the operations are executed on
+	keys  []string              // The order in which the pending keys were queued
+	ops   map[string]*pendingOp // The pending operations by key
+	count int64                 // Generates the keys of appended operations
+	limit int                   // The maximum number of pending keys, unlimited if 0
+	space *sync.Cond            // Signaled when a flush makes room in the queue
+	full  chan struct{}         // Requests a flush of the full queue
+	flush sync.Mutex            // Ensures that only one flush executes at a time
+	done  chan struct{}         // Closed to stop the background flushes
+	wg    sync.WaitGroup        // Waits for the background flushes to stop
+}
+
+// NewBatchWriter creates a batch writer and starts flushing the pending
+// operations to the store every interval in the background. At most limit
+// operations can be pending before writes block; if the limit is 0 then the
+// queue is not limited.
+func NewBatchWriter(store Store, interval time.Duration, limit int) *BatchWriter {
+	w := &BatchWriter{
+		store: store,
+		ops:   make(map[string]*pendingOp),
+		limit: limit,
+		full:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	w.space = sync.NewCond(w)
+
+	w.wg.Add(1)
+	go w.run(interval)
+	return w
+}
+
+// Write queues an operation with the given key, replacing any pending
+// operation with the same key. If the queue is full, Write blocks until the
+// pending operations are flushed. Write must not be called after Close.
+func (w *BatchWriter) Write(key string, op WriteFunc) {
+	w.Lock()
+	defer w.Unlock()
+
+	for w.limit > 0 && len(w.keys) >= w.limit {
+		// Replacing a pending operation does not need more room
+		if _, ok := w.ops[key]; ok {
+			break
+		}
+
+		// Ask for a flush and wait for it to make room
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+		w.space.Wait()
+	}
+
+	if _, ok := w.ops[key]; !ok {
+		w.keys = append(w.keys, key)
+	}
+	w.ops[key] = &pendingOp{op: op}
+}
+
+// Append queues an operation that is never coalesced with other operations,
+// e.g. the insert of a new record.
+func (w *BatchWriter) Append(op WriteFunc) {
+	w.Lock()
+	w.count++
+	key := fmt.Sprintf("\x00append %d", w.count)
+	w.Unlock()
+
+	w.Write(key, op)
+}
+
+// Pending returns the number of operations waiting to be flushed.
+func (w *BatchWriter) Pending() int {
+	w.Lock()
+	defer w.Unlock()
+	return len(w.keys)
+}
+
+// Flush executes all of the pending operations in order, in a single
+// transaction if the store is a Batcher. The transaction is rolled back if
+// any operation fails; without a transaction every operation is attempted.
+// The failed operations are queued again and the first error is returned.
+func (w *BatchWriter) Flush() error {
+	_, err := w.execute()
+	return err
+}
+
+// Close stops the background flushes and flushes any pending operations,
+// retrying the failed operations until they are written or dropped. The first
+// error is returned.
+func (w *BatchWriter) Close() error {
+	close(w.done)
+	w.wg.Wait()
+
+	var err error
+	for {
+		attempted, ferr := w.execute()
+		if ferr == nil {
+			return err
+		}
+
+		if err == nil {
+			err = ferr
+		}
+
+		// Stop if the flush failed without an operation failing, e.g. if the
+		// transaction could not be committed, since retrying may never end
+		if !attempted {
+			return err
+		}
+	}
+}
+
+// Helper function that executes the pending operations and queues the failed
+// operations again. Returns true if an operation failed, which counts as an
+// attempt of the operation, along with the first error.
+func (w *BatchWriter) execute() (bool, error) {
+	w.flush.Lock()
+	defer w.flush.Unlock()
+
+	// Swap out the pending operations so writes are not blocked by the flush
+	w.Lock()
+	keys, ops := w.keys, w.ops
+	w.keys, w.ops = nil, make(map[string]*pendingOp)
+	w.space.Broadcast()
+	w.Unlock()
+
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	var failed []string
+	var attempted bool
+
+	if batcher, ok := w.store.(Batcher); ok {
+		err := batcher.Batch(func(store Store) error {
+			for _, key := range keys {
+				if err := ops[key].op(store); err != nil {
+					ops[key].attempts++
+					attempted = true
+					return err
+				}
+			}
+			return nil
+		})
+
+		// Every operation of the flush was rolled back
+		if err != nil {
+			failed = keys
+		}
+
+		w.requeue(failed, ops)
+		return attempted, err
+	}
+
+	var err error
+	for _, key := range keys {
+		if oerr := ops[key].op(w.store); oerr != nil {
+			ops[key].attempts++
+			attempted = true
+			failed = append(failed, key)
+			if err == nil {
+				err = oerr
+			}
+		}
+	}
+
+	w.requeue(failed, ops)
+	return attempted, err
+}
+
+// Helper function that queues the failed operations of a flush again in their
+// order ahead of the operations queued during the flush. Operations that were
+// replaced during the flush or have failed too often are dropped.
+func (w *BatchWriter) requeue(keys []string, ops map[string]*pendingOp) {
+	if len(keys) == 0 {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	requeued := make([]string, 0, len(keys)+len(w.keys))
+	for _, key := range keys {
+		if _, ok := w.ops[key]; ok {
+			continue
+		}
+
+		if ops[key].attempts >= MaxWriteAttempts {
+			log.Printf("dropped write %q after %d failed attempts\n", key, ops[key].attempts)
+			continue
+		}
+
+		w.ops[key] = ops[key]
+		requeued = append(requeued, key)
+	}
+
+	w.keys = append(requeued, w.keys...)
+}
+
+// Helper function that flushes the writer on the interval, or when the queue is
+// full, until it is closed.
+// Errors are logged since there is no caller to return them to.
+func (w *BatchWriter) run(interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				log.Printf("could not flush writes: %s\n", err)
+			}
+		case <-w.full:
+			if err := w.Flush(); err != nil {
+				log.Printf("could not flush writes: %s\n", err)
+			}
+		}
+	}
+}
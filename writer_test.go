@@ -0,0 +1,215 @@
+package orca_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/bbengfort/orca"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BatchWriter", func() {
+
+	var writer *BatchWriter
+	var calls []string
+
+	// Helper function to create an operation that records its call
+	record := func(name string, err error) WriteFunc {
+		return func(store Store) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	BeforeEach(func() {
+		calls = nil
+		writer = NewBatchWriter(nil, time.Hour, 0)
+	})
+
+	AfterEach(func() {
+		writer.Close()
+	})
+
+	It("should execute operations in the order they were queued", func() {
+		writer.Write("a", record("a", nil))
+		writer.Write("b", record("b", nil))
+		writer.Write("c", record("c", nil))
+
+		Ω(writer.Pending()).Should(Equal(3))
+		Ω(writer.Flush()).Should(Succeed())
+		Ω(calls).Should(Equal([]string{"a", "b", "c"}))
+		Ω(writer.Pending()).Should(BeZero())
+	})
+
+	It("should coalesce operations with the same key", func() {
+		writer.Write("a", record("a1", nil))
+		writer.Write("b", record("b1", nil))
+		writer.Write("a", record("a2", nil))
+
+		Ω(writer.Flush()).Should(Succeed())
+		Ω(calls).Should(Equal([]string{"a2", "b1"}))
+	})
+
+	It("should not coalesce appended operations", func() {
+		writer.Append(record("a1", nil))
+		writer.Write("a", record("a2", nil))
+		writer.Append(record("a3", nil))
+
+		Ω(writer.Flush()).Should(Succeed())
+		Ω(calls).Should(Equal([]string{"a1", "a2", "a3"}))
+	})
+
+	It("should attempt every operation and return the first error", func() {
+		writer.Write("a", record("a", errors.New("first")))
+		writer.Write("b", record("b", errors.New("second")))
+		writer.Write("c", record("c", nil))
+
+		Ω(writer.Flush()).Should(MatchError("first"))
+		Ω(calls).Should(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("should requeue failed operations unless they were replaced", func() {
+		writer.Write("a", record("a1", errors.New("failed")))
+		writer.Write("b", record("b1", errors.New("failed")))
+		writer.Write("c", record("c1", nil))
+		Ω(writer.Flush()).ShouldNot(Succeed())
+		Ω(writer.Pending()).Should(Equal(2))
+
+		// The failed operations are retried ahead of the newer operations
+		calls = nil
+		writer.Write("d", record("d1", nil))
+		writer.Write("b", record("b2", nil))
+		Ω(writer.Flush()).Should(MatchError("failed"))
+		Ω(calls).Should(Equal([]string{"a1", "b2", "d1"}))
+	})
+
+	It("should drop operations that fail too often", func() {
+		writer.Write("a", record("a", errors.New("failed")))
+		for i := 0; i < MaxWriteAttempts; i++ {
+			Ω(writer.Flush()).Should(MatchError("failed"))
+		}
+
+		Ω(writer.Pending()).Should(BeZero())
+		Ω(calls).Should(HaveLen(MaxWriteAttempts))
+	})
+
+	It("should flush pending operations on close", func() {
+		writer.Write("a", record("a", nil))
+		Ω(writer.Close()).Should(Succeed())
+		Ω(calls).Should(Equal([]string{"a"}))
+
+		// Replace the writer so that it is not closed twice
+		writer = NewBatchWriter(nil, time.Hour, 0)
+	})
+
+	It("should flush on the interval", func() {
+		interval := NewBatchWriter(nil, 10*time.Millisecond, 0)
+		defer interval.Close()
+
+		interval.Write("a", record("a", nil))
+		Eventually(interval.Pending).Should(BeZero())
+	})
+
+	It("should flush and block writes when the queue is full", func() {
+		var executed int64
+		count := func(store Store) error {
+			atomic.AddInt64(&executed, 1)
+			return nil
+		}
+
+		limited := NewBatchWriter(nil, time.Hour, 2)
+		defer limited.Close()
+
+		limited.Write("a", count)
+		limited.Write("b", count)
+		limited.Write("a", count)
+		Ω(limited.Pending()).Should(Equal(2))
+
+		// The third key waits for the full queue to be flushed
+		limited.Write("c", count)
+		Ω(limited.Pending()).Should(Equal(1))
+		Eventually(func() int64 { return atomic.LoadInt64(&executed) }).Should(BeEquivalentTo(2))
+	})
+
+	It("should execute a flush in a single transaction", func() {
+		dir, err := ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		app := &App{Config: &Config{DBPath: filepath.Join(dir, "orca.db")}}
+		Ω(app.ConnectDB()).Should(Succeed())
+		Ω(app.CreateDB()).Should(Succeed())
+		store := app.GetStore()
+		defer store.Close()
+
+		save := func(name string) WriteFunc {
+			return func(tx Store) error {
+				Ω(tx).ShouldNot(BeIdenticalTo(store))
+				_, err := tx.SaveDevice(&Device{Name: name})
+				return err
+			}
+		}
+
+		batched := NewBatchWriter(store, time.Hour, 0)
+		batched.Append(save("alpha"))
+		batched.Append(save("alpha"))
+		batched.Append(save("bravo"))
+		Ω(batched.Flush()).Should(MatchError(ContainSubstring("UNIQUE")))
+
+		// The failed operation rolls back the others, which are requeued
+		devices, err := store.ListDevices()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(devices).Should(BeEmpty())
+		Ω(batched.Pending()).Should(Equal(3))
+
+		// Close retries until the failed operation is dropped
+		Ω(batched.Close()).Should(MatchError(ContainSubstring("UNIQUE")))
+		devices, err = store.ListDevices()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(devices).Should(HaveLen(2))
+	})
+
+	It("should insert records again after a flush is rolled back", func() {
+		dir, err := ioutil.TempDir("", "orca")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		app := &App{Config: &Config{DBPath: filepath.Join(dir, "orca.db")}}
+		Ω(app.ConnectDB()).Should(Succeed())
+		Ω(app.CreateDB()).Should(Succeed())
+		store := app.GetStore()
+		defer store.Close()
+
+		// The ID of the device is kept although its insert is rolled back
+		device := &Device{Name: "alpha"}
+		fail := true
+		batched := NewBatchWriter(store, time.Hour, 0)
+		batched.Write("alpha", func(tx Store) error {
+			_, err := tx.SaveDevice(device)
+			return err
+		})
+		batched.Append(func(tx Store) error {
+			if fail {
+				return errors.New("failed")
+			}
+			return nil
+		})
+
+		Ω(batched.Flush()).Should(MatchError("failed"))
+		Ω(device.ID).ShouldNot(BeZero())
+
+		fail = false
+		Ω(batched.Close()).Should(Succeed())
+
+		stored, err := store.GetDeviceByName("alpha")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(stored.ID).Should(Equal(device.ID))
+	})
+
+})